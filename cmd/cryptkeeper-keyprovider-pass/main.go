@@ -0,0 +1,17 @@
+// cryptkeeper-keyprovider-pass is the reference key provider. It wraps data
+// keys with a secret kept in pass, or printed by $CK_PASS_COMMAND.
+package main
+
+import (
+	"os"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider/pass"
+)
+
+func main() {
+	// Errors were already sent to cryptkeeper in the response.
+	if err := keyprovider.Serve(os.Stdin, os.Stdout, pass.Provider{}); err != nil {
+		os.Exit(1)
+	}
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/sunny-b/cryptkeeper/internal/commands"
 	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
 	"github.com/sunny-b/cryptkeeper/internal/logger"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -16,6 +18,9 @@ var rootCmd = &cobra.Command{Use: "cryptkeeper"}
 
 func init() {
 	cobra.OnInitialize(initConfig)
+
+	rootCmd.PersistentFlags().BoolVar(&config.IgnoreIntegrity, "ignore-integrity", false, "Load the config even if its integrity check fails")
+	rootCmd.PersistentFlags().IntVar(&crypt.KeyFD, "key-fd", 0, "Read the key file from this inherited file descriptor instead of the key path")
 }
 
 func main() {
@@ -29,6 +34,16 @@ func main() {
 	rootCmd.AddCommand(commands.Verify)
 	rootCmd.AddCommand(commands.Direnv)
 	rootCmd.AddCommand(commands.Version)
+	rootCmd.AddCommand(commands.Rotate)
+	rootCmd.AddCommand(commands.Migrate)
+	rootCmd.AddCommand(commands.Reencrypt)
+	rootCmd.AddCommand(commands.Key)
+	rootCmd.AddCommand(commands.Agent)
+	rootCmd.AddCommand(commands.Unlock)
+	rootCmd.AddCommand(commands.Lock)
+	rootCmd.AddCommand(commands.Recipients)
+	rootCmd.AddCommand(commands.Reseal)
+	rootCmd.AddCommand(commands.Trust)
 
 	logLevelStr := os.Getenv("LOG_LEVEL")
 	if logLevelStr == "" {
@@ -43,7 +58,12 @@ func main() {
 	log.SetLevel(logLevel)
 	log.SetFormatter(&logger.CustomFormatter{})
 
-	if err := rootCmd.Execute(); err != nil {
+	err = rootCmd.Execute()
+
+	// Keys and other secrets are only needed while the command runs.
+	securemem.WipeAll()
+
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
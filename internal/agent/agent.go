@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// SocketEnvKey overrides where the agent listens. The socket's directory
+// must be private to the current user.
+const SocketEnvKey = "CK_AGENT_SOCK"
+
+const (
+	opAdd  = "add"
+	opGet  = "get"
+	opLock = "lock"
+)
+
+var (
+	ErrNotRunning = errors.New("cryptkeeper agent isn't running")
+	ErrNotFound   = errors.New("key isn't unlocked in the agent")
+
+	ErrUnsafeSocketDir = errors.New("refusing to use the agent socket directory")
+	ErrWrongPeer       = errors.New("agent socket is held by another user")
+	ErrPeerUnsupported = errors.New("the agent can't check who is at the other end of its socket on this platform")
+)
+
+// request is sent by the client, one per connection.
+type request struct {
+	Op  string `json:"op"`
+	ID  string `json:"id,omitempty"`
+	Key []byte `json:"key,omitempty"`
+}
+
+type response struct {
+	Key   []byte `json:"key,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// SocketPath returns where the agent listens: $CK_AGENT_SOCK if set,
+// otherwise a socket in $XDG_RUNTIME_DIR, falling back to a per-user
+// directory in the system temp dir.
+func SocketPath() string {
+	if path, ok := os.LookupEnv(SocketEnvKey); ok && path != "" {
+		return path
+	}
+
+	dir, ok := os.LookupEnv("XDG_RUNTIME_DIR")
+	if !ok || dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("cryptkeeper-%d", os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "cryptkeeper")
+	}
+
+	return filepath.Join(dir, "agent.sock")
+}
+
+// checkPeer refuses a connection whose other end is a process of another
+// user. Both ends check, so keys are only ever exchanged between processes
+// of the current user.
+func checkPeer(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("%w: not a Unix socket", ErrWrongPeer)
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var uid int
+	var uidErr error
+	err = raw.Control(func(fd uintptr) {
+		uid, uidErr = peerUID(int(fd))
+	})
+	if err != nil {
+		return err
+	}
+	if uidErr != nil {
+		return uidErr
+	}
+
+	if uid != os.Getuid() {
+		return fmt.Errorf("%w: uid %d", ErrWrongPeer, uid)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package agent_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/agent"
+)
+
+func startAgent(t *testing.T, idleTimeout time.Duration) {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "agent")
+	assert.NoError(t, os.Mkdir(dir, 0700))
+
+	path := filepath.Join(dir, "agent.sock")
+	t.Setenv(agent.SocketEnvKey, path)
+
+	listener, err := agent.Listen(path)
+	assert.NoError(t, err)
+
+	server := agent.NewServer(idleTimeout)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+	})
+}
+
+func TestAgent(t *testing.T) {
+	assert := assert.New(t)
+
+	startAgent(t, 0)
+	assert.True(agent.Running())
+
+	_, err := agent.Get("key")
+	assert.ErrorIs(err, agent.ErrNotFound)
+
+	assert.NoError(agent.Add("key", []byte("secret")))
+
+	key, err := agent.Get("key")
+	assert.NoError(err)
+	assert.Equal([]byte("secret"), key)
+
+	assert.NoError(agent.Lock())
+
+	_, err = agent.Get("key")
+	assert.ErrorIs(err, agent.ErrNotFound)
+}
+
+func TestAgentIdleTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	startAgent(t, 50*time.Millisecond)
+	assert.NoError(agent.Add("key", []byte("secret")))
+
+	assert.Eventually(func() bool {
+		_, err := agent.Get("key")
+		return err != nil
+	}, time.Second, 100*time.Millisecond)
+}
+
+func TestAgentNotRunning(t *testing.T) {
+	t.Setenv(agent.SocketEnvKey, filepath.Join(t.TempDir(), "agent.sock"))
+
+	assert.False(t, agent.Running())
+
+	_, err := agent.Get("key")
+	assert.ErrorIs(t, err, agent.ErrNotRunning)
+}
+
+func TestListenUnsafeDir(t *testing.T) {
+	assert := assert.New(t)
+
+	shared := filepath.Join(t.TempDir(), "shared")
+	assert.NoError(os.Mkdir(shared, 0700))
+	assert.NoError(os.Chmod(shared, 0755))
+
+	_, err := agent.Listen(filepath.Join(shared, "agent.sock"))
+	assert.ErrorIs(err, agent.ErrUnsafeSocketDir)
+
+	private := filepath.Join(t.TempDir(), "private")
+	assert.NoError(os.Mkdir(private, 0700))
+	link := filepath.Join(t.TempDir(), "link")
+	assert.NoError(os.Symlink(private, link))
+
+	_, err = agent.Listen(filepath.Join(link, "agent.sock"))
+	assert.ErrorIs(err, agent.ErrUnsafeSocketDir)
+}
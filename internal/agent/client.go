@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+)
+
+// dialTimeout keeps commands snappy when a stale socket is left behind.
+const dialTimeout = 500 * time.Millisecond
+
+// Get returns the unlocked key stored under id.
+func Get(id string) ([]byte, error) {
+	resp, err := call(request{Op: opGet, ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Key, nil
+}
+
+// Add stores an unlocked key under id.
+func Add(id string, key []byte) error {
+	_, err := call(request{Op: opAdd, ID: id, Key: key})
+	return err
+}
+
+// Lock wipes every key held by the agent.
+func Lock() error {
+	_, err := call(request{Op: opLock})
+	return err
+}
+
+// Running reports whether an agent is listening on SocketPath.
+func Running() bool {
+	return running(SocketPath())
+}
+
+func running(path string) bool {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+func call(req request) (*response, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), dialTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+
+	// Keys are sent to and read from the agent, so it must be ours.
+	err = checkPeer(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(response)
+	err = json.NewDecoder(conn).Decode(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Error {
+	case "":
+		return resp, nil
+	case ErrNotFound.Error():
+		return nil, ErrNotFound
+	default:
+		return nil, errors.New(resp.Error)
+	}
+}
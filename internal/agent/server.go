@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+// Server holds unlocked keys in locked memory and hands them out over a
+// Unix socket. Keys are wiped after the idle timeout passes without any
+// request.
+type Server struct {
+	idleTimeout time.Duration
+
+	mu    sync.Mutex
+	keys  map[string]*securemem.Buffer
+	timer *time.Timer
+}
+
+func NewServer(idleTimeout time.Duration) *Server {
+	return &Server{
+		idleTimeout: idleTimeout,
+		keys:        make(map[string]*securemem.Buffer),
+	}
+}
+
+// Listen creates the socket at path. The directory and socket are only
+// accessible by the current user, and an existing directory is refused
+// unless it already is.
+func Listen(path string) (net.Listener, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if running(path) {
+		return nil, fmt.Errorf("an agent is already listening on %s", path)
+	}
+
+	// Clean up a socket left behind by an agent that didn't shut down.
+	_ = os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Serve answers requests until the listener is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		go s.handle(conn)
+	}
+}
+
+// Lock wipes every key.
+func (s *Server) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lock()
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	err := checkPeer(conn)
+	if err != nil {
+		logrus.WithError(err).Debug("refused agent connection")
+		return
+	}
+
+	req := new(request)
+	err = json.NewDecoder(conn).Decode(req)
+	if err != nil {
+		logrus.WithError(err).Debug("failed to read agent request")
+		return
+	}
+
+	resp := s.do(req)
+	securemem.Wipe(req.Key)
+
+	err = json.NewEncoder(conn).Encode(resp)
+	securemem.Wipe(resp.Key)
+	if err != nil {
+		logrus.WithError(err).Debug("failed to write agent response")
+	}
+}
+
+func (s *Server) do(req *request) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.touch()
+
+	switch req.Op {
+	case opAdd:
+		key, err := securemem.New(req.Key)
+		if err != nil {
+			return &response{Error: fmt.Sprintf("failed to lock key in memory: %s", err)}
+		}
+
+		if old, ok := s.keys[req.ID]; ok {
+			old.Destroy()
+		}
+		s.keys[req.ID] = key
+
+		return &response{}
+	case opGet:
+		key, ok := s.keys[req.ID]
+		if !ok {
+			return &response{Error: ErrNotFound.Error()}
+		}
+
+		// The key can be wiped by lock or the idle timer as soon as s.mu is
+		// released, before the response is written, so it's sent a copy.
+		return &response{Key: bytes.Clone(key.Bytes())}
+	case opLock:
+		s.lock()
+		return &response{}
+	default:
+		return &response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+	}
+}
+
+// touch restarts the idle timer. s.mu must be held.
+func (s *Server) touch() {
+	if s.idleTimeout <= 0 {
+		return
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	s.timer = time.AfterFunc(s.idleTimeout, func() {
+		logrus.Debug("agent idle timeout reached, locking")
+		s.Lock()
+	})
+}
+
+// lock wipes every key. s.mu must be held.
+func (s *Server) lock() {
+	for id, key := range s.keys {
+		key.Destroy()
+		delete(s.keys, id)
+	}
+}
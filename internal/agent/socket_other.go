@@ -0,0 +1,9 @@
+//go:build !unix
+
+package agent
+
+// checkDir refuses every socket directory. The agent's socket can't be
+// protected on this platform.
+func checkDir(dir string) error {
+	return ErrPeerUnsupported
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkDir refuses a socket directory that another user could have created
+// or could write to, since whoever listens in it is handed unlocked keys.
+func checkDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%w: %s is a symlink", ErrUnsafeSocketDir, dir)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: %s isn't a directory", ErrUnsafeSocketDir, dir)
+	}
+
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: %s isn't owned by the current user", ErrUnsafeSocketDir, dir)
+	}
+	if fi.Mode().Perm() != 0700 {
+		return fmt.Errorf("%w: %s has mode %#o, expected 0700", ErrUnsafeSocketDir, dir, fi.Mode().Perm())
+	}
+
+	return nil
+}
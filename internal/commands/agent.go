@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/sunny-b/cryptkeeper/internal/agent"
+	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+var agentTimeout time.Duration
+
+var Agent = &cobra.Command{
+	Use:   "agent",
+	Short: "Hold unlocked keys in memory so the passphrase is only entered once",
+	Long:  "Runs in the foreground and keeps keys unlocked with 'cryptkeeper unlock' in locked memory, so the shell hook doesn't need the passphrase. Keys are wiped after the idle timeout. Start it in the background, for example with 'cryptkeeper agent &'.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := securemem.DisableCoreDumps()
+		if err != nil {
+			logrus.WithError(err).Warn("failed to disable core dumps")
+		}
+
+		path := agent.SocketPath()
+		listener, err := agent.Listen(path)
+		if err != nil {
+			return err
+		}
+
+		server := agent.NewServer(agentTimeout)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			server.Lock()
+			listener.Close()
+		}()
+
+		fmt.Printf("cryptkeeper agent listening on %s\n", path)
+
+		return server.Serve(listener)
+	},
+}
+
+var Unlock = &cobra.Command{
+	Use:   "unlock",
+	Short: "Unlock the key and hand it to the agent",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !agent.Running() {
+			return fmt.Errorf("%w - start it with 'cryptkeeper agent &'", agent.ErrNotRunning)
+		}
+
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+		if crypt.WrapsDataKey(cfg.Encryption.Type) {
+			return crypt.ErrNoKeyFile
+		}
+
+		err = crypt.UnlockInAgent(cfg.Encryption.KeyPath)
+		if errors.Is(err, crypt.ErrKeyUnprotected) {
+			fmt.Printf("Key in %s isn't protected with a passphrase, nothing to unlock\n", cfg.Encryption.KeyPath)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Unlocked key in %s\n", cfg.Encryption.KeyPath)
+
+		return nil
+	},
+}
+
+var Lock = &cobra.Command{
+	Use:   "lock",
+	Short: "Wipe every unlocked key from the agent",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := agent.Lock()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Locked all keys")
+
+		return nil
+	},
+}
+
+func init() {
+	Agent.Flags().DurationVarP(&agentTimeout, "timeout", "t", time.Hour, "Wipe keys after this long without use (0 keeps them until 'cryptkeeper lock')")
+}
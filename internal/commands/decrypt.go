@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/sunny-b/cryptkeeper/internal/config"
-	"github.com/sunny-b/cryptkeeper/internal/crypt"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -22,7 +21,7 @@ var Decrypt = &cobra.Command{
 			return err
 		}
 
-		keeper, err := crypt.NewKeeper(config.Encryption.Type, config.Encryption.KeyPath)
+		keeper, err := config.Keeper()
 		if err != nil {
 			return err
 		}
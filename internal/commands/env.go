@@ -35,6 +35,7 @@ var Env = &cobra.Command{
 			if (errors.Is(err, fileutils.ErrFileNotFound) || errors.As(err, pathErr)) && envVarExists(config.CKWatchEnvKey) && envVarExists(config.CKRevertEnvKey) {
 				fmt.Print(unloadDiff(config.CKRevertEnvKey, sh))
 			}
+			logIntegrityError(err)
 			return
 		}
 
@@ -55,7 +56,7 @@ var Env = &cobra.Command{
 			return
 		}
 
-		keeper, err := crypt.NewKeeper(cfg.Encryption.Type, cfg.Encryption.KeyPath)
+		keeper, err := cfg.Keeper()
 		if err != nil {
 			return
 		}
@@ -79,8 +80,10 @@ var Env = &cobra.Command{
 
 		lastEnv, err := envdiff.FetchLastEnv(config.CKLastEnvKey, keeper)
 		if err != nil {
+			// The key may have been rotated since the last export, in which
+			// case we start over and export everything again.
 			log.WithError(err).Debug("failed to fetch last env")
-			return
+			lastEnv = make(config.Env)
 		}
 
 		revertEnv, err := envdiff.FetchRevert(config.CKRevertEnvKey)
@@ -166,6 +169,14 @@ var Env = &cobra.Command{
 	},
 }
 
+// logIntegrityError tells the user why the hidden commands didn't export
+// anything when the config fails its integrity check.
+func logIntegrityError(err error) {
+	if errors.Is(err, config.ErrIntegrity) || errors.Is(err, config.ErrIntegrityMissing) || errors.Is(err, config.ErrUnsealed) {
+		log.WithError(err).Error("cryptkeeper: refusing to load secrets")
+	}
+}
+
 func envVarExists(envKey string) bool {
 	_, ok := os.LookupEnv(envKey)
 	return ok
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/config"
+)
+
+func TestLogIntegrityError(t *testing.T) {
+	hook := test.NewGlobal()
+	t.Cleanup(func() { logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks)) })
+
+	tests := []struct {
+		err    error
+		logged bool
+	}{
+		{config.ErrIntegrity, true},
+		{config.ErrIntegrityMissing, true},
+		{config.ErrUnsealed, true},
+		{errors.New("failed to read config file"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			hook.Reset()
+
+			logIntegrityError(fmt.Errorf(".ckrc: %w", tt.err))
+			if !tt.logged {
+				assert.Empty(t, hook.AllEntries())
+				return
+			}
+
+			entry := hook.LastEntry()
+			if assert.NotNil(t, entry) {
+				assert.Equal(t, logrus.ErrorLevel, entry.Level)
+				assert.ErrorIs(t, entry.Data[logrus.ErrorKey].(error), tt.err)
+			}
+		})
+	}
+}
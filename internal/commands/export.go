@@ -7,7 +7,6 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/sunny-b/cryptkeeper/internal/config"
-	"github.com/sunny-b/cryptkeeper/internal/crypt"
 	"github.com/sunny-b/cryptkeeper/internal/shell"
 )
 
@@ -20,6 +19,7 @@ var Export = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg, err := config.GetConfig()
 		if err != nil {
+			logIntegrityError(err)
 			return
 		}
 
@@ -31,7 +31,7 @@ var Export = &cobra.Command{
 			sh = shell.Bash
 		}
 
-		keeper, err := crypt.NewKeeper(cfg.Encryption.Type, cfg.Encryption.KeyPath)
+		keeper, err := cfg.Keeper()
 		if err != nil {
 			return
 		}
@@ -2,15 +2,20 @@ package commands
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/sunny-b/cryptkeeper/internal/config"
 	"github.com/sunny-b/cryptkeeper/internal/config/direnv"
 	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/sshkey"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/vault"
 	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+	"github.com/sunny-b/cryptkeeper/internal/passphrase"
 	"github.com/sunny-b/cryptkeeper/internal/shell"
 )
 
@@ -18,53 +23,193 @@ var (
 	encryption string
 	keyPath    string
 	standalone bool
+	protectKey bool
+	keySize    int
+	recipients []string
+	initSSH    bool
+	initSSHKey string
+	padding    string
+
+	pkcs11Slot  uint
+	pkcs11Label string
+
+	vaultAddrEnv  string
+	vaultMount    string
+	vaultKey      string
+	vaultTokenEnv string
+
+	keyProvider        string
+	keyProviderOptions map[string]string
 )
 
 var Init = &cobra.Command{
 	Use:       "init",
 	Short:     "Initialize cryptkeeper",
+	Long:      "Initializes cryptkeeper in the current directory, generating a key file and writing the config.\n\n" + deterministicHelp,
 	Args:      cobra.ExactArgs(1),
 	ValidArgs: []string{"bash", "zsh", "fish"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		keyPath = fileutils.Clean(keyPath)
 		configPath := fileutils.Clean(config.FileName())
 
+		encType, err := crypt.ParseEncryptionType(encryption)
+		if err != nil {
+			return err
+		}
+
+		if len(recipients) > 0 && encType != crypt.Age {
+			return fmt.Errorf("--recipient is only supported with '-e %s'", crypt.Age)
+		}
+
+		// pkcs11, vault-transit and keyprovider projects keep their key in
+		// an HSM, Vault or key provider, so they have no key file. Their
+		// data key is wrapped by it and stored in the config.
+		wrapsDataKey := crypt.WrapsDataKey(encType)
+		if wrapsDataKey && (protectKey || initSSH || initSSHKey != "") {
+			return fmt.Errorf("--passphrase and --ssh don't apply to '-e %s', the key never leaves the HSM, Vault or key provider", encType)
+		}
+		if encType != crypt.PKCS11 && pkcs11Label != "" {
+			return fmt.Errorf("--pkcs11-label is only supported with '-e %s'", crypt.PKCS11)
+		}
+		if encType != crypt.VaultTransit && (vaultKey != "" || vaultAddrEnv != "" || vaultTokenEnv != "") {
+			return fmt.Errorf("--vault-key, --vault-addr-env and --vault-token-env are only supported with '-e %s'", crypt.VaultTransit)
+		}
+		if encType != crypt.KeyProvider && (keyProvider != "" || len(keyProviderOptions) > 0) {
+			return fmt.Errorf("--key-provider and --key-provider-option are only supported with '-e %s'", crypt.KeyProvider)
+		}
+
+		// age projects can use an existing identity, such as an SSH key,
+		// instead of generating a new key file.
+		existingKey := encType == crypt.Age && fileutils.FileExists(keyPath)
+
 		// Guard against overwriting key or config that already exist.
-		if fileutils.FileExists(keyPath) {
+		if fileutils.FileExists(keyPath) && !existingKey && !wrapsDataKey {
 			return fmt.Errorf("key file already exists at %s", keyPath)
 		}
 		if fileutils.FileExists(configPath) {
 			return fmt.Errorf("config file already exists at %s", fileutils.Clean(config.FileName()))
 		}
 
-		var encType crypt.EncryptionType
-		var err error
-		switch strings.ToLower(encryption) {
-		case "aes", "aes256", "aes-256":
-			encType = crypt.AES256
-		case "rsa", "rsa2048", "rsa-2048":
-			encType = crypt.RSA2048
-		case "ecc", "ecc256", "ecc-256":
-			encType = crypt.ECC256
-		case "serpent", "serpent256", "serpent-256":
-			encType = crypt.Serpent256
-		default:
-			return crypt.ErrUnknownEncryptionType
+		pad, err := crypt.ParsePadding(padding)
+		if err != nil {
+			return err
+		}
+		if pad != nil && encType == crypt.Age {
+			return fmt.Errorf("--padding isn't supported with '-e %s', the age CLI wouldn't strip it", crypt.Age)
 		}
 
-		err = crypt.GenerateKeys(encType, keyPath)
+		var team config.Recipients
+		for _, r := range recipients {
+			recipient, err := config.ParseRecipient(encType, r)
+			if err != nil {
+				return err
+			}
+			if team.Find(recipient.Key) >= 0 {
+				continue
+			}
+
+			team = append(team, recipient)
+		}
+
+		var opts []crypt.Option
+		if keySize != 0 {
+			encType, err = crypt.ResizeEncryptionType(encType, keySize)
+			if err != nil {
+				return err
+			}
+
+			opts = append(opts, crypt.WithKeySize(keySize))
+		}
+
+		projectID, err := crypt.NewProjectID()
 		if err != nil {
 			return err
 		}
 
+		var sshKey string
+		if initSSH || initSSHKey != "" {
+			if protectKey {
+				return errors.New("--passphrase and --ssh can't be combined, the key file is protected with one or the other")
+			}
+			if existingKey {
+				return fmt.Errorf("--ssh only applies to generated keys, %s already exists", keyPath)
+			}
+
+			sshKey, err = sshkey.Resolve(initSSHKey)
+			if err != nil {
+				return err
+			}
+
+			opts = append(opts, crypt.WithSSHKey(sshKey))
+		}
+
+		if protectKey {
+			if existingKey {
+				return fmt.Errorf("--passphrase only applies to generated keys, %s already exists", keyPath)
+			}
+
+			opts = append(opts, crypt.WithPassphrase(passphrase.New))
+		}
+
+		var hsmKey *crypt.PKCS11Key
+		var transitKey *crypt.VaultTransitKey
+		var providerKey *crypt.KeyProviderKey
+		switch {
+		case encType == crypt.PKCS11:
+			hsmKey = &crypt.PKCS11Key{Module: os.Getenv(crypt.PKCS11ModuleEnvVar), Slot: pkcs11Slot, KeyLabel: pkcs11Label}
+			err = crypt.NewPKCS11Key(hsmKey)
+			if err != nil {
+				return err
+			}
+
+			keyPath = ""
+		case encType == crypt.VaultTransit:
+			transitKey = &crypt.VaultTransitKey{AddressEnv: vaultAddrEnv, Mount: vaultMount, KeyName: vaultKey, TokenEnv: vaultTokenEnv}
+			err = crypt.NewVaultTransitKey(transitKey)
+			if err != nil {
+				return err
+			}
+
+			keyPath = ""
+		case encType == crypt.KeyProvider:
+			providerKey = &crypt.KeyProviderKey{Name: keyProvider, Options: keyProviderOptions}
+			err = crypt.NewKeyProviderKey(providerKey)
+			if err != nil {
+				return err
+			}
+
+			keyPath = ""
+		case !existingKey:
+			err = crypt.GenerateKeys(encType, keyPath, opts...)
+			if err != nil {
+				return err
+			}
+		}
+
 		cfg := &config.Config{
 			Encryption: config.Encryption{
-				KeyPath: keyPath,
-				Type:    encType,
+				KeyPath:      keyPath,
+				Type:         encType,
+				ProjectID:    projectID,
+				SSHKey:       sshKey,
+				Padding:      pad,
+				PKCS11:       hsmKey,
+				VaultTransit: transitKey,
+				KeyProvider:  providerKey,
 			},
 			Env:  make(config.Env),
 			Path: configPath,
 		}
+		if !existingKey {
+			cfg.Encryption.Created = time.Now().UTC().Truncate(time.Second)
+		}
+
+		if crypt.HasRecipients(encType) {
+			err = startTeam(cfg, team, opts...)
+			if err != nil {
+				return err
+			}
+		}
 
 		envrcPath := direnv.EnvrcPath()
 		sh := shell.Detect(args[0])
@@ -107,7 +252,19 @@ var Init = &cobra.Command{
 			return fmt.Errorf("error writing config: %w", err)
 		}
 
-		fmt.Printf("Initialized config in %s and key in %s\n", fileutils.Clean(config.FileName()), keyPath)
+		switch {
+		case wrapsDataKey:
+			keeper, err := cfg.Keeper()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Initialized config in %s with a data key wrapped by %s\n", fileutils.Clean(config.FileName()), keeper.KeySource())
+		case existingKey:
+			fmt.Printf("Initialized config in %s using the existing key in %s\n", fileutils.Clean(config.FileName()), keyPath)
+		default:
+			fmt.Printf("Initialized config in %s and key in %s\n", fileutils.Clean(config.FileName()), keyPath)
+		}
 
 		return nil
 	},
@@ -117,8 +274,34 @@ func init() {
 	Init.Flags().StringVarP(&encryption, "encryption", "e", "aes256", "Type of encryption to use for encrypting/decrypting the secrets")
 	Init.Flags().StringVarP(&keyPath, "key-path", "k", config.KeyFileName(), "File path to output generated encryption key")
 	Init.Flags().BoolVarP(&standalone, "standalone", "s", false, "Run in standalone mode")
+	Init.Flags().BoolVarP(&protectKey, "passphrase", "p", false, "Protect the generated key file with a passphrase")
+	Init.Flags().BoolVar(&initSSH, "ssh", false, "Protect the generated key file with the first ssh-ed25519 or ssh-rsa key in ssh-agent or ~/.ssh")
+	Init.Flags().StringVar(&initSSHKey, "ssh-key", "", "Protect the generated key file with this SSH key, given as a fingerprint or the path to its public or private key (implies --ssh)")
+	Init.Flags().StringArrayVarP(&recipients, "recipient", "r", nil, "Public key to encrypt the secrets to with '-e age', as an age or SSH public key with an optional name; may be repeated")
+	Init.Flags().StringVar(&padding, "padding", "none", "Pad secrets before encrypting them to hide their length: a block size in bytes, 'pow2' for the next power of two, or 'none'")
+	Init.Flags().UintVar(&pkcs11Slot, "pkcs11-slot", 0, "Slot of the token holding the key with '-e pkcs11'. The token's module is read from $CK_PKCS11_MODULE, e.g. /usr/lib/softhsm/libsofthsm2.so, and recorded in the config for machines that don't set it")
+	Init.Flags().StringVar(&pkcs11Label, "pkcs11-label", "", "Label of the AES key on the token that wraps the data key with '-e pkcs11'")
+	Init.Flags().StringVar(&vaultMount, "vault-mount", vault.DefaultMount, "Path the transit engine is mounted at with '-e vault-transit'")
+	Init.Flags().StringVar(&vaultKey, "vault-key", "", "Name of the transit key that wraps the data key with '-e vault-transit'. The Vault server and token are read from $VAULT_ADDR and $VAULT_TOKEN or ~/.vault-token")
+	Init.Flags().StringVar(&vaultAddrEnv, "vault-addr-env", "", "Environment variable to read the Vault server from instead of $VAULT_ADDR with '-e vault-transit'. Its name must start with VAULT_")
+	Init.Flags().StringVar(&vaultTokenEnv, "vault-token-env", "", "Environment variable to read the Vault token from instead of $VAULT_TOKEN or ~/.vault-token with '-e vault-transit'. Its name must start with VAULT_")
+	Init.Flags().StringVar(&keyProvider, "key-provider", "", "Name of the key provider that wraps the data key with '-e keyprovider', run as cryptkeeper-keyprovider-<name> from $PATH")
+	Init.Flags().StringToStringVar(&keyProviderOptions, "key-provider-option", nil, "Option sent to the key provider with '-e keyprovider', as key=value, e.g. entry=cryptkeeper/project for the pass provider")
+	Init.Flags().IntVar(&keySize, "key-size", 0, "Size of the generated key in bits, e.g. '-e rsa --key-size 4096' (default depends on the encryption type)")
 }
 
+// deterministicHelp explains what aes256-siv gives up, for the commands
+// that pick an encryption type.
+const deterministicHelp = `Every encryption type except aes256-siv picks a random nonce for each value,
+so re-running 'set' or 'reencrypt' rewrites every value in .ckrc even when
+nothing changed. aes256-siv is deterministic: the same key, secret name and
+value always give the same ciphertext, so diffs of .ckrc only show the
+secrets that actually changed. The tradeoff is that it leaks equality:
+anyone who can read .ckrc, or its git history, can tell when a secret is
+set back to a value it had before, or that it didn't change, without
+decrypting anything. Equal values of different secrets or projects still
+look different.`
+
 func promptUserf(prompt string, args ...any) string {
 	fmt.Printf(prompt, args...)
 
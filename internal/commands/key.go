@@ -0,0 +1,547 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/sshkey"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+	"github.com/sunny-b/cryptkeeper/internal/passphrase"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+var Key = &cobra.Command{
+	Use:   "key",
+	Short: "Manage the encryption key",
+}
+
+var (
+	protectWithSSH bool
+	protectSSHKey  string
+)
+
+var KeyProtect = &cobra.Command{
+	Use:   "protect",
+	Short: "Protect the key file with a passphrase or SSH key",
+	Long:  "Encrypts the key file with a key derived from a passphrase using Argon2id. The passphrase is read from CK_PASSPHRASE or prompted for whenever the key is needed.\n\nWith --ssh, the key file is encrypted with a key derived from a signature by an SSH key instead, so whoever holds the SSH key in ssh-agent or ~/.ssh can use it without a passphrase. Only ssh-ed25519 and ssh-rsa keys are supported. The SSH key's fingerprint is recorded in .ckrc.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+		if crypt.WrapsDataKey(cfg.Encryption.Type) {
+			return crypt.ErrNoKeyFile
+		}
+
+		if protectWithSSH || protectSSHKey != "" {
+			fingerprint, err := sshkey.Resolve(protectSSHKey)
+			if err != nil {
+				return err
+			}
+
+			err = crypt.ProtectKeyWithSSH(cfg.Encryption.KeyPath, fingerprint)
+			if err != nil {
+				return err
+			}
+
+			cfg.Encryption.SSHKey = fingerprint
+			err = config.Write(cfg)
+			if err != nil {
+				return fmt.Errorf("error writing config: %w", err)
+			}
+
+			fmt.Printf("Protected key in %s with SSH key %s\n", cfg.Encryption.KeyPath, fingerprint)
+
+			return nil
+		}
+
+		pass, err := passphrase.New()
+		if err != nil {
+			return err
+		}
+
+		err = crypt.ProtectKey(cfg.Encryption.KeyPath, pass)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Protected key in %s with a passphrase\n", cfg.Encryption.KeyPath)
+
+		return nil
+	},
+}
+
+var KeyUnprotect = &cobra.Command{
+	Use:   "unprotect",
+	Short: "Remove the passphrase or SSH key protection from the key file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+		if crypt.WrapsDataKey(cfg.Encryption.Type) {
+			return crypt.ErrNoKeyFile
+		}
+
+		err = crypt.UnprotectKey(cfg.Encryption.KeyPath)
+		if err != nil {
+			return err
+		}
+
+		if cfg.Encryption.SSHKey != "" {
+			cfg.Encryption.SSHKey = ""
+			err = config.Write(cfg)
+			if err != nil {
+				return fmt.Errorf("error writing config: %w", err)
+			}
+
+			fmt.Printf("Removed the SSH key protection from the key in %s\n", cfg.Encryption.KeyPath)
+
+			return nil
+		}
+
+		fmt.Printf("Removed the passphrase from the key in %s\n", cfg.Encryption.KeyPath)
+
+		return nil
+	},
+}
+
+const publicKeySuffix = ".pub"
+
+var publicKeyPath string
+
+var KeyPublic = &cobra.Command{
+	Use:   "public",
+	Short: "Write a key file holding only the public key",
+	Long:  "Writes the public half of an ecc256 key to a separate key file. Point key_path at it on machines, such as CI jobs, that should be able to add secrets with 'cryptkeeper set' but never read them. Configs written with only the public key can't be integrity checked until they're written again with the private key.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		path := publicKeyPath
+		if path == "" {
+			path = cfg.Encryption.KeyPath + publicKeySuffix
+		}
+
+		keeper, err := cfg.Keeper()
+		if err != nil {
+			return err
+		}
+
+		err = keeper.WritePublicKey(path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote the public key to %s\n", path)
+
+		return nil
+	},
+}
+
+var (
+	splitShares    int
+	splitThreshold int
+	combineKeyPath string
+)
+
+var KeySplit = &cobra.Command{
+	Use:   "split",
+	Short: "Split the key into shares for backup",
+	Long:  "Splits the key into --shares printable shares using Shamir's secret sharing, one per line. Any --threshold of them rebuild the key with 'cryptkeeper key combine', and fewer reveal nothing about it. Each share carries a checksum and the key's fingerprint, so mistyped shares and shares of other keys are caught.\n\nThe shares hold the plain key, even if the key file is protected. Keep them apart.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		keeper, err := cfg.Keeper()
+		if err != nil {
+			return err
+		}
+
+		shares, err := keeper.SplitKey(splitShares, splitThreshold)
+		if err != nil {
+			return err
+		}
+
+		for _, share := range shares {
+			fmt.Println(share)
+		}
+
+		fmt.Fprintf(os.Stderr, "Split the key in %s into %d shares, any %d of which rebuild it\n", keeper.KeySource(), splitShares, splitThreshold)
+
+		return nil
+	},
+}
+
+var KeyCombine = &cobra.Command{
+	Use:   "combine [share]...",
+	Short: "Rebuild the key from shares",
+	Long:  "Rebuilds a key file from shares written by 'cryptkeeper key split'. Shares are given as arguments, or read from stdin one per line. The rebuilt key file isn't protected - run 'cryptkeeper key protect' to protect it again.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyPath := fileutils.Clean(combineKeyPath)
+		if fileutils.FileExists(keyPath) {
+			return fmt.Errorf("key file already exists at %s", keyPath)
+		}
+
+		shares := args
+		if len(shares) == 0 {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				if line := strings.TrimSpace(scanner.Text()); line != "" {
+					shares = append(shares, line)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("error reading shares: %w", err)
+			}
+		}
+
+		encType, fingerprint, err := crypt.CombineKey(shares, keyPath)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Rebuilt %s key %s in %s\n", encType, fingerprint, keyPath)
+		fmt.Println("The key file isn't protected - run 'cryptkeeper key protect' to protect it.")
+
+		return nil
+	},
+}
+
+var (
+	exportMnemonic   bool
+	importMnemonic   bool
+	importEncryption string
+	importKeyPath    string
+)
+
+var KeyExport = &cobra.Command{
+	Use:   "export",
+	Short: "Print the key for a backup",
+	Long:  "Prints the base64 of the plain key file, which 'cryptkeeper key import' reads back in. With --mnemonic, prints an aes256 or serpent256 key as a 24-word BIP39 mnemonic instead, which can be written down and typed back in with 'cryptkeeper key import --mnemonic'. Either is the plain key, even if the key file is protected.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		keeper, err := cfg.Keeper()
+		if err != nil {
+			return err
+		}
+
+		if !exportMnemonic {
+			b, err := keeper.PlainKeyFile()
+			if err != nil {
+				return err
+			}
+			defer securemem.Wipe(b)
+
+			fmt.Println(base64.StdEncoding.EncodeToString(b))
+
+			return nil
+		}
+
+		words, err := keeper.Mnemonic()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(words)
+
+		return nil
+	},
+}
+
+var KeyImport = &cobra.Command{
+	Use:   "import [key]",
+	Short: "Recreate the key from a backup",
+	Long:  "Recreates a key file from the base64 printed by 'cryptkeeper key export'. With --mnemonic, recreates it from the mnemonic printed by 'cryptkeeper key export --mnemonic' instead: words may be shortened to their first four letters, and the mnemonic's checksum catches most mistyped or missing words. The key or words are given as arguments, or read from stdin. The key file isn't protected - run 'cryptkeeper key protect' to protect it.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		encType, err := crypt.ParseEncryptionType(importEncryption)
+		if err != nil {
+			return err
+		}
+
+		input := strings.Join(args, " ")
+		if len(args) == 0 {
+			if !isInputPiped() {
+				if importMnemonic {
+					fmt.Fprintln(os.Stderr, "Enter the mnemonic, then press Ctrl-D:")
+				} else {
+					fmt.Fprintln(os.Stderr, "Enter the key, then press Ctrl-D:")
+				}
+			}
+
+			b, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("error reading key: %w", err)
+			}
+
+			input = string(b)
+		}
+
+		keyPath := fileutils.Clean(importKeyPath)
+
+		var fingerprint string
+		if importMnemonic {
+			fingerprint, err = crypt.ImportMnemonic(encType, input, keyPath)
+		} else {
+			var b []byte
+			b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(input))
+			if err != nil {
+				return fmt.Errorf("key isn't valid base64: %w", err)
+			}
+
+			fingerprint, err = crypt.ImportKeyFile(encType, b, keyPath)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Imported %s key %s into %s\n", encType, fingerprint, keyPath)
+		fmt.Println("The key file isn't protected - run 'cryptkeeper key protect' to protect it.")
+
+		return nil
+	},
+}
+
+var KeyInfo = &cobra.Command{
+	Use:   "info",
+	Short: "Show the key's algorithm, fingerprint, creation date and file permissions",
+	Long:  "Shows what the key file holds and how it's stored. Two key files hold the same key if and only if their fingerprints match, so compare fingerprints rather than key files to check that teammates or machines have the right key.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		keeper, err := cfg.Keeper()
+		if err != nil {
+			return err
+		}
+
+		fingerprint, err := keeper.Fingerprint()
+		if err != nil {
+			return err
+		}
+		if fingerprint == "" {
+			fingerprint = "none - the key file is from before key pairs, it gets one the next time a secret is added"
+		}
+
+		created := "unknown"
+		if !cfg.Encryption.Created.IsZero() {
+			created = cfg.Encryption.Created.Local().Format(time.RFC1123)
+		}
+
+		fmt.Printf("Algorithm:   %s\n", cfg.Encryption.Type)
+		fmt.Printf("Fingerprint: %s\n", fingerprint)
+		fmt.Printf("Created:     %s\n", created)
+
+		keyPath := keeper.KeySource()
+		if keyPath != cfg.Encryption.KeyPath && !fileutils.FileExists(keyPath) {
+			fmt.Printf("Key source:  %s\n", keyPath)
+			return nil
+		}
+
+		info, err := fileutils.Stat(keyPath)
+		if err != nil {
+			return err
+		}
+
+		protection, err := keyProtection(keyPath)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Key file:    %s\n", keyPath)
+		fmt.Printf("Permissions: %s\n", info.Mode().Perm())
+		fmt.Printf("Protection:  %s\n", protection)
+
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+			logrus.Warnf("%s can be read by other users - run 'chmod 600 %s'", keyPath, keyPath)
+		}
+
+		return nil
+	},
+}
+
+var KeyStore = &cobra.Command{
+	Use:   "store",
+	Short: "Move the key into your per-user key store",
+	Long:  "Moves the key file into the key store at $XDG_DATA_HOME/cryptkeeper/keys (~/.local/share/cryptkeeper/keys by default), named after its fingerprint, and drops its path from the config. cryptkeeper finds the key there by the fingerprint in the config, so the config can be committed while each developer keeps their copy of the key outside the repository.\n\nProjects with recipients can't use the key store, since every member has a key of their own.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if crypt.KeySupplied() {
+			return crypt.ErrSuppliedKey
+		}
+
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		if crypt.WrapsDataKey(cfg.Encryption.Type) {
+			return crypt.ErrNoKeyFile
+		}
+
+		fingerprint := cfg.Encryption.Fingerprint
+		if fingerprint == "" {
+			return fmt.Errorf("the config doesn't record the key's fingerprint, which the key store finds keys by - projects with recipients can't use the key store, and older key files get a fingerprint the next time a secret is added")
+		}
+
+		keyPath := cfg.Encryption.KeyPath
+		if crypt.InKeyStore(keyPath) {
+			fmt.Printf("The key is already in the key store at %s\n", keyPath)
+			return nil
+		}
+
+		// Loading the keeper checks the key file holds the key the config
+		// expects before it's moved anywhere.
+		if _, err := cfg.Keeper(); err != nil {
+			return err
+		}
+
+		storedPath, err := crypt.StoreKey(keyPath, fingerprint)
+		if err != nil {
+			return err
+		}
+
+		cfg.Encryption.KeyPath = storedPath
+		err = config.Write(cfg)
+		if err != nil {
+			return fmt.Errorf("moved the key to %s but failed to update the config: %w", storedPath, err)
+		}
+
+		fmt.Printf("Moved the key to %s\n", storedPath)
+		fmt.Printf("%s no longer names a key file and can be committed. Teammates run 'cryptkeeper key store' with their own copy of the key.\n", cfg.Path)
+
+		return nil
+	},
+}
+
+var KeyPrintCI = &cobra.Command{
+	Use:   "print-ci",
+	Short: "Print the key in the form CI secrets take",
+	Long:  "Prints the base64 of the plain key file. Store it in a masked CI secret exposed to jobs as " + crypt.KeyEnvVar + ", and every cryptkeeper command uses it instead of the key file. The value is the plain key, even if the key file is protected.\n\nAlternatively, point " + crypt.KeyFileEnvVar + " at a key file, or pass the key file on an inherited file descriptor with --key-fd.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		keeper, err := cfg.Keeper()
+		if err != nil {
+			return err
+		}
+
+		b, err := keeper.PlainKeyFile()
+		if err != nil {
+			return err
+		}
+		defer securemem.Wipe(b)
+
+		fmt.Println(base64.StdEncoding.EncodeToString(b))
+		fmt.Fprintf(os.Stderr, "Store this in a masked CI secret named %s\n", crypt.KeyEnvVar)
+
+		return nil
+	},
+}
+
+func init() {
+	KeyProtect.Flags().BoolVar(&protectWithSSH, "ssh", false, "Protect the key file with the first ssh-ed25519 or ssh-rsa key in ssh-agent or ~/.ssh instead of a passphrase")
+	KeyProtect.Flags().StringVar(&protectSSHKey, "ssh-key", "", "Protect the key file with this SSH key, given as a fingerprint or the path to its public or private key (implies --ssh)")
+	KeyPublic.Flags().StringVarP(&publicKeyPath, "output", "o", "", "File path to write the public key to (default is the key path with .pub appended)")
+	KeySplit.Flags().IntVarP(&splitShares, "shares", "n", 5, "Number of shares to split the key into")
+	KeySplit.Flags().IntVarP(&splitThreshold, "threshold", "t", 3, "Number of shares needed to rebuild the key")
+	KeyCombine.Flags().StringVarP(&combineKeyPath, "key-path", "k", config.KeyFileName(), "File path to write the rebuilt key to")
+	KeyExport.Flags().BoolVar(&exportMnemonic, "mnemonic", false, "Print the key as a 24-word mnemonic instead of base64")
+	KeyImport.Flags().BoolVar(&importMnemonic, "mnemonic", false, "Read the key as a 24-word mnemonic instead of base64")
+	KeyImport.Flags().StringVarP(&importEncryption, "encryption", "e", "aes256", "Type of encryption the key is for")
+	KeyImport.Flags().StringVarP(&importKeyPath, "key-path", "k", config.KeyFileName(), "File path to write the imported key to")
+
+	Key.AddCommand(KeyProtect)
+	Key.AddCommand(KeyUnprotect)
+	Key.AddCommand(KeyPublic)
+	Key.AddCommand(KeyInfo)
+	Key.AddCommand(KeyPrintCI)
+	Key.AddCommand(KeyStore)
+	Key.AddCommand(KeySplit)
+	Key.AddCommand(KeyCombine)
+	Key.AddCommand(KeyExport)
+	Key.AddCommand(KeyImport)
+}
+
+// keyOptions returns the options for generating a key to replace the one at
+// keyPath. A protected key is replaced by a key protected the same way.
+func keyOptions(keyPath string) ([]crypt.Option, error) {
+	fingerprint, err := crypt.SSHKeyFingerprint(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != "" {
+		return []crypt.Option{crypt.WithSSHKey(fingerprint)}, nil
+	}
+
+	protected, err := crypt.IsProtected(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !protected {
+		return nil, nil
+	}
+
+	return []crypt.Option{crypt.WithPassphrase(passphrase.New)}, nil
+}
+
+// keyProtection describes how the key file at keyPath is protected.
+func keyProtection(keyPath string) (string, error) {
+	external, err := crypt.IsExternalKey(keyPath)
+	if err != nil {
+		return "", err
+	}
+	if external {
+		return "SSH key managed outside cryptkeeper", nil
+	}
+
+	fingerprint, err := crypt.SSHKeyFingerprint(keyPath)
+	if err != nil {
+		return "", err
+	}
+	if fingerprint != "" {
+		return "SSH key " + fingerprint, nil
+	}
+
+	protected, err := crypt.IsProtected(keyPath)
+	if err != nil {
+		return "", err
+	}
+	if protected {
+		return "passphrase", nil
+	}
+
+	return "none", nil
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/config/direnv"
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+)
+
+var (
+	migrateTo      string
+	migrateKeyPath string
+)
+
+var Migrate = &cobra.Command{
+	Use:   "migrate-encryption",
+	Short: "Re-encrypt every secret with a different encryption type",
+	Long:  "Decrypts every secret with the current key, re-encrypts it with a newly generated key of the target type and updates the config. Nothing is changed if any secret fails to decrypt.\n\n" + deterministicHelp,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		encType, err := crypt.ParseEncryptionType(migrateTo)
+		if err != nil {
+			return err
+		}
+
+		if encType == cfg.Encryption.Type {
+			return fmt.Errorf("secrets are already encrypted with %s - use 'cryptkeeper rotate' to replace the key", encType)
+		}
+		for _, t := range []crypt.EncryptionType{cfg.Encryption.Type, encType} {
+			if crypt.WrapsDataKey(t) {
+				return fmt.Errorf("can't migrate to or from %s, its key never leaves the HSM, Vault or key provider - set the secrets again in a project initialized with the other type", t)
+			}
+		}
+
+		if crypt.KeySupplied() {
+			return crypt.ErrSuppliedKey
+		}
+
+		oldKeeper, err := cfg.Keeper()
+		if err != nil {
+			return fmt.Errorf("failed to load current key: %w", err)
+		}
+
+		oldKeyPath := cfg.Encryption.KeyPath
+		keyPath := oldKeyPath
+
+		external, err := crypt.IsExternalKey(oldKeyPath)
+		if err != nil {
+			return err
+		}
+		if external && migrateKeyPath == "" {
+			return fmt.Errorf("%s is an SSH key managed outside cryptkeeper - choose where to put the new key with --key-path", oldKeyPath)
+		}
+		if migrateKeyPath != "" {
+			keyPath = fileutils.Clean(migrateKeyPath)
+		}
+
+		newKeyPath := keyPath + newKeySuffix
+		backupPath := oldKeyPath + backupKeySuffix
+
+		if err := guardKeyPaths(newKeyPath, backupPath); err != nil {
+			return err
+		}
+		if keyPath != oldKeyPath && fileutils.FileExists(keyPath) || external && keyPath == oldKeyPath {
+			return fmt.Errorf("key file already exists at %s", keyPath)
+		}
+
+		opts, err := keyOptions(oldKeyPath)
+		if err != nil {
+			return err
+		}
+
+		err = crypt.GenerateKeys(encType, newKeyPath, opts...)
+		if err != nil {
+			return err
+		}
+
+		next := *cfg
+		next.Encryption = config.Encryption{
+			Type:      encType,
+			KeyPath:   newKeyPath,
+			ProjectID: cfg.Encryption.ProjectID,
+			SSHKey:    cfg.Encryption.SSHKey,
+			Padding:   cfg.Encryption.Padding,
+			Created:   time.Now().UTC().Truncate(time.Second),
+		}
+
+		err = ensureProjectID(&next.Encryption)
+		if err != nil {
+			_ = fileutils.Remove(newKeyPath)
+			return err
+		}
+
+		if crypt.HasRecipients(encType) {
+			err = startTeam(&next, nil, opts...)
+			if err != nil {
+				_ = fileutils.Remove(newKeyPath)
+				return err
+			}
+		}
+
+		newKeeper, err := next.Keeper()
+		if err != nil {
+			_ = fileutils.Remove(newKeyPath)
+			return err
+		}
+
+		env, err := reencrypt(cfg.Env, oldKeeper, newKeeper)
+		if err != nil {
+			_ = fileutils.Remove(newKeyPath)
+			return fmt.Errorf("migration aborted, nothing was changed: %w", err)
+		}
+
+		next.Env = env
+		next.Encryption.KeyPath, err = replacementKeyPath(keyPath, newKeeper)
+		if err != nil {
+			_ = fileutils.Remove(newKeyPath)
+			return err
+		}
+
+		if external {
+			err = installExternalKey(&next, newKeyPath)
+		} else {
+			err = installKey(&next, oldKeyPath, newKeyPath, backupPath)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Migrated %d secret(s) to %s with a new key in %s\n", len(env), encType, next.Encryption.KeyPath)
+
+		if !external {
+			removeBackup(backupPath)
+		}
+
+		if cfg.IsDirenvIntegrated() {
+			return direnv.ReloadEnv()
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	Migrate.Flags().StringVarP(&migrateTo, "to", "t", "", "Type of encryption to migrate the secrets to")
+	Migrate.Flags().StringVarP(&migrateKeyPath, "key-path", "k", "", "File path to output the new encryption key (defaults to the current key path)")
+	Migrate.Flags().BoolVarP(&yesPrompt, "yes", "y", false, "Delete the backup of the old key without prompting")
+
+	_ = Migrate.MarkFlagRequired("to")
+}
@@ -0,0 +1,267 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/config/direnv"
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+)
+
+var identityKeyPath string
+
+var Recipients = &cobra.Command{
+	Use:   "recipients",
+	Short: "Manage the teammates secrets are encrypted to",
+	Long:  "Projects initialized with '-e x25519' or '-e age' encrypt every secret to each public key in .ckrecipients, so every teammate decrypts with their own key and nobody has to share one. Commit .ckrecipients with .ckrc, but never the key files.",
+}
+
+var RecipientsList = &cobra.Command{
+	Use:   "list",
+	Short: "List the recipients",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := teamConfig()
+		if err != nil {
+			return err
+		}
+
+		for _, recipient := range cfg.Recipients {
+			fmt.Println(recipient.Key, recipient.Name)
+		}
+
+		return nil
+	},
+}
+
+var RecipientsAdd = &cobra.Command{
+	Use:   "add <public key> [name]",
+	Short: "Give a teammate access to every secret",
+	Long:  "Adds the public key printed by the teammate's 'cryptkeeper recipients identity' and re-encrypts every secret so they can decrypt it. age projects also accept age public keys and SSH public keys.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := teamConfig()
+		if err != nil {
+			return err
+		}
+
+		// An SSH public key's comment names the recipient, unless a name is
+		// given.
+		recipient, err := config.ParseRecipient(cfg.Encryption.Type, args[0])
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			recipient.Name = strings.Join(args[1:], " ")
+		}
+		if cfg.Recipients.Find(recipient.Key) >= 0 {
+			return fmt.Errorf("%s is already a recipient", recipient.Key)
+		}
+
+		recipients := append(append(config.Recipients{}, cfg.Recipients...), recipient)
+
+		err = rewrap(cfg, recipients)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Added %s and re-encrypted %d secret(s)\n", recipient.Key, len(cfg.Env))
+
+		if cfg.IsDirenvIntegrated() {
+			return direnv.ReloadEnv()
+		}
+
+		return nil
+	},
+}
+
+var RecipientsRemove = &cobra.Command{
+	Use:   "remove <public key or name>",
+	Short: "Take a teammate's access to future secrets away",
+	Long:  "Removes the recipient and re-encrypts every secret without them. They can still read old copies of the config they already have, so rotate any secret they had access to.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := teamConfig()
+		if err != nil {
+			return err
+		}
+
+		i := cfg.Recipients.Find(args[0])
+		if i < 0 {
+			return fmt.Errorf("%s isn't a recipient", args[0])
+		}
+		if len(cfg.Recipients) == 1 {
+			return errors.New("can't remove the last recipient")
+		}
+
+		removed := cfg.Recipients[i]
+		recipients := append(append(config.Recipients{}, cfg.Recipients[:i]...), cfg.Recipients[i+1:]...)
+
+		err = rewrap(cfg, recipients)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Removed %s and re-encrypted %d secret(s)\n", removed.Key, len(cfg.Env))
+
+		if cfg.IsDirenvIntegrated() {
+			return direnv.ReloadEnv()
+		}
+
+		return nil
+	},
+}
+
+var RecipientsIdentity = &cobra.Command{
+	Use:   "identity",
+	Short: "Print your public key, generating your key if needed",
+	Long:  "Prints the public key a teammate passes to 'cryptkeeper recipients add' to give you access. The key is generated at the key path first if it doesn't exist yet.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		encType, path := crypt.X25519, config.KeyFileName()
+		if cfg, err := config.GetConfig(); err == nil && crypt.HasRecipients(cfg.Encryption.Type) {
+			encType, path = cfg.Encryption.Type, cfg.Encryption.KeyPath
+		}
+		if identityKeyPath != "" {
+			path = identityKeyPath
+		}
+		path = fileutils.Clean(path)
+
+		if !fileutils.FileExists(path) {
+			err := crypt.GenerateKeys(encType, path)
+			if err != nil {
+				return err
+			}
+		}
+
+		keeper, err := crypt.NewKeeper(encType, path)
+		if err != nil {
+			return err
+		}
+
+		recipient, err := keeper.Recipient()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(recipient)
+
+		return nil
+	},
+}
+
+func init() {
+	RecipientsIdentity.Flags().StringVarP(&identityKeyPath, "key-path", "k", "", "Path of your key file (default is the project's key path)")
+
+	Recipients.AddCommand(RecipientsList)
+	Recipients.AddCommand(RecipientsAdd)
+	Recipients.AddCommand(RecipientsRemove)
+	Recipients.AddCommand(RecipientsIdentity)
+}
+
+func teamConfig() (*config.Config, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if !crypt.HasRecipients(cfg.Encryption.Type) {
+		return nil, fmt.Errorf("project is encrypted with %s - recipients need x25519 or age, use 'cryptkeeper migrate-encryption --to x25519'", cfg.Encryption.Type)
+	}
+
+	return cfg, nil
+}
+
+// startTeam makes the owner of the key at cfg's key path and the given
+// recipients the recipients of a new project.
+func startTeam(cfg *config.Config, recipients config.Recipients, opts ...crypt.Option) error {
+	keeper, err := crypt.NewKeeper(cfg.Encryption.Type, cfg.Encryption.KeyPath, opts...)
+	if err != nil {
+		return err
+	}
+
+	key, err := keeper.Recipient()
+	if err != nil {
+		return err
+	}
+
+	cfg.Recipients = recipients
+	if recipients.Find(key) < 0 {
+		owner := config.Recipient{Key: key}
+		if u, err := user.Current(); err == nil {
+			owner.Name = u.Username
+		}
+
+		cfg.Recipients = append(config.Recipients{owner}, recipients...)
+	}
+
+	cfg.Encryption.TeamSecret, err = crypt.NewTeamSecret(cfg.Encryption.Type, cfg.Recipients.Keys())
+
+	return err
+}
+
+// rewrap re-encrypts every secret and a fresh team secret to recipients and
+// writes the config. Nothing is written if any secret fails to decrypt.
+func rewrap(cfg *config.Config, recipients config.Recipients) error {
+	oldKeeper, err := cfg.Keeper()
+	if err != nil {
+		return err
+	}
+
+	next := *cfg
+	next.Recipients = recipients
+	next.Encryption.TeamSecret, err = crypt.NewTeamSecret(next.Encryption.Type, recipients.Keys())
+	if err != nil {
+		return err
+	}
+
+	newKeeper, err := next.Keeper()
+	if err != nil {
+		return err
+	}
+
+	next.Env, err = reencrypt(cfg.Env, oldKeeper, newKeeper)
+	if err != nil {
+		return fmt.Errorf("nothing was changed: %w", err)
+	}
+
+	*cfg = next
+
+	return config.Write(cfg)
+}
+
+// rotateRecipient replaces the public key of oldKeeper's identity with the
+// one at next's key path, so whoever rotates their key keeps access.
+func rotateRecipient(next *config.Config, oldKeeper *crypt.Keeper, opts ...crypt.Option) error {
+	oldKey, err := oldKeeper.Recipient()
+	if err != nil {
+		return err
+	}
+
+	i := next.Recipients.Find(oldKey)
+	if i < 0 {
+		return crypt.ErrNotRecipient
+	}
+
+	newKeeper, err := crypt.NewKeeper(next.Encryption.Type, next.Encryption.KeyPath, opts...)
+	if err != nil {
+		return err
+	}
+
+	newKey, err := newKeeper.Recipient()
+	if err != nil {
+		return err
+	}
+
+	next.Recipients = append(config.Recipients{}, next.Recipients...)
+	next.Recipients[i].Key = newKey
+	next.Encryption.TeamSecret, err = crypt.NewTeamSecret(next.Encryption.Type, next.Recipients.Keys())
+
+	return err
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/config/direnv"
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+)
+
+var reencryptPadding string
+
+var Reencrypt = &cobra.Command{
+	Use:   "reencrypt",
+	Short: "Re-encrypt every secret with the current key in the latest format",
+	Long:  "Decrypts every secret and encrypts it again with the current key. Use it to upgrade projects created by older versions, for example to bind each value to its secret name and project, or to change how values are padded with --padding.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		oldKeeper, err := cfg.Keeper()
+		if err != nil {
+			return err
+		}
+
+		next := *cfg
+		err = ensureProjectID(&next.Encryption)
+		if err != nil {
+			return err
+		}
+
+		if cmd.Flags().Changed("padding") {
+			next.Encryption.Padding, err = crypt.ParsePadding(reencryptPadding)
+			if err != nil {
+				return err
+			}
+			if next.Encryption.Padding != nil && next.Encryption.Type == crypt.Age {
+				return fmt.Errorf("--padding isn't supported with '-e %s', the age CLI wouldn't strip it", crypt.Age)
+			}
+		}
+
+		newKeeper, err := next.Keeper()
+		if err != nil {
+			return err
+		}
+
+		next.Env, err = reencrypt(cfg.Env, oldKeeper, newKeeper)
+		if err != nil {
+			return fmt.Errorf("nothing was changed: %w", err)
+		}
+
+		err = config.Write(&next)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Re-encrypted %d secret(s)\n", len(next.Env))
+		if cmd.Flags().Changed("padding") {
+			fmt.Printf("Padding: %s\n", next.Encryption.Padding)
+		}
+
+		if cfg.IsDirenvIntegrated() {
+			return direnv.ReloadEnv()
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	Reencrypt.Flags().StringVar(&reencryptPadding, "padding", "", "Change how secrets are padded to hide their length: a block size in bytes, 'pow2' for the next power of two, or 'none'")
+}
@@ -28,7 +28,7 @@ var Remove = &cobra.Command{
 		}
 
 		if cfg.Encryption.Type == crypt.ECC256 {
-			keeper, err := crypt.NewKeeper(cfg.Encryption.Type, cfg.Encryption.KeyPath)
+			keeper, err := cfg.Keeper()
 			if err != nil {
 				logrus.
 					WithError(err).
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/config/direnv"
+)
+
+var Reseal = &cobra.Command{
+	Use:   "reseal",
+	Short: "Seal the config again after it was written with only the public key",
+	Long:  "Recomputes the config's integrity MAC without checking the old one. Configs written by someone holding only the public key, or missing their MAC, are refused until they're resealed. Resealing vouches for every secret in the config, so review the changes made to it since it was last sealed first.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config.IgnoreIntegrity = true
+
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		err = config.Write(cfg)
+		if err != nil {
+			return err
+		}
+		if !cfg.Sealed() {
+			return errors.New("the key can't seal the config, it has to be resealed by someone holding the private key")
+		}
+
+		fmt.Printf("Resealed %s with %d secret(s)\n", cfg.Path, len(cfg.Env))
+
+		if cfg.IsDirenvIntegrated() {
+			return direnv.ReloadEnv()
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,320 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/config/direnv"
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+)
+
+const (
+	newKeySuffix    = ".new"
+	backupKeySuffix = ".bak"
+)
+
+var Rotate = &cobra.Command{
+	Use:   "rotate",
+	Short: "Re-encrypt every secret under a freshly generated key",
+	Long:  "Generates a new key of the same encryption type, re-encrypts every secret with it and replaces the key file. The old key is kept next to the new one until you confirm it can be deleted.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		if crypt.KeySupplied() {
+			return crypt.ErrSuppliedKey
+		}
+
+		oldKeeper, err := cfg.Keeper()
+		if err != nil {
+			return fmt.Errorf("failed to load current key: %w", err)
+		}
+
+		if crypt.WrapsDataKey(cfg.Encryption.Type) {
+			return rotateDataKey(cfg, oldKeeper)
+		}
+
+		keyPath := cfg.Encryption.KeyPath
+
+		external, err := crypt.IsExternalKey(keyPath)
+		if err != nil {
+			return err
+		}
+		if external {
+			return fmt.Errorf("can't rotate %s: %w", keyPath, crypt.ErrExternalKey)
+		}
+
+		newKeyPath := keyPath + newKeySuffix
+		backupPath := keyPath + backupKeySuffix
+
+		if err := guardKeyPaths(newKeyPath, backupPath); err != nil {
+			return err
+		}
+
+		opts, err := keyOptions(keyPath)
+		if err != nil {
+			return err
+		}
+
+		err = crypt.GenerateKeys(cfg.Encryption.Type, newKeyPath, opts...)
+		if err != nil {
+			return err
+		}
+
+		// Everything ends up under the new key, so retired keys aren't
+		// needed anymore. The new key's fingerprint is recorded when the
+		// config is written.
+		next := *cfg
+		next.Encryption.KeyPath = newKeyPath
+		next.Encryption.Fingerprint = ""
+		next.Encryption.Created = time.Now().UTC().Truncate(time.Second)
+		next.Encryption.Retired = nil
+
+		err = ensureProjectID(&next.Encryption)
+		if err != nil {
+			_ = fileutils.Remove(newKeyPath)
+			return err
+		}
+
+		if crypt.HasRecipients(next.Encryption.Type) {
+			err = rotateRecipient(&next, oldKeeper, opts...)
+			if err != nil {
+				_ = fileutils.Remove(newKeyPath)
+				return err
+			}
+		}
+
+		newKeeper, err := next.Keeper()
+		if err != nil {
+			_ = fileutils.Remove(newKeyPath)
+			return err
+		}
+
+		env, err := reencrypt(cfg.Env, oldKeeper, newKeeper)
+		if err != nil {
+			_ = fileutils.Remove(newKeyPath)
+			return err
+		}
+
+		next.Env = env
+		next.Encryption.KeyPath, err = replacementKeyPath(keyPath, newKeeper)
+		if err != nil {
+			_ = fileutils.Remove(newKeyPath)
+			return err
+		}
+
+		err = installKey(&next, keyPath, newKeyPath, backupPath)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Re-encrypted %d secret(s) with a new key in %s\n", len(env), next.Encryption.KeyPath)
+
+		removeBackup(backupPath)
+
+		if cfg.IsDirenvIntegrated() {
+			return direnv.ReloadEnv()
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	Rotate.Flags().BoolVarP(&yesPrompt, "yes", "y", false, "Delete the backup of the old key without prompting")
+}
+
+// rotateDataKey re-encrypts every secret of a pkcs11, vault-transit or
+// keyprovider project under a new data key, wrapped by the same HSM key,
+// transit key or key provider.
+// There's no key file to replace, so the config holds the only copy of the
+// new data key once it's written.
+func rotateDataKey(cfg *config.Config, oldKeeper *crypt.Keeper) error {
+	next := *cfg
+
+	var err error
+	switch {
+	case cfg.Encryption.PKCS11 != nil:
+		hsmKey := *cfg.Encryption.PKCS11
+		err = crypt.NewPKCS11Key(&hsmKey)
+		next.Encryption.PKCS11 = &hsmKey
+	case cfg.Encryption.VaultTransit != nil:
+		transitKey := *cfg.Encryption.VaultTransit
+		err = crypt.NewVaultTransitKey(&transitKey)
+		next.Encryption.VaultTransit = &transitKey
+	case cfg.Encryption.KeyProvider != nil:
+		providerKey := *cfg.Encryption.KeyProvider
+		err = crypt.NewKeyProviderKey(&providerKey)
+		next.Encryption.KeyProvider = &providerKey
+	}
+	if err != nil {
+		return err
+	}
+
+	next.Encryption.Created = time.Now().UTC().Truncate(time.Second)
+	next.Encryption.Retired = nil
+
+	err = ensureProjectID(&next.Encryption)
+	if err != nil {
+		return err
+	}
+
+	newKeeper, err := next.Keeper()
+	if err != nil {
+		return err
+	}
+
+	next.Env, err = reencrypt(cfg.Env, oldKeeper, newKeeper)
+	if err != nil {
+		return err
+	}
+
+	err = config.Write(&next)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-encrypted %d secret(s) with a new data key wrapped by %s\n", len(next.Env), newKeeper.KeySource())
+
+	if cfg.IsDirenvIntegrated() {
+		return direnv.ReloadEnv()
+	}
+
+	return nil
+}
+
+// reencrypt decrypts every value in env with from and encrypts it again with
+// to. Everything is decrypted before anything is encrypted, so a value that
+// fails to decrypt leaves the project untouched.
+func reencrypt(env config.Env, from, to *crypt.Keeper) (config.Env, error) {
+	plainEnv := env.Copy()
+	err := plainEnv.Decrypt(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets: %w", err)
+	}
+
+	newEnv := make(config.Env, len(plainEnv))
+	for key, plainText := range plainEnv {
+		newEnv[key], err = to.Encrypt(key, plainText)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
+		}
+	}
+
+	return newEnv, nil
+}
+
+// ensureProjectID gives configs written before values were bound to their
+// project a project ID. Only call it when every value is re-encrypted.
+func ensureProjectID(enc *config.Encryption) error {
+	if enc.ProjectID != "" {
+		return nil
+	}
+
+	projectID, err := crypt.NewProjectID()
+	if err != nil {
+		return err
+	}
+
+	enc.ProjectID = projectID
+
+	return nil
+}
+
+// replacementKeyPath returns where the key of newKeeper goes when it
+// replaces the key at keyPath. Keys in the key store are named after their
+// fingerprint, everything else keeps its path.
+func replacementKeyPath(keyPath string, newKeeper *crypt.Keeper) (string, error) {
+	if !crypt.InKeyStore(keyPath) {
+		return keyPath, nil
+	}
+
+	fingerprint, err := newKeeper.Fingerprint()
+	if err != nil {
+		return "", err
+	}
+
+	return crypt.KeyStorePath(fingerprint)
+}
+
+// guardKeyPaths refuses to run when an earlier rotation or migration left
+// its files behind, so we never clobber a key that might still be needed.
+func guardKeyPaths(paths ...string) error {
+	for _, path := range paths {
+		if fileutils.FileExists(path) {
+			return fmt.Errorf("%s already exists - move it out of the way and try again", path)
+		}
+	}
+
+	return nil
+}
+
+// installKey swaps the key at newKeyPath into cfg.Encryption.KeyPath, moves
+// the current key at oldKeyPath to backupPath and writes cfg. If any step
+// fails, the old key and config are put back.
+func installKey(cfg *config.Config, oldKeyPath, newKeyPath, backupPath string) error {
+	err := fileutils.Rename(oldKeyPath, backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to back up old key: %w", err)
+	}
+
+	err = fileutils.Rename(newKeyPath, cfg.Encryption.KeyPath)
+	if err != nil {
+		_ = fileutils.Rename(backupPath, oldKeyPath)
+		return fmt.Errorf("failed to install new key: %w", err)
+	}
+
+	err = config.Write(cfg)
+	if err != nil {
+		_ = fileutils.Rename(cfg.Encryption.KeyPath, newKeyPath)
+		_ = fileutils.Rename(backupPath, oldKeyPath)
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
+
+// installExternalKey installs the new key next to an SSH key that cryptkeeper
+// must leave in place.
+func installExternalKey(cfg *config.Config, newKeyPath string) error {
+	err := fileutils.Rename(newKeyPath, cfg.Encryption.KeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to install new key: %w", err)
+	}
+
+	err = config.Write(cfg)
+	if err != nil {
+		_ = fileutils.Rename(cfg.Encryption.KeyPath, newKeyPath)
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
+
+func removeBackup(backupPath string) {
+	remove := yesPrompt
+	if !remove {
+		output := promptUserf("Delete the old key at %s? Keep it until you've checked the new key works. [y/N]: ", backupPath)
+		remove = output == "y" || output == "yes"
+	}
+
+	if !remove {
+		fmt.Printf("Kept the old key at %s\n", backupPath)
+		return
+	}
+
+	err := fileutils.Remove(backupPath)
+	if err != nil {
+		logrus.
+			WithError(err).
+			Warnf("failed to delete the old key at %s", backupPath)
+	}
+}
@@ -2,7 +2,6 @@ package commands
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -10,7 +9,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/sunny-b/cryptkeeper/internal/config"
 	"github.com/sunny-b/cryptkeeper/internal/config/direnv"
-	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
 
 	"github.com/atotto/clipboard"
 	"github.com/spf13/cobra"
@@ -31,7 +30,7 @@ var Set = &cobra.Command{
 
 		cfg, err := config.GetConfig()
 		if err != nil {
-			return errors.New("failed to get config")
+			return fmt.Errorf("failed to get config: %w", err)
 		}
 
 		switch {
@@ -61,11 +60,12 @@ var Set = &cobra.Command{
 			}
 
 			value = string(byteValue)
+			securemem.Wipe(byteValue)
 		}
 
 		value = strings.TrimSuffix(value, "\n")
 
-		keeper, err := crypt.NewKeeper(cfg.Encryption.Type, cfg.Encryption.KeyPath)
+		keeper, err := cfg.Keeper()
 		if err != nil {
 			return err
 		}
@@ -85,7 +85,7 @@ var Set = &cobra.Command{
 
 		err = config.Write(cfg)
 		if err != nil {
-			return errors.New("failed to write config")
+			return fmt.Errorf("failed to write config: %w", err)
 		}
 
 		if cfg.IsDirenvIntegrated() {
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sunny-b/cryptkeeper/internal/config"
+	"github.com/sunny-b/cryptkeeper/internal/config/direnv"
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+)
+
+var Trust = &cobra.Command{
+	Use:   "trust",
+	Short: "Trust the key provider the config runs, with its options",
+	Long:  "Records that you trust the config's key provider to be run with the options in the config. The config comes from the project, so key providers are only run with options you've trusted: review the key_provider blocks in the config, retired keys' included, before trusting them. Projects initialized or rotated on this machine are trusted already.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The config can't be verified until its key provider is trusted.
+		ignoreIntegrity := config.IgnoreIntegrity
+		config.IgnoreIntegrity = true
+
+		cfg, err := config.GetConfig()
+		config.IgnoreIntegrity = ignoreIntegrity
+		if err != nil {
+			return err
+		}
+
+		keys := []*crypt.KeyProviderKey{cfg.Encryption.KeyProvider}
+		for _, retired := range cfg.Encryption.Retired {
+			keys = append(keys, retired.KeyProvider)
+		}
+
+		trusted := 0
+		for _, key := range keys {
+			if key == nil {
+				continue
+			}
+
+			options := "no options"
+			if len(key.Options) > 0 {
+				pairs := make([]string, 0, len(key.Options))
+				for _, name := range slices.Sorted(maps.Keys(key.Options)) {
+					pairs = append(pairs, name+"="+key.Options[name])
+				}
+
+				options = "options " + strings.Join(pairs, ", ")
+			}
+
+			fmt.Printf("Trusting key provider %q with %s\n", key.Name, options)
+
+			err = key.Trust()
+			if err != nil {
+				return err
+			}
+			trusted++
+		}
+		if trusted == 0 {
+			return errors.New("the config doesn't use a key provider")
+		}
+
+		// Now that the provider can be run, the config's MAC is checked.
+		cfg, err = config.GetConfig()
+		if err != nil {
+			return err
+		}
+
+		if cfg.IsDirenvIntegrated() {
+			return direnv.ReloadEnv()
+		}
+
+		return nil
+	},
+}
@@ -3,7 +3,6 @@ package commands
 import (
 	"bufio"
 	"crypto/subtle"
-	"errors"
 	"fmt"
 	"os"
 
@@ -11,7 +10,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/sunny-b/cryptkeeper/internal/config"
-	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
 	"golang.org/x/term"
 )
 
@@ -26,7 +25,7 @@ var Verify = &cobra.Command{
 
 		cfg, err := config.GetConfig()
 		if err != nil {
-			return errors.New("failed to get config")
+			return fmt.Errorf("failed to get config: %w", err)
 		}
 
 		cipher, ok := cfg.Env[envKey]
@@ -59,9 +58,10 @@ var Verify = &cobra.Command{
 			}
 
 			expectedValue = string(byteValue)
+			securemem.Wipe(byteValue)
 		}
 
-		keeper, err := crypt.NewKeeper(cfg.Encryption.Type, cfg.Encryption.KeyPath)
+		keeper, err := cfg.Keeper()
 		if err != nil {
 			return err
 		}
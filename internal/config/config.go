@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/direnv/direnv/gzenv"
+	"github.com/sirupsen/logrus"
 
 	"github.com/sunny-b/cryptkeeper/internal/crypt"
 	"github.com/sunny-b/cryptkeeper/internal/fileutils"
@@ -44,26 +47,91 @@ type Config struct {
 	Encryption Encryption `json:"encryption"`
 	Env        Env        `json:"env"`
 
+	// Integrity is a MAC over the encryption block and the env, recomputed
+	// by Write and checked whenever the config is loaded.
+	Integrity string `json:"integrity,omitempty"`
+
+	// Recipients are read from and written to their own file, and only
+	// used by encryption types with recipients.
+	Recipients Recipients `json:"-"`
+
 	Path string
 }
 
 func (c *Config) MarshalJSON() ([]byte, error) {
+	encryption := c.Encryption
+	if c.Path != "" {
+		encryption = encryption.portable(filepath.Dir(c.Path))
+	}
+
 	tmp := struct {
 		Mode       Mode       `json:"mode"`
 		Encryption Encryption `json:"encryption"`
 		Env        Env        `json:"env"`
+		Integrity  string     `json:"integrity,omitempty"`
 	}{
 		Mode:       c.Mode,
-		Encryption: c.Encryption,
+		Encryption: encryption,
 		Env:        c.Env,
+		Integrity:  c.Integrity,
 	}
 
 	return json.Marshal(tmp)
 }
 
 type Encryption struct {
-	Type    crypt.EncryptionType `json:"type"`
-	KeyPath string               `json:"key_path"`
+	Type crypt.EncryptionType `json:"type"`
+
+	// KeyPath is absolute once the config is read. It's written relative
+	// to the config, or left out when the key is in the key store.
+	KeyPath string `json:"key_path,omitempty"`
+
+	// Fingerprint identifies the key. Keepers refuse any other key, so a
+	// mixed-up key file fails with a clear error. Configs written before it
+	// existed get one the next time they're written. Projects with
+	// recipients don't have one, since every member has their own key.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// Created is when the key was generated, if cryptkeeper generated it.
+	Created time.Time `json:"created,omitzero"`
+
+	// ProjectID is bound to every value so it can't be copied into another
+	// project. Configs written before it existed don't have one until they
+	// are upgraded with 'cryptkeeper reencrypt'.
+	ProjectID string `json:"project_id,omitempty"`
+
+	// Retired lists keys that have been replaced but that some values may
+	// still be encrypted with. They're only used for decryption.
+	Retired []Encryption `json:"retired,omitempty"`
+
+	// TeamSecret is sealed to every recipient of a project with
+	// recipients. The config's integrity MAC is keyed with it.
+	TeamSecret string `json:"team_secret,omitempty"`
+
+	// SSHKey is the fingerprint of the SSH key that protects the key file,
+	// as ssh-keygen -l prints it. The key is looked up in ssh-agent and
+	// ~/.ssh.
+	SSHKey string `json:"ssh_key,omitempty"`
+
+	// Padding hides the length of values by padding them before they're
+	// encrypted. Values are encrypted at their own length without it.
+	Padding *crypt.Padding `json:"padding,omitempty"`
+
+	// PKCS11 names the slot and label of the HSM key of a pkcs11 project
+	// and holds the data key it wrapped. Such projects have no key file.
+	// The module it names is only loaded if it's a well-known one or the
+	// user allowed it, and CK_PKCS11_MODULE takes precedence.
+	PKCS11 *crypt.PKCS11Key `json:"pkcs11,omitempty"`
+
+	// VaultTransit names the transit key of a vault-transit project and
+	// holds the data key it wrapped. Such projects have no key file either.
+	// The Vault address and token are never read from the config, only
+	// from the VAULT_ environment variables it names.
+	VaultTransit *crypt.VaultTransitKey `json:"vault_transit,omitempty"`
+
+	// KeyProvider names the key provider of a keyprovider project, the
+	// options it's sent, and holds the data key it wrapped.
+	KeyProvider *crypt.KeyProviderKey `json:"key_provider,omitempty"`
 }
 
 type Direnv struct {
@@ -127,6 +195,54 @@ func (e Env) CleanContext() {
 	delete(e, CKWatchEnvKey)
 }
 
+// Keeper returns a Keeper for the configured key, or for the key supplied by
+// CK_KEY, CK_KEY_FILE or --key-fd. Retired keys are loaded alongside it when
+// their key files are available.
+func (c *Config) Keeper() (*crypt.Keeper, error) {
+	opts := []crypt.Option{
+		crypt.WithProjectID(c.Encryption.ProjectID),
+		crypt.WithPadding(c.Encryption.Padding),
+	}
+	if crypt.HasRecipients(c.Encryption.Type) {
+		opts = append(opts,
+			crypt.WithRecipients(c.Recipients.Keys()),
+			crypt.WithTeamSecret(c.Encryption.TeamSecret),
+		)
+	}
+
+	keeper, err := crypt.NewKeeper(c.Encryption.Type, c.Encryption.KeyPath, append(opts,
+		crypt.WithSSHKey(c.Encryption.SSHKey),
+		crypt.WithFingerprint(c.Encryption.Fingerprint),
+		crypt.WithPKCS11Key(c.Encryption.PKCS11),
+		crypt.WithVaultTransitKey(c.Encryption.VaultTransit),
+		crypt.WithKeyProviderKey(c.Encryption.KeyProvider),
+		crypt.WithSuppliedKey(),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, retired := range c.Encryption.Retired {
+		r, err := crypt.NewKeeper(retired.Type, retired.KeyPath, append(opts,
+			crypt.WithSSHKey(retired.SSHKey),
+			crypt.WithFingerprint(retired.Fingerprint),
+			crypt.WithPKCS11Key(retired.PKCS11),
+			crypt.WithVaultTransitKey(retired.VaultTransit),
+			crypt.WithKeyProviderKey(retired.KeyProvider),
+		)...)
+		if err != nil {
+			logrus.
+				WithError(err).
+				Debugf("skipping retired key at %s", retired.KeyPath)
+			continue
+		}
+
+		keeper.AddRetired(r)
+	}
+
+	return keeper, nil
+}
+
 func (c *Config) IsDirenvIntegrated() bool {
 	return c.Mode == DirenvMode
 }
@@ -187,6 +303,20 @@ func Write(config *Config) error {
 		}
 	}
 
+	config.Path = path
+
+	err := config.seal()
+	if err != nil {
+		return fmt.Errorf("failed to compute config integrity: %w", err)
+	}
+
+	if crypt.HasRecipients(config.Encryption.Type) {
+		err = writeRecipients(config.RecipientsPath(), config.Recipients)
+		if err != nil {
+			return fmt.Errorf("failed to write recipients: %w", err)
+		}
+	}
+
 	b, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -211,6 +341,11 @@ func GetConfigFromPath(path string) (*Config, error) {
 
 	config.Path = path
 
+	err = config.load()
+	if err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
@@ -232,6 +367,11 @@ func GetConfig() (*Config, error) {
 
 	config.Path = path
 
+	err = config.load()
+	if err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
@@ -256,6 +396,22 @@ func findPathToConfig() (string, error) {
 	return nearestPath, nil
 }
 
+// load resolves the key paths, reads the recipients of projects that have
+// them and checks the config's integrity.
+func (c *Config) load() error {
+	c.resolveKeyPaths()
+
+	if crypt.HasRecipients(c.Encryption.Type) {
+		var err error
+		c.Recipients, err = readRecipients(c.RecipientsPath(), c.Encryption.Type)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.verify()
+}
+
 func loadConfig(path string, config *Config) error {
 	b, err := fileutils.ReadFile(path)
 	if err != nil {
@@ -295,5 +451,10 @@ func getCachedConfig() (*Config, error) {
 		return nil, err
 	}
 
+	err = config.load()
+	if err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
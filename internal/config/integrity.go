@@ -0,0 +1,246 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+)
+
+const (
+	integrityPrefix = "hmac-sha256:"
+
+	// unsealedIntegrity flags a config written by someone holding only the
+	// public key, who can't produce a MAC. It's refused by everyone who can
+	// check the MAC until it's resealed.
+	unsealedIntegrity = "unsealed:public-key-only"
+)
+
+var (
+	// IgnoreIntegrity skips the integrity check when loading the config.
+	IgnoreIntegrity bool
+
+	ErrIntegrity        = errors.New("config integrity check failed - secrets, recipients or encryption settings were changed outside cryptkeeper (use --ignore-integrity to load it anyway)")
+	ErrIntegrityMissing = errors.New("config has no integrity MAC (review it and run 'cryptkeeper reseal', or use --ignore-integrity to load it anyway)")
+	ErrUnsealed         = errors.New("config was last written with only the public key, so its secrets can't be authenticated (review the changes to it and run 'cryptkeeper reseal', or use --ignore-integrity to load it anyway)")
+)
+
+// integrityPayload is the canonical form of everything the MAC covers: the
+// encryption block, the sorted secret names with their ciphertexts and the
+// recipients, if the encryption type has any.
+func (c *Config) integrityPayload() ([]byte, error) {
+	var b strings.Builder
+
+	encryption, err := json.Marshal(c.Encryption.withoutKeyPaths())
+	if err != nil {
+		return nil, err
+	}
+
+	b.WriteString("cryptkeeper integrity v1\n")
+	b.Write(encryption)
+	b.WriteString("\n")
+
+	keys := c.Env.Keys()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s\x00%s\n", key, c.Env[key])
+	}
+
+	if crypt.HasRecipients(c.Encryption.Type) {
+		b.WriteString("recipients\n")
+		b.WriteString(c.Recipients.payload())
+	}
+
+	return []byte(b.String()), nil
+}
+
+// withoutKeyPaths returns a copy of e without its key paths, or those of
+// its retired keys. They're resolved differently on every machine, and the
+// key they lead to is pinned by its fingerprint and by the MAC key anyway.
+func (e Encryption) withoutKeyPaths() Encryption {
+	e.KeyPath = ""
+
+	retired := make([]Encryption, len(e.Retired))
+	for i, r := range e.Retired {
+		retired[i] = r.withoutKeyPaths()
+	}
+	if len(retired) > 0 {
+		e.Retired = retired
+	}
+
+	return e
+}
+
+// seal recomputes the integrity MAC and records that the config is sealed.
+// Keys that can't produce a MAC flag the config as unsealed instead. Configs
+// without a key fingerprint are given the one of the key that seals them,
+// unless every recipient has a key of their own.
+func (c *Config) seal() error {
+	keeper, err := c.Keeper()
+	if err != nil {
+		return err
+	}
+
+	if c.Encryption.Fingerprint == "" && c.Encryption.hasKeyFile() {
+		c.Encryption.Fingerprint, err = keeper.Fingerprint()
+		if err != nil {
+			return err
+		}
+	}
+
+	payload, err := c.integrityPayload()
+	if err != nil {
+		return err
+	}
+
+	sum, err := keeper.MAC(payload)
+	if errors.Is(err, crypt.ErrNoMACKey) {
+		logrus.Warnf("%s is written with only the public key, so it's flagged as unsealed - someone holding the private key has to review it and run 'cryptkeeper reseal'", c.Path)
+		c.Integrity = unsealedIntegrity
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Integrity = integrityPrefix + base64.StdEncoding.EncodeToString(sum)
+
+	err = keeper.MarkSealed()
+	if err != nil {
+		logrus.WithError(err).Debug("failed to record that the config is sealed")
+	}
+
+	return nil
+}
+
+// verify checks the integrity MAC. Configs written before the MAC existed
+// have no project ID and are let through without one, unless their key is
+// wrapped by an HSM, Vault or key provider, or this machine has seen the
+// config sealed, so stripping the MAC and the project ID doesn't bring back
+// values from before it was sealed. Configs flagged as unsealed are refused
+// by every key that could check their MAC.
+func (c *Config) verify() error {
+	if IgnoreIntegrity {
+		return nil
+	}
+
+	if c.Integrity == "" {
+		// Keys wrapped by an HSM, Vault or key provider came after the MAC,
+		// so their configs were always sealed. They're refused before the
+		// key is unwrapped, which would run the key provider.
+		if c.Encryption.ProjectID == "" && !crypt.WrapsDataKey(c.Encryption.Type) {
+			sealed, err := c.sealed()
+			if err != nil {
+				return err
+			}
+			if sealed {
+				return fmt.Errorf("%s: %w", c.Path, ErrIntegrityMissing)
+			}
+
+			logrus.Debug("legacy config has no integrity MAC")
+			return nil
+		}
+
+		return fmt.Errorf("%s: %w", c.Path, ErrIntegrityMissing)
+	}
+
+	if c.Integrity == unsealedIntegrity {
+		return c.verifyUnsealed()
+	}
+
+	encoded, ok := strings.CutPrefix(c.Integrity, integrityPrefix)
+	if !ok {
+		return fmt.Errorf("%s: %w", c.Path, ErrIntegrity)
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Path, ErrIntegrity)
+	}
+
+	keeper, err := c.Keeper()
+	if err != nil {
+		return err
+	}
+
+	payload, err := c.integrityPayload()
+	if err != nil {
+		return err
+	}
+
+	valid, err := keeper.VerifyMAC(payload, sum)
+	if errors.Is(err, crypt.ErrNoMACKey) {
+		logrus.Debug("key can't authenticate the config - skipping the integrity check")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("%s: %w", c.Path, ErrIntegrity)
+	}
+
+	err = keeper.MarkSealed()
+	if err != nil {
+		logrus.WithError(err).Debug("failed to record that the config is sealed")
+	}
+
+	return nil
+}
+
+// verifyUnsealed refuses a config flagged as unsealed, unless the key can't
+// produce a MAC either. Writers holding only the public key can't check
+// anything, so they keep adding secrets.
+func (c *Config) verifyUnsealed() error {
+	// Wrapped data keys always produce a MAC, so they're refused without
+	// being unwrapped.
+	if crypt.WrapsDataKey(c.Encryption.Type) {
+		return fmt.Errorf("%s: %w", c.Path, ErrUnsealed)
+	}
+
+	keeper, err := c.Keeper()
+	if err != nil {
+		return err
+	}
+
+	_, err = keeper.MAC(nil)
+	if errors.Is(err, crypt.ErrNoMACKey) {
+		logrus.Debug("key can't authenticate the config - skipping the integrity check")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%s: %w", c.Path, ErrUnsealed)
+}
+
+// Sealed reports whether the config has an integrity MAC.
+func (c *Config) Sealed() bool {
+	return strings.HasPrefix(c.Integrity, integrityPrefix)
+}
+
+// sealed reports whether the config has been sealed or verified on this
+// machine before. Legacy configs whose key can't be loaded, or can't
+// produce a MAC, never have been.
+func (c *Config) sealed() (bool, error) {
+	keeper, err := c.Keeper()
+	if err != nil {
+		logrus.WithError(err).Debug("failed to load the key of a legacy config")
+		return false, nil
+	}
+
+	sealed, err := keeper.Sealed()
+	if errors.Is(err, crypt.ErrNoMACKey) {
+		return false, nil
+	}
+
+	return sealed, err
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/ecc"
+)
+
+func TestIntegrity(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.AES256, keyPath))
+
+	newConfig := func() *Config {
+		return &Config{
+			Encryption: Encryption{
+				Type:      crypt.AES256,
+				KeyPath:   keyPath,
+				ProjectID: "project",
+			},
+			Env: Env{
+				"FOO": "ck:v2:aes256:0123456789abcdef:Zm9v",
+				"BAR": "ck:v2:aes256:0123456789abcdef:YmFy",
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(c *Config)
+		err    error
+	}{
+		{"Untouched", func(c *Config) {}, nil},
+		{"Secret removed", func(c *Config) { delete(c.Env, "BAR") }, ErrIntegrity},
+		{"Secret added", func(c *Config) { c.Env["BAZ"] = "ck:v2:aes256:0123456789abcdef:YmF6" }, ErrIntegrity},
+		{"Secret replaced", func(c *Config) { c.Env["FOO"] = c.Env["BAR"] }, ErrIntegrity},
+		{"Project changed", func(c *Config) { c.Encryption.ProjectID = "other" }, ErrIntegrity},
+		{"MAC removed", func(c *Config) { c.Integrity = "" }, ErrIntegrityMissing},
+		{"Padding changed", func(c *Config) { c.Encryption.Padding = &crypt.Padding{Block: 64} }, ErrIntegrity},
+		{"PKCS11 key added", func(c *Config) { c.Encryption.PKCS11 = &crypt.PKCS11Key{Slot: 1, KeyLabel: "other"} }, ErrIntegrity},
+		{"Retired key added", func(c *Config) { c.Encryption.Retired = []Encryption{{Type: crypt.AES256}} }, ErrIntegrity},
+		{"Key path changed", func(c *Config) { c.Encryption.KeyPath = c.Encryption.KeyPath + ".link" }, nil},
+	}
+
+	assert.NoError(os.Symlink(keyPath, keyPath+".link"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			assert.NoError(c.seal())
+			assert.NotEmpty(c.Integrity)
+
+			tt.tamper(c)
+			assert.ErrorIs(c.verify(), tt.err)
+		})
+	}
+}
+
+func TestIntegrityLegacyConfig(t *testing.T) {
+	c := &Config{Env: Env{"FOO": "Zm9v"}}
+	assert.NoError(t, c.verify())
+}
+
+func TestIntegrityStripped(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.AES256, keyPath))
+
+	legacy := &Config{
+		Encryption: Encryption{Type: crypt.AES256, KeyPath: keyPath},
+		Env:        Env{"FOO": "Zm9v"},
+	}
+	assert.NoError(legacy.verify())
+
+	c := &Config{
+		Encryption: Encryption{Type: crypt.AES256, KeyPath: keyPath, ProjectID: "project"},
+		Env:        Env{"FOO": "ck:v2:aes256:0123456789abcdef:Zm9v"},
+	}
+	assert.NoError(c.seal())
+
+	// Once the key has sealed a config, stripping the MAC and the project ID
+	// doesn't make it look like a legacy config any more.
+	assert.ErrorIs(legacy.verify(), ErrIntegrityMissing)
+
+	IgnoreIntegrity = true
+	defer func() { IgnoreIntegrity = false }()
+	assert.NoError(legacy.verify())
+}
+
+func TestIntegrityVerifyMarksSealed(t *testing.T) {
+	assert := assert.New(t)
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.AES256, keyPath))
+
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	c := &Config{
+		Encryption: Encryption{Type: crypt.AES256, KeyPath: keyPath, ProjectID: "project"},
+		Env:        Env{"FOO": "ck:v2:aes256:0123456789abcdef:Zm9v"},
+	}
+	assert.NoError(c.seal())
+
+	// Another machine that has only ever loaded the config is protected too.
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	legacy := &Config{
+		Encryption: Encryption{Type: crypt.AES256, KeyPath: keyPath},
+		Env:        Env{"FOO": "Zm9v"},
+	}
+	assert.NoError(legacy.verify())
+	assert.NoError(c.verify())
+	assert.ErrorIs(legacy.verify(), ErrIntegrityMissing)
+}
+
+func TestIntegrityPublicKeyWriter(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.ECC256, keyPath))
+
+	c := &Config{
+		Encryption: Encryption{Type: crypt.ECC256, KeyPath: keyPath, ProjectID: "project"},
+		Env:        Env{"FOO": "ck:v2:ecc256:0123456789abcdef:Zm9v"},
+	}
+	assert.NoError(c.seal())
+	assert.NoError(c.verify())
+
+	keeper, err := c.Keeper()
+	assert.NoError(err)
+
+	publicKeyPath := filepath.Join(t.TempDir(), ".ckkey.pub")
+	assert.NoError(keeper.WritePublicKey(publicKeyPath))
+
+	// A writer holding only the public key can't verify or seal the config,
+	// but it can still load it and flag it as unsealed.
+	public := *c
+	public.Encryption.KeyPath = publicKeyPath
+	assert.NoError(public.verify())
+	assert.NoError(public.seal())
+	assert.False(public.Sealed())
+	assert.NoError(public.verify())
+
+	// Whoever holds the private key refuses it until it's resealed.
+	public.Encryption.KeyPath = keyPath
+	assert.ErrorIs(public.verify(), ErrUnsealed)
+	assert.NoError(public.seal())
+	assert.True(public.Sealed())
+	assert.NoError(public.verify())
+}
+
+func TestIntegrityMissingECC256(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.ECC256, keyPath))
+
+	c := &Config{
+		Encryption: Encryption{Type: crypt.ECC256, KeyPath: keyPath, ProjectID: "project"},
+		Env:        Env{"FOO": "ck:v2:ecc256:0123456789abcdef:Zm9v"},
+	}
+	assert.NoError(c.seal())
+
+	c.Integrity = ""
+	assert.ErrorIs(c.verify(), ErrIntegrityMissing)
+}
+
+func TestIntegrityKeyProviderNotRun(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	// Neither config gets as far as looking the provider up, which would
+	// fail with another error.
+	provider := &crypt.KeyProviderKey{Name: "ck-test-missing", WrappedKey: "wrapped"}
+
+	stripped := &Config{
+		Encryption: Encryption{Type: crypt.KeyProvider, KeyProvider: provider},
+		Env:        Env{"FOO": "ck:v2:keyprovider:0123456789abcdef:Zm9v"},
+	}
+	assert.ErrorIs(t, stripped.verify(), ErrIntegrityMissing)
+
+	unsealed := &Config{
+		Encryption: Encryption{Type: crypt.KeyProvider, KeyProvider: provider, ProjectID: "project"},
+		Env:        Env{"FOO": "ck:v2:keyprovider:0123456789abcdef:Zm9v"},
+		Integrity:  unsealedIntegrity,
+	}
+	assert.ErrorIs(t, unsealed.verify(), ErrUnsealed)
+}
+
+func TestIntegrityLegacyECCKeySupplied(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	// Key files from before the MAC key existed have none.
+	keys := ecc.Keys{}
+	assert.NoError(keys.GenerateKeyPair())
+	plain, err := json.Marshal(keys)
+	assert.NoError(err)
+	assert.NotContains(string(plain), "mac_key")
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(os.WriteFile(keyPath, plain, 0600))
+
+	c := &Config{
+		Encryption: Encryption{Type: crypt.ECC256, KeyPath: keyPath, ProjectID: "project"},
+		Env:        Env{"FOO": "ck:v2:ecc256:0123456789abcdef:Zm9v"},
+	}
+	assert.NoError(c.seal())
+	assert.True(c.Sealed())
+
+	// Loading the config derives the MAC key in memory: the key file isn't
+	// rewritten, and a key supplied by CK_KEY works as well.
+	assert.NoError(c.verify())
+	onDisk, err := os.ReadFile(keyPath)
+	assert.NoError(err)
+	assert.Equal(plain, onDisk)
+
+	t.Setenv(crypt.KeyEnvVar, base64.StdEncoding.EncodeToString(plain))
+	assert.NoError(c.verify())
+}
+
+func TestSealRecordsFingerprint(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, ".ckkey")
+	otherPath := filepath.Join(dir, "other")
+	assert.NoError(crypt.GenerateKeys(crypt.AES256, keyPath))
+	assert.NoError(crypt.GenerateKeys(crypt.AES256, otherPath))
+
+	c := &Config{
+		Encryption: Encryption{Type: crypt.AES256, KeyPath: keyPath, ProjectID: "project"},
+		Env:        Env{"FOO": "ck:v2:aes256:0123456789abcdef:Zm9v"},
+	}
+	assert.NoError(c.seal())
+	assert.NotEmpty(c.Encryption.Fingerprint)
+
+	keeper, err := c.Keeper()
+	assert.NoError(err)
+
+	fingerprint, err := keeper.Fingerprint()
+	assert.NoError(err)
+	assert.Equal(fingerprint, c.Encryption.Fingerprint)
+
+	// The fingerprint isn't replaced, so the config refuses other keys.
+	c.Encryption.KeyPath = otherPath
+	assert.ErrorIs(c.seal(), crypt.ErrWrongKey)
+	assert.ErrorIs(c.verify(), crypt.ErrWrongKey)
+	assert.Equal(fingerprint, c.Encryption.Fingerprint)
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+)
+
+// homePrefix marks key paths relative to the home directory.
+const homePrefix = "~/"
+
+// portableKeyPath returns keyPath as it's written to a config in dir, so the
+// config works for everyone who checks it out. Keys inside dir are written
+// relative to it, keys under the home directory relative to that, and keys
+// in the key store not at all, since they're found by their fingerprint.
+// Paths use forward slashes on every OS.
+func portableKeyPath(keyPath, dir string) string {
+	if !filepath.IsAbs(keyPath) {
+		return keyPath
+	}
+	if crypt.InKeyStore(keyPath) {
+		return ""
+	}
+
+	if rel, ok := relativePath(dir, keyPath); ok {
+		return filepath.ToSlash(rel)
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		if rel, ok := relativePath(home, keyPath); ok {
+			return homePrefix + filepath.ToSlash(rel)
+		}
+	}
+
+	return keyPath
+}
+
+// resolveKeyPath turns a key path read from a config in dir into an
+// absolute path. A config without a key path uses the key file next to it,
+// or the key with its fingerprint in the key store. So does a config whose
+// key file is missing.
+func resolveKeyPath(keyPath, dir, fingerprint string) string {
+	switch {
+	case keyPath == "":
+		keyPath = filepath.Join(dir, KeyFileName())
+	case strings.HasPrefix(keyPath, homePrefix):
+		if home, err := os.UserHomeDir(); err == nil {
+			keyPath = filepath.Join(home, filepath.FromSlash(strings.TrimPrefix(keyPath, homePrefix)))
+		}
+	case !filepath.IsAbs(keyPath):
+		keyPath = filepath.Join(dir, filepath.FromSlash(keyPath))
+	}
+
+	return crypt.FindKey(keyPath, fingerprint)
+}
+
+// relativePath returns path relative to dir if it's inside dir.
+func relativePath(dir, path string) (string, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return rel, true
+}
+
+// resolveKeyPaths makes the key paths of the config and its retired keys
+// absolute once it's been read.
+func (c *Config) resolveKeyPaths() {
+	dir := filepath.Dir(c.Path)
+
+	if !crypt.WrapsDataKey(c.Encryption.Type) {
+		c.Encryption.KeyPath = resolveKeyPath(c.Encryption.KeyPath, dir, c.Encryption.Fingerprint)
+	}
+	for i := range c.Encryption.Retired {
+		retired := &c.Encryption.Retired[i]
+		if !crypt.WrapsDataKey(retired.Type) {
+			retired.KeyPath = resolveKeyPath(retired.KeyPath, dir, retired.Fingerprint)
+		}
+	}
+}
+
+// hasKeyFile reports whether the key is one the key file at the key path
+// holds for the whole project. Every member of a project with recipients
+// has their own key, and the keys wrapping data keys never leave their HSM
+// or Vault.
+func (e Encryption) hasKeyFile() bool {
+	return !crypt.HasRecipients(e.Type) && !crypt.WrapsDataKey(e.Type)
+}
+
+// portable returns a copy of e with portable key paths for a config in dir.
+func (e Encryption) portable(dir string) Encryption {
+	e.KeyPath = portableKeyPath(e.KeyPath, dir)
+
+	retired := make([]Encryption, len(e.Retired))
+	for i, r := range e.Retired {
+		retired[i] = r.portable(dir)
+	}
+	if len(retired) > 0 {
+		e.Retired = retired
+	}
+
+	return e
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+)
+
+func TestPortableKeyPath(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	home, err := os.UserHomeDir()
+	assert.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "project")
+	storePath, err := crypt.KeyStorePath("SHA256:" + strings.Repeat("0123456789abcdef", 4))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		keyPath  string
+		expected string
+	}{
+		{"Next to the config", filepath.Join(dir, ".ckkey"), ".ckkey"},
+		{"Inside the project", filepath.Join(dir, "keys", ".ckkey"), "keys/.ckkey"},
+		{"Under home", filepath.Join(home, ".cryptkeeper-test", ".ckkey"), "~/.cryptkeeper-test/.ckkey"},
+		{"In the key store", storePath, ""},
+		{"Already relative", "keys/.ckkey", "keys/.ckkey"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, portableKeyPath(tt.keyPath, dir))
+		})
+	}
+}
+
+func TestResolveKeyPath(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	home, err := os.UserHomeDir()
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.AES256, keyPath))
+
+	keeper, err := crypt.NewKeeper(crypt.AES256, keyPath)
+	assert.NoError(err)
+	fingerprint, err := keeper.Fingerprint()
+	assert.NoError(err)
+
+	assert.Equal(keyPath, resolveKeyPath("", dir, fingerprint))
+	assert.Equal(filepath.Join(dir, "keys", ".ckkey"), resolveKeyPath("keys/.ckkey", dir, ""))
+	assert.Equal(filepath.Join(home, "keys", ".ckkey"), resolveKeyPath("~/keys/.ckkey", dir, ""))
+
+	storedPath, err := crypt.StoreKey(keyPath, fingerprint)
+	assert.NoError(err)
+	assert.NoFileExists(keyPath)
+
+	assert.Equal(storedPath, resolveKeyPath("", dir, fingerprint))
+	assert.Equal(storedPath, resolveKeyPath(".ckkey", dir, fingerprint))
+	assert.Equal(keyPath, resolveKeyPath("", dir, ""))
+}
+
+func TestKeyStoreConfig(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.AES256, keyPath))
+
+	c := &Config{
+		Path:       filepath.Join(dir, ".ckrc"),
+		Encryption: Encryption{Type: crypt.AES256, KeyPath: keyPath, ProjectID: "project"},
+		Env:        Env{},
+	}
+	assert.NoError(c.seal())
+
+	storedPath, err := crypt.StoreKey(keyPath, c.Encryption.Fingerprint)
+	assert.NoError(err)
+	c.Encryption.KeyPath = storedPath
+
+	b, err := c.MarshalJSON()
+	assert.NoError(err)
+	assert.NotContains(string(b), "key_path")
+
+	var read Config
+	assert.NoError(json.Unmarshal(b, &read))
+	read.Path = c.Path
+	read.resolveKeyPaths()
+	assert.Equal(storedPath, read.Encryption.KeyPath)
+
+	keeper, err := read.Keeper()
+	assert.NoError(err)
+	fingerprint, err := keeper.Fingerprint()
+	assert.NoError(err)
+	assert.Equal(c.Encryption.Fingerprint, fingerprint)
+}
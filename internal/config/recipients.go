@@ -0,0 +1,130 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+)
+
+const (
+	recipientsFileName = ".ckrecipients"
+
+	recipientsHeader = "# cryptkeeper recipients - every secret is encrypted to each public key below.\n# Use 'cryptkeeper recipients add/remove' to change them, editing this file\n# doesn't give anyone access.\n"
+)
+
+// Recipient is a team member's public key with an optional name to tell
+// them apart.
+type Recipient struct {
+	Key  string
+	Name string
+}
+
+// Recipients are the public keys X25519 and age projects encrypt to. They're
+// kept in .ckrecipients next to the config, one per line:
+//
+//	<public key> [name]
+//
+// SSH public keys are written the way they are in authorized_keys, so
+// their comment becomes the name.
+type Recipients []Recipient
+
+// ParseRecipient parses a line of the recipients file for type t.
+func ParseRecipient(t crypt.EncryptionType, line string) (Recipient, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Recipient{}, errors.New("empty recipient")
+	}
+
+	// SSH public keys are "<type> <key>".
+	n := 1
+	if strings.HasPrefix(fields[0], "ssh-") && len(fields) > 1 {
+		n = 2
+	}
+
+	r := Recipient{
+		Key:  strings.Join(fields[:n], " "),
+		Name: strings.Join(fields[n:], " "),
+	}
+
+	if err := crypt.ValidateRecipient(t, r.Key); err != nil {
+		return Recipient{}, err
+	}
+
+	return r, nil
+}
+
+func (r Recipients) Keys() []string {
+	keys := make([]string, 0, len(r))
+	for _, recipient := range r {
+		keys = append(keys, recipient.Key)
+	}
+
+	return keys
+}
+
+// Find returns the index of the recipient with the given public key or
+// name, or -1 if there isn't one.
+func (r Recipients) Find(keyOrName string) int {
+	for i, recipient := range r {
+		if recipient.Key == keyOrName || (recipient.Name != "" && recipient.Name == keyOrName) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// RecipientsPath returns the path of the recipients file for the config.
+func (c *Config) RecipientsPath() string {
+	return filepath.Join(filepath.Dir(c.Path), recipientsFileName)
+}
+
+func readRecipients(path string, t crypt.EncryptionType) (Recipients, error) {
+	b, err := fileutils.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %w", err)
+	}
+
+	var recipients Recipients
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r, err := ParseRecipient(t, line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+
+		recipients = append(recipients, r)
+	}
+
+	return recipients, nil
+}
+
+func writeRecipients(path string, recipients Recipients) error {
+	var b strings.Builder
+
+	b.WriteString(recipientsHeader)
+	for _, recipient := range recipients {
+		b.WriteString(strings.TrimSpace(recipient.Key + " " + recipient.Name))
+		b.WriteString("\n")
+	}
+
+	return fileutils.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// payload is the canonical form of the recipients covered by the
+// integrity MAC, so nobody can quietly add their key to the list.
+func (r Recipients) payload() string {
+	keys := r.Keys()
+	sort.Strings(keys)
+
+	return strings.Join(keys, "\n")
+}
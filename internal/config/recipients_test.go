@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/x25519"
+)
+
+func newRecipient(t *testing.T, keyPath string) string {
+	t.Helper()
+
+	assert.NoError(t, crypt.GenerateKeys(crypt.X25519, keyPath))
+
+	keeper, err := crypt.NewKeeper(crypt.X25519, keyPath)
+	assert.NoError(t, err)
+
+	recipient, err := keeper.Recipient()
+	assert.NoError(t, err)
+
+	return recipient
+}
+
+func TestRecipientsFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, recipientsFileName)
+
+	recipients := Recipients{
+		{Key: newRecipient(t, filepath.Join(dir, "alice")), Name: "alice"},
+		{Key: newRecipient(t, filepath.Join(dir, "bob"))},
+	}
+
+	assert.NoError(writeRecipients(path, recipients))
+
+	read, err := readRecipients(path, crypt.X25519)
+	assert.NoError(err)
+	assert.Equal(recipients, read)
+
+	assert.Equal(0, read.Find("alice"))
+	assert.Equal(1, read.Find(recipients[1].Key))
+	assert.Equal(-1, read.Find("carol"))
+
+	assert.NoError(os.WriteFile(path, []byte("ck-x25519:nope carol\n"), 0644))
+
+	_, err = readRecipients(path, crypt.X25519)
+	assert.ErrorIs(err, x25519.ErrInvalidRecipient)
+}
+
+func TestIntegrityRecipients(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, ".ckkey")
+	alice := newRecipient(t, keyPath)
+	eve := newRecipient(t, filepath.Join(dir, "eve"))
+
+	teamSecret, err := crypt.NewTeamSecret(crypt.X25519, []string{alice})
+	assert.NoError(err)
+
+	c := &Config{
+		Encryption: Encryption{
+			Type:       crypt.X25519,
+			KeyPath:    keyPath,
+			ProjectID:  "project",
+			TeamSecret: teamSecret,
+		},
+		Env:        Env{"FOO": "ck:v2:x25519:0123456789abcdef:Zm9v"},
+		Recipients: Recipients{{Key: alice}},
+	}
+	assert.NoError(c.seal())
+	assert.NoError(c.verify())
+
+	// Every member has their own key, so there's no one key to expect.
+	assert.Empty(c.Encryption.Fingerprint)
+
+	// Adding a key to the recipients file doesn't give anyone access.
+	c.Recipients = append(c.Recipients, Recipient{Key: eve, Name: "eve"})
+	assert.ErrorIs(c.verify(), ErrIntegrity)
+}
@@ -8,6 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
 )
 
 type EncryptionKey struct {
@@ -18,6 +22,28 @@ type AES256 struct {
 	aead cipher.AEAD
 }
 
+// ID returns the short identifier recorded next to every value encrypted
+// with this key.
+func (e *EncryptionKey) ID() string {
+	return keyid.New(e.Key)
+}
+
+// Fingerprint identifies the key in full, so two key files can be compared
+// without looking at the key itself.
+func (e *EncryptionKey) Fingerprint() string {
+	return keyid.Fingerprint(e.Key)
+}
+
+// Secret returns the raw key, which other keys can be derived from.
+func (e *EncryptionKey) Secret() []byte {
+	return e.Key
+}
+
+// LockMemory moves the key into memory that won't be swapped to disk.
+func (e *EncryptionKey) LockMemory() {
+	e.Key = securemem.Lock(e.Key)
+}
+
 func GenerateKeys() (*EncryptionKey, error) {
 	key := make([]byte, 32)
 	if _, err := io.ReadFull(rand.Reader, key); err != nil {
@@ -27,8 +53,9 @@ func GenerateKeys() (*EncryptionKey, error) {
 	return &EncryptionKey{key}, nil
 }
 
-// Encrypt encrypts the given plaintext with the given key using AES-256-GCM
-func (a *AES256) Encrypt(plaintext string, key any) (string, error) {
+// Encrypt encrypts the given plaintext with the given key using AES-256-GCM,
+// authenticating additionalData alongside it.
+func (a *AES256) Encrypt(plaintext string, key any, additionalData []byte) (string, error) {
 	e, ok := key.(*EncryptionKey)
 	if !ok {
 		return "", errors.New("invalid encryption key")
@@ -51,13 +78,14 @@ func (a *AES256) Encrypt(plaintext string, key any) (string, error) {
 		return "", err
 	}
 
-	ciphertext := a.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	ciphertext := a.aead.Seal(nonce, nonce, []byte(plaintext), additionalData)
 
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-// Decrypt decrypts the given ciphertext with the given key using AES-256-GCM
-func (a *AES256) Decrypt(cipherText string, key any) (string, error) {
+// Decrypt decrypts the given ciphertext with the given key using AES-256-GCM.
+// additionalData must match what was passed to Encrypt.
+func (a *AES256) Decrypt(cipherText string, key any, additionalData []byte) (string, error) {
 	e, ok := key.(*EncryptionKey)
 	if !ok {
 		return "", errors.New("invalid encryption key")
@@ -87,7 +115,8 @@ func (a *AES256) Decrypt(cipherText string, key any) (string, error) {
 
 	nonce, cipher := rawCiphertext[:nonceSize], rawCiphertext[nonceSize:]
 
-	b, err := a.aead.Open(nil, nonce, cipher, nil)
+	b, err := authenc.Open(a.aead, nonce, cipher, additionalData)
+	defer securemem.Wipe(b)
 
 	return string(b), err
 }
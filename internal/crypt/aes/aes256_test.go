@@ -28,13 +28,34 @@ func TestAES256(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Encryption
-			cipherText, err := a.Encrypt(tt.plaintext, key)
+			cipherText, err := a.Encrypt(tt.plaintext, key, nil)
 			assert.NoError(err)
 
 			// Decryption
-			decrypted, err := a.Decrypt(cipherText, key)
+			decrypted, err := a.Decrypt(cipherText, key, nil)
 			assert.NoError(err)
 			assert.Equal(tt.plaintext, string(decrypted))
 		})
 	}
 }
+
+func TestAdditionalData(t *testing.T) {
+	assert := assert.New(t)
+	a := &aes.AES256{}
+
+	key, err := aes.GenerateKeys()
+	assert.NoError(err)
+
+	ciphertext, err := a.Encrypt("secret", key, []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := a.Decrypt(ciphertext, key, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = a.Decrypt(ciphertext, key, []byte("BAR"))
+	assert.Error(err)
+
+	_, err = a.Decrypt(ciphertext, key, nil)
+	assert.Error(err)
+}
@@ -0,0 +1,106 @@
+package aessiv
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+// KeySize is the size of AES-SIV keys with AES-256 for both S2V and CTR.
+const KeySize = 64
+
+type EncryptionKey struct {
+	Key []byte `json:"key"`
+}
+
+// AES256SIV encrypts with AES-SIV (RFC 5297) using AES-256. It's
+// deterministic: the same key, additional data and plaintext always give
+// the same ciphertext, so unchanged values don't change when they're
+// encrypted again. The cost is that anyone who can see two ciphertexts
+// made with the same additional data can tell whether they hold the same
+// value.
+type AES256SIV struct {
+	siv *siv
+}
+
+// ID returns the short identifier recorded next to every value encrypted
+// with this key.
+func (e *EncryptionKey) ID() string {
+	return keyid.New(e.Key)
+}
+
+// Fingerprint identifies the key in full, so two key files can be compared
+// without looking at the key itself.
+func (e *EncryptionKey) Fingerprint() string {
+	return keyid.Fingerprint(e.Key)
+}
+
+// Secret returns the raw key, which other keys can be derived from.
+func (e *EncryptionKey) Secret() []byte {
+	return e.Key
+}
+
+// LockMemory moves the key into memory that won't be swapped to disk.
+func (e *EncryptionKey) LockMemory() {
+	e.Key = securemem.Lock(e.Key)
+}
+
+func GenerateKeys() (*EncryptionKey, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	return &EncryptionKey{key}, nil
+}
+
+// Encrypt encrypts the given plaintext with the given key using AES-SIV,
+// authenticating additionalData alongside it.
+func (a *AES256SIV) Encrypt(plaintext string, key any, additionalData []byte) (string, error) {
+	e, ok := key.(*EncryptionKey)
+	if !ok {
+		return "", errors.New("invalid encryption key")
+	}
+
+	if a.siv == nil {
+		var err error
+		a.siv, err = newSIV(e.Key)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	ciphertext := a.siv.seal([]byte(plaintext), additionalData)
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt decrypts the given ciphertext with the given key using AES-SIV.
+// additionalData must match what was passed to Encrypt.
+func (a *AES256SIV) Decrypt(cipherText string, key any, additionalData []byte) (string, error) {
+	e, ok := key.(*EncryptionKey)
+	if !ok {
+		return "", errors.New("invalid encryption key")
+	}
+
+	rawCiphertext, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", err
+	}
+
+	if a.siv == nil {
+		a.siv, err = newSIV(e.Key)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	b, err := a.siv.open(rawCiphertext, additionalData)
+	defer securemem.Wipe(b)
+
+	return string(b), err
+}
@@ -0,0 +1,85 @@
+package aessiv_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/aessiv"
+)
+
+func TestAES256SIV(t *testing.T) {
+	assert := assert.New(t)
+	key, err := aessiv.GenerateKeys()
+	assert.NoError(err)
+
+	a := &aessiv.AES256SIV{}
+
+	// Table-driven tests
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"Normal text", "Hello, world!"},
+		{"Empty text", ""},
+		{"Special characters", "!@#$\n%^\t&*()"},
+		{"Long text", "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Vivamus lacinia odio vitae vestibulum."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Encryption
+			cipherText, err := a.Encrypt(tt.plaintext, key, nil)
+			assert.NoError(err)
+
+			// Decryption
+			decrypted, err := a.Decrypt(cipherText, key, nil)
+			assert.NoError(err)
+			assert.Equal(tt.plaintext, string(decrypted))
+		})
+	}
+}
+
+func TestAdditionalData(t *testing.T) {
+	assert := assert.New(t)
+	a := &aessiv.AES256SIV{}
+
+	key, err := aessiv.GenerateKeys()
+	assert.NoError(err)
+
+	ciphertext, err := a.Encrypt("secret", key, []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := a.Decrypt(ciphertext, key, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = a.Decrypt(ciphertext, key, []byte("BAR"))
+	assert.Error(err)
+
+	_, err = a.Decrypt(ciphertext, key, nil)
+	assert.Error(err)
+}
+
+func TestDeterministic(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := aessiv.GenerateKeys()
+	assert.NoError(err)
+
+	first, err := new(aessiv.AES256SIV).Encrypt("secret", key, []byte("FOO"))
+	assert.NoError(err)
+
+	again, err := new(aessiv.AES256SIV).Encrypt("secret", key, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal(first, again)
+
+	changed, err := new(aessiv.AES256SIV).Encrypt("secret2", key, []byte("FOO"))
+	assert.NoError(err)
+	assert.NotEqual(first, changed)
+
+	// Equal values of different secrets don't look equal.
+	other, err := new(aessiv.AES256SIV).Encrypt("secret", key, []byte("BAR"))
+	assert.NoError(err)
+	assert.NotEqual(first, other)
+}
@@ -0,0 +1,174 @@
+package aessiv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
+)
+
+// siv implements AES-SIV from RFC 5297. The first half of the key keys
+// S2V, which is built on CMAC, and the second half keys AES-CTR.
+type siv struct {
+	mac *cmac
+	ctr cipher.Block
+}
+
+func newSIV(key []byte) (*siv, error) {
+	if len(key) != 32 && len(key) != 48 && len(key) != 64 {
+		return nil, aes.KeySizeError(len(key))
+	}
+
+	half := len(key) / 2
+
+	macBlock, err := aes.NewCipher(key[:half])
+	if err != nil {
+		return nil, err
+	}
+
+	ctrBlock, err := aes.NewCipher(key[half:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &siv{mac: newCMAC(macBlock), ctr: ctrBlock}, nil
+}
+
+// seal returns the synthetic IV followed by the ciphertext.
+func (s *siv) seal(plaintext []byte, additionalData ...[]byte) []byte {
+	v := s.s2v(plaintext, additionalData)
+
+	out := make([]byte, aes.BlockSize+len(plaintext))
+	copy(out, v)
+	s.xorCTR(out[aes.BlockSize:], plaintext, v)
+
+	return out
+}
+
+func (s *siv) open(ciphertext []byte, additionalData ...[]byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize {
+		return nil, authenc.ErrOpen
+	}
+
+	v := ciphertext[:aes.BlockSize]
+
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
+	s.xorCTR(plaintext, ciphertext[aes.BlockSize:], v)
+
+	if subtle.ConstantTimeCompare(v, s.s2v(plaintext, additionalData)) != 1 {
+		return nil, authenc.ErrOpen
+	}
+
+	return plaintext, nil
+}
+
+// xorCTR runs AES-CTR with the synthetic IV as the counter, after clearing
+// the two bits RFC 5297 clears so implementations can use 64 bit counters.
+func (s *siv) xorCTR(dst, src, v []byte) {
+	q := make([]byte, aes.BlockSize)
+	copy(q, v)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+
+	cipher.NewCTR(s.ctr, q).XORKeyStream(dst, src)
+}
+
+// s2v turns the associated data strings and the plaintext into a single
+// synthetic IV, as described in section 2.4 of RFC 5297.
+func (s *siv) s2v(plaintext []byte, additionalData [][]byte) []byte {
+	d := s.mac.sum(make([]byte, aes.BlockSize))
+
+	for _, ad := range additionalData {
+		d = dbl(d)
+		xor(d, s.mac.sum(ad))
+	}
+
+	if len(plaintext) >= aes.BlockSize {
+		t := make([]byte, len(plaintext))
+		copy(t, plaintext)
+		xor(t[len(t)-aes.BlockSize:], d)
+
+		return s.mac.sum(t)
+	}
+
+	d = dbl(d)
+	xor(d, pad(plaintext))
+
+	return s.mac.sum(d)
+}
+
+// cmac is the CMAC message authentication code from RFC 4493.
+type cmac struct {
+	block  cipher.Block
+	k1, k2 []byte
+}
+
+func newCMAC(block cipher.Block) *cmac {
+	l := make([]byte, aes.BlockSize)
+	block.Encrypt(l, l)
+
+	k1 := dbl(l)
+
+	return &cmac{block: block, k1: k1, k2: dbl(k1)}
+}
+
+func (c *cmac) sum(msg []byte) []byte {
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	complete := n > 0 && len(msg)%aes.BlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+
+	var last []byte
+	if complete {
+		last = make([]byte, aes.BlockSize)
+		copy(last, msg[(n-1)*aes.BlockSize:])
+		xor(last, c.k1)
+	} else {
+		last = pad(msg[(n-1)*aes.BlockSize:])
+		xor(last, c.k2)
+	}
+
+	x := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		xor(x, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		c.block.Encrypt(x, x)
+	}
+
+	xor(x, last)
+	c.block.Encrypt(x, x)
+
+	return x
+}
+
+// dbl multiplies a block by x in GF(2^128).
+func dbl(b []byte) []byte {
+	out := make([]byte, aes.BlockSize)
+
+	var carry byte
+	for i := aes.BlockSize - 1; i >= 0; i-- {
+		out[i] = b[i]<<1 | carry
+		carry = b[i] >> 7
+	}
+
+	// Constant time reduction by x^128 + x^7 + x^2 + x + 1.
+	out[aes.BlockSize-1] ^= 0x87 & -carry
+
+	return out
+}
+
+// pad appends a single one bit and zeros to a partial block.
+func pad(b []byte) []byte {
+	out := make([]byte, aes.BlockSize)
+	copy(out, b)
+	out[len(b)] = 0x80
+
+	return out
+}
+
+func xor(dst, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,98 @@
+package aessiv
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	assert.NoError(t, err)
+
+	return b
+}
+
+// Test vectors from RFC 4493, section 4.
+func TestCMAC(t *testing.T) {
+	key := "2b7e1516 28aed2a6 abf71588 09cf4f3c"
+
+	tests := []struct {
+		name    string
+		message string
+		mac     string
+	}{
+		{"Empty", "", "bb1d6929 e9593728 7fa37d12 9b756746"},
+		{"One block", "6bc1bee2 2e409f96 e93d7e11 7393172a", "070a16b4 6b4d4144 f79bdd9d d04a287c"},
+		{"Partial block", "6bc1bee2 2e409f96 e93d7e11 7393172a ae2d8a57 1e03ac9c 9eb76fac 45af8e51 30c81c46 a35ce411", "dfa66747 de9ae630 30ca3261 1497c827"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := aes.NewCipher(unhex(t, key))
+			assert.NoError(t, err)
+
+			assert.Equal(t, unhex(t, tt.mac), newCMAC(block).sum(unhex(t, tt.message)))
+		})
+	}
+}
+
+// Test vectors from RFC 5297, appendix A.
+func TestSIV(t *testing.T) {
+	tests := []struct {
+		name           string
+		key            string
+		additionalData []string
+		plaintext      string
+		output         string
+	}{
+		{
+			name:           "Deterministic authenticated encryption",
+			key:            "fffefdfc fbfaf9f8 f7f6f5f4 f3f2f1f0 f0f1f2f3 f4f5f6f7 f8f9fafb fcfdfeff",
+			additionalData: []string{"10111213 14151617 18191a1b 1c1d1e1f 20212223 24252627"},
+			plaintext:      "11223344 55667788 99aabbcc ddee",
+			output:         "85632d07 c6e8f37f 950acd32 0a2ecc93 40c02b96 90c4dc04 daef7f6a fe5c",
+		},
+		{
+			name: "Nonce-based authenticated encryption",
+			key:  "7f7e7d7c 7b7a7978 77767574 73727170 40414243 44454647 48494a4b 4c4d4e4f",
+			additionalData: []string{
+				"00112233 44556677 8899aabb ccddeeff deaddada deaddada ffeeddcc bbaa9988 77665544 33221100",
+				"10203040 50607080 90a0",
+				"09f91102 9d74e35b d84156c5 635688c0",
+			},
+			plaintext: "74686973 20697320 736f6d65 20706c61 696e7465 78742074 6f20656e 63727970 74207573 696e6720 5349562d 414553",
+			output:    "7bdb6e3b 432667eb 06f4d14b ff2fbd0f cb900f2f ddbe4043 26601965 c889bf17 dba77ceb 094fa663 b7a3f748 ba8af829 ea64ad54 4a272e9c 485b62a3 fd5c0d",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			s, err := newSIV(unhex(t, tt.key))
+			assert.NoError(err)
+
+			var additionalData [][]byte
+			for _, ad := range tt.additionalData {
+				additionalData = append(additionalData, unhex(t, ad))
+			}
+
+			output := s.seal(unhex(t, tt.plaintext), additionalData...)
+			assert.Equal(unhex(t, tt.output), output)
+
+			plaintext, err := s.open(output, additionalData...)
+			assert.NoError(err)
+			assert.Equal(unhex(t, tt.plaintext), plaintext)
+
+			output[len(output)-1] ^= 1
+			_, err = s.open(output, additionalData...)
+			assert.Error(err)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package age
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+// ArmorHeader starts every value written by Age.
+const ArmorHeader = armor.Header
+
+// Age writes values in age's armored format, so they can be decrypted with
+// the age CLI as well:
+//
+//	age -d -i ~/.ssh/id_ed25519
+//
+// The age format has no associated data, so values aren't bound to their
+// secret name or project. The config's integrity MAC still covers which
+// value belongs to which secret.
+type Age struct{}
+
+// Encrypt encrypts plaintext to the given []age.Recipient. additionalData
+// is ignored.
+func (a *Age) Encrypt(plaintext string, key any, additionalData []byte) (string, error) {
+	recipients, ok := key.([]age.Recipient)
+	if !ok {
+		return "", errors.New("invalid age encryption key")
+	}
+
+	sealed, err := Seal([]byte(plaintext), recipients)
+	if err != nil {
+		return "", err
+	}
+
+	return string(sealed), nil
+}
+
+// Decrypt decrypts a value with the given *Keys. additionalData is ignored.
+func (a *Age) Decrypt(ciphertext string, key any, additionalData []byte) (string, error) {
+	keys, ok := key.(*Keys)
+	if !ok {
+		return "", errors.New("invalid age decryption key")
+	}
+
+	plaintext, err := Open([]byte(ciphertext), keys)
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(plaintext)
+
+	return string(plaintext), nil
+}
+
+// IsValue reports whether value was written by Age.
+func IsValue(value string) bool {
+	return strings.HasPrefix(value, ArmorHeader)
+}
+
+// Seal encrypts plaintext to recipients in the armored age format.
+func Seal(plaintext []byte, recipients []age.Recipient) ([]byte, error) {
+	var buf bytes.Buffer
+
+	a := armor.NewWriter(&buf)
+	w, err := age.Encrypt(a, recipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := a.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Open decrypts an armored age file with keys.
+func Open(sealed []byte, keys *Keys) ([]byte, error) {
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(sealed)), keys.Identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,189 @@
+package age_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	fage "filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/age"
+)
+
+// newSSHKey returns an OpenSSH ed25519 private key and its public key line.
+func newSSHKey(t *testing.T, passphrase []byte) ([]byte, string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	var block *pem.Block
+	if passphrase == nil {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", passphrase)
+	}
+	assert.NoError(t, err)
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(block), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+}
+
+func parseKeys(t *testing.T, b []byte) *age.Keys {
+	t.Helper()
+
+	keys, err := age.ParseKeys(b, nil, nil)
+	assert.NoError(t, err)
+
+	return keys
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	assert := assert.New(t)
+	a := &age.Age{}
+
+	generated, err := age.GenerateKeys()
+	assert.NoError(err)
+	keyFile, err := generated.KeyFile()
+	assert.NoError(err)
+	native := parseKeys(t, keyFile)
+
+	sshKey, sshPub := newSSHKey(t, nil)
+	sshKeys := parseKeys(t, sshKey)
+	assert.Equal(sshPub, sshKeys.Recipient)
+
+	var recipients []fage.Recipient
+	for _, r := range []string{native.Recipient, sshPub} {
+		recipient, err := age.ParseRecipient(r)
+		assert.NoError(err)
+		recipients = append(recipients, recipient)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"Normal text", "Hello, world!"},
+		{"Empty text", ""},
+		{"Special characters", "!@#$\n%^\t&*()"},
+		{"Long text", "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Vivamus lacinia odio vitae vestibulum."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ciphertext, err := a.Encrypt(tt.plaintext, recipients, []byte("ignored"))
+			assert.NoError(err)
+			assert.True(age.IsValue(ciphertext))
+			assert.True(strings.HasPrefix(ciphertext, age.ArmorHeader))
+
+			// Both the age identity and the SSH key decrypt it.
+			for _, keys := range []*age.Keys{native, sshKeys} {
+				decrypted, err := a.Decrypt(ciphertext, keys, nil)
+				assert.NoError(err)
+				assert.Equal(tt.plaintext, decrypted)
+			}
+
+			// Anything that reads armored age files can decrypt it, the
+			// same way 'age -d -i' does.
+			identities, err := fage.ParseIdentities(bytes.NewReader(keyFile))
+			assert.NoError(err)
+			r, err := fage.Decrypt(armor.NewReader(strings.NewReader(ciphertext)), identities...)
+			assert.NoError(err)
+			decrypted, err := io.ReadAll(r)
+			assert.NoError(err)
+			assert.Equal(tt.plaintext, string(decrypted))
+		})
+	}
+}
+
+func TestDecryptNotRecipient(t *testing.T) {
+	assert := assert.New(t)
+	a := &age.Age{}
+
+	alice, err := age.GenerateKeys()
+	assert.NoError(err)
+	bob, err := age.GenerateKeys()
+	assert.NoError(err)
+
+	recipient, err := age.ParseRecipient(alice.Recipient)
+	assert.NoError(err)
+
+	ciphertext, err := a.Encrypt("secret", []fage.Recipient{recipient}, nil)
+	assert.NoError(err)
+
+	_, err = a.Decrypt(ciphertext, bob, nil)
+	var noMatch *fage.NoIdentityMatchError
+	assert.True(errors.As(err, &noMatch))
+}
+
+func TestParseKeysProtectedSSHKey(t *testing.T) {
+	assert := assert.New(t)
+	a := &age.Age{}
+
+	sshKey, sshPub := newSSHKey(t, []byte("hunter2"))
+	assert.True(age.IsSSHKey(sshKey))
+
+	// OpenSSH keys carry their public key in the clear, so the .pub file
+	// isn't needed.
+	var asked int
+	keys, err := age.ParseKeys(sshKey, nil, func() ([]byte, error) {
+		asked++
+		return []byte("hunter2"), nil
+	})
+	assert.NoError(err)
+	assert.Equal(sshPub, keys.Recipient)
+	assert.Zero(asked, "the passphrase is only needed to decrypt")
+
+	recipient, err := age.ParseRecipient(keys.Recipient)
+	assert.NoError(err)
+
+	ciphertext, err := a.Encrypt("secret", []fage.Recipient{recipient}, nil)
+	assert.NoError(err)
+
+	decrypted, err := a.Decrypt(ciphertext, keys, nil)
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+	assert.Equal(1, asked)
+}
+
+func TestParseRecipient(t *testing.T) {
+	_, sshPub := newSSHKey(t, nil)
+	generated, err := age.GenerateKeys()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		recipient string
+		valid     bool
+	}{
+		{"age public key", generated.Recipient, true},
+		{"SSH public key", sshPub, true},
+		{"SSH public key with comment", sshPub + " alice@example.com", true},
+		{"x25519 public key", "ck-x25519:AAAA", false},
+		{"Truncated age public key", generated.Recipient[:20], false},
+		{"Empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := age.ParseRecipient(tt.recipient)
+			if tt.valid {
+				assert.NoError(err)
+			} else {
+				assert.ErrorIs(err, age.ErrInvalidRecipient)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package age
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+)
+
+var ErrInvalidRecipient = errors.New("invalid recipient - expected an age public key (age1...) or an SSH public key (ssh-ed25519 or ssh-rsa)")
+
+// Keys is an age identity file. It's either age's own identity file, as
+// written by age-keygen, or an SSH private key such as ~/.ssh/id_ed25519.
+type Keys struct {
+	Identities []age.Identity
+
+	// Recipient is the public key that matches the first identity, in the
+	// form ParseRecipient accepts.
+	Recipient string
+
+	raw []byte
+}
+
+// GenerateKeys generates an X25519 identity and writes it the way
+// age-keygen does, so it can be passed to 'age -d -i'.
+func GenerateKeys() (*Keys, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	recipient := identity.Recipient().String()
+	raw := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", time.Now().Format(time.RFC3339), recipient, identity)
+
+	return &Keys{
+		Identities: []age.Identity{identity},
+		Recipient:  recipient,
+		raw:        []byte(raw),
+	}, nil
+}
+
+// IsSSHKey reports whether b is an SSH private key rather than an age
+// identity file.
+func IsSSHKey(b []byte) bool {
+	return bytes.Contains(b, []byte("PRIVATE KEY-----"))
+}
+
+// ParseKeys parses an identity file. Passphrase-protected SSH keys need
+// their public key next to them in a .pub file, like age itself, and
+// passphrase is only called when a value is decrypted.
+func ParseKeys(b []byte, publicKey func() ([]byte, error), passphrase func() ([]byte, error)) (*Keys, error) {
+	if !IsSSHKey(b) {
+		identities, err := age.ParseIdentities(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+
+		x25519, ok := identities[0].(*age.X25519Identity)
+		if !ok {
+			return nil, errors.New("unsupported age identity")
+		}
+
+		return &Keys{Identities: identities, Recipient: x25519.Recipient().String(), raw: b}, nil
+	}
+
+	identity, err := agessh.ParseIdentity(b)
+	if err == nil {
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Keys{Identities: []age.Identity{identity}, Recipient: sshRecipient(signer.PublicKey()), raw: b}, nil
+	}
+
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return nil, err
+	}
+
+	pub := missing.PublicKey
+	if pub == nil {
+		if publicKey == nil {
+			return nil, errors.New("SSH key is protected with a passphrase and its public key is missing")
+		}
+
+		raw, err := publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("SSH key is protected with a passphrase and its public key is missing: %w", err)
+		}
+
+		pub, _, _, _, err = ssh.ParseAuthorizedKey(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	encrypted, err := agessh.NewEncryptedSSHIdentity(pub, b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keys{Identities: []age.Identity{encrypted}, Recipient: sshRecipient(pub), raw: b}, nil
+}
+
+// KeyFile returns the identity file exactly as it's stored.
+func (k *Keys) KeyFile() ([]byte, error) {
+	return k.raw, nil
+}
+
+// ID returns the short identifier of the identity's public key.
+func (k *Keys) ID() string {
+	return keyid.New([]byte(k.Recipient))
+}
+
+// Fingerprint identifies the identity in full by its recipient.
+func (k *Keys) Fingerprint() string {
+	return keyid.Fingerprint([]byte(k.Recipient))
+}
+
+// ParseRecipient parses an age public key or an SSH public key in
+// authorized_keys form. SSH comments are ignored.
+func ParseRecipient(s string) (age.Recipient, error) {
+	if strings.HasPrefix(s, "age1") {
+		r, err := age.ParseX25519Recipient(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+		}
+
+		return r, nil
+	}
+
+	if strings.HasPrefix(s, "ssh-") {
+		r, err := agessh.ParseRecipient(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+		}
+
+		return r, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrInvalidRecipient, s)
+}
+
+func sshRecipient(pub ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+}
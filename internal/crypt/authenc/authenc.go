@@ -0,0 +1,22 @@
+package authenc
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// ErrOpen is returned by every encrypter when a value fails to
+// authenticate, so the keeper can tell it apart from malformed values and
+// wrong keys.
+var ErrOpen = errors.New("message authentication failed")
+
+// Open opens and authenticates ciphertext with a. It returns ErrOpen if the
+// ciphertext or additional data were changed.
+func Open(a cipher.AEAD, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	b, err := a.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrOpen
+	}
+
+	return b, nil
+}
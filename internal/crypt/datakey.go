@@ -0,0 +1,134 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/aes"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+// keyWrapper wraps and unwraps data keys with a key that never leaves an
+// HSM, Vault or key provider.
+type keyWrapper interface {
+	wrap(dataKey []byte) (string, error)
+	unwrap(wrapped string) ([]byte, error)
+
+	// String names the wrapping key, for errors and 'key info'.
+	String() string
+}
+
+var (
+	// dataKeys remembers data keys Vault already unwrapped for this
+	// process, so a command asks Vault once however many keepers it loads.
+	// HSMs and key providers are asked every time, so they can still refuse,
+	// e.g. when the PIN is wrong.
+	dataKeys   = make(map[[sha256.Size]byte][]byte)
+	dataKeysMu sync.Mutex
+)
+
+// newDataKey generates a data key and returns it wrapped by w.
+func newDataKey(w keyWrapper) (string, error) {
+	dataKey, err := aes.GenerateKeys()
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(dataKey.Key)
+
+	return w.wrap(dataKey.Key)
+}
+
+// wrappedDataKeys maps each type that keeps a wrapped data key in the
+// config to the keeper method returning what wraps it and the wrapped data
+// key. The keeper treats every type here alike, so a new backend is added
+// here rather than to the keeper's switches.
+var wrappedDataKeys = map[EncryptionType]func(k *Keeper) (keyWrapper, string, error){
+	PKCS11:       (*Keeper).pkcs11DataKey,
+	VaultTransit: (*Keeper).transitDataKey,
+	KeyProvider:  (*Keeper).providerDataKey,
+}
+
+// keyWrapper returns what wraps the keeper's data key, and the wrapped data
+// key.
+func (k *Keeper) keyWrapper() (keyWrapper, string, error) {
+	dataKey, ok := wrappedDataKeys[k.encryptionType]
+	if !ok {
+		return nil, "", fmt.Errorf("%s doesn't wrap a data key", k.encryptionType)
+	}
+
+	return dataKey(k)
+}
+
+// cipherType is the type of key values are encrypted with. Values under a
+// wrapped data key are AES-256-GCM values.
+func (k *Keeper) cipherType() EncryptionType {
+	if WrapsDataKey(k.encryptionType) {
+		return AES256
+	}
+
+	return k.encryptionType
+}
+
+// fetchDataKey unwraps the data key. Vault isn't asked again for a data key
+// it already unwrapped for this process.
+func (k *Keeper) fetchDataKey() error {
+	w, wrapped, err := k.keyWrapper()
+	if err != nil {
+		return err
+	}
+	k.source = w.String()
+
+	if wrapped == "" {
+		return fmt.Errorf("no wrapped data key for %s", w)
+	}
+
+	if k.encryptionType != VaultTransit {
+		dataKey, err := unwrapDataKey(w, wrapped)
+		if err != nil {
+			return err
+		}
+
+		key := &aes.EncryptionKey{Key: dataKey}
+		key.LockMemory()
+		k.encryptionKey = key
+		return nil
+	}
+
+	id := sha256.Sum256([]byte(strings.Join([]string{string(k.encryptionType), w.String(), wrapped}, "\x00")))
+
+	dataKeysMu.Lock()
+	defer dataKeysMu.Unlock()
+
+	dataKey, ok := dataKeys[id]
+	if !ok {
+		plain, err := unwrapDataKey(w, wrapped)
+		if err != nil {
+			return err
+		}
+
+		dataKey = securemem.Lock(plain)
+		dataKeys[id] = dataKey
+	}
+
+	key := &aes.EncryptionKey{Key: bytes.Clone(dataKey)}
+	key.LockMemory()
+	k.encryptionKey = key
+	return nil
+}
+
+// unwrapDataKey has w unwrap the data key and checks its size.
+func unwrapDataKey(w keyWrapper, wrapped string) ([]byte, error) {
+	plain, err := w.unwrap(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(plain) != 32 {
+		securemem.Wipe(plain)
+		return nil, fmt.Errorf("%s unwrapped a %d-byte data key, expected 32 bytes", w, len(plain))
+	}
+
+	return plain, nil
+}
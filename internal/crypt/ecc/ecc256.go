@@ -1,57 +1,161 @@
 package ecc
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
 	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"io"
 
-	"github.com/sunny-b/cryptkeeper/internal/crypt/aes"
+	cryptaes "github.com/sunny-b/cryptkeeper/internal/crypt/aes"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
 
 	"golang.org/x/crypto/hkdf"
 )
 
+// eciesInfo separates the keys derived for values from anything else derived
+// from the same shared secret.
+const eciesInfo = "cryptkeeper ecies p256 v1"
+
+var ErrInvalidCiphertext = errors.New("invalid ecc ciphertext")
+
+// ECC256 is ECIES over P-256. Every value gets a fresh ephemeral key pair,
+// and the AES-256-GCM key is derived from its agreement with the recipient's
+// public key, so encrypting only needs the public key. The ephemeral public
+// key is stored in front of the ciphertext:
+//
+//	base64(ephemeral public key || nonce || ciphertext)
 type ECC256 struct{}
 
-func (e *ECC256) Encrypt(plaintext string, key interface{}) (string, error) {
-	k, ok := key.(*Key)
+// Encrypt encrypts plaintext to the given *ecdh.PublicKey.
+func (e *ECC256) Encrypt(plaintext string, key any, additionalData []byte) (string, error) {
+	recipient, ok := key.(*ecdh.PublicKey)
 	if !ok {
 		return "", errors.New("invalid ecc encryption key")
 	}
 
-	// Derive shared secret
-	x, _ := elliptic.P256().ScalarMult(k.Private.X, k.Private.Y, k.Private.D.Bytes())
-	sharedSecret := x.Bytes()
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
 
-	// Derive AES key using HKDF
-	hkdf := hkdf.New(sha256.New, sharedSecret, nil, nil)
-	aesKey := make([]byte, 32)
-	_, err := io.ReadFull(hkdf, aesKey)
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(shared, ephemeral.PublicKey(), recipient)
+	securemem.Wipe(shared)
 	if err != nil {
 		return "", err
 	}
 
-	a := new(aes.AES256)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	out := append(ephemeral.PublicKey().Bytes(), nonce...)
+	out = aead.Seal(out, nonce, []byte(plaintext), additionalData)
 
-	return a.Encrypt(plaintext, &aes.EncryptionKey{Key: aesKey})
+	return base64.StdEncoding.EncodeToString(out), nil
 }
 
-func (e *ECC256) Decrypt(ciphertext string, key interface{}) (string, error) {
-	k, ok := key.(*Key)
+// Decrypt decrypts a value with the recipient's *ecdh.PrivateKey. It also
+// accepts the per-secret *Key that values were encrypted with before ECIES.
+func (e *ECC256) Decrypt(ciphertext string, key any, additionalData []byte) (string, error) {
+	if legacy, ok := key.(*Key); ok {
+		return decryptLegacy(ciphertext, legacy, additionalData)
+	}
+
+	recipient, ok := key.(*ecdh.PrivateKey)
 	if !ok {
-		return "", errors.New("invalid decryption key")
+		return "", errors.New("invalid ecc decryption key")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	pointSize := len(recipient.PublicKey().Bytes())
+	if len(data) < pointSize {
+		return "", ErrInvalidCiphertext
+	}
+
+	ephemeral, err := ecdh.P256().NewPublicKey(data[:pointSize])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	shared, err := recipient.ECDH(ephemeral)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(shared, ephemeral, recipient.PublicKey())
+	securemem.Wipe(shared)
+	if err != nil {
+		return "", err
+	}
+
+	data = data[pointSize:]
+	if len(data) < aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := authenc.Open(aead, nonce, sealed, additionalData)
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(plaintext)
+
+	return string(plaintext), nil
+}
+
+// newAEAD derives the AES-256-GCM key for one value. Both public keys are
+// mixed in so the key is tied to this exact sender and recipient.
+func newAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+
+	key := make([]byte, 32)
+	defer securemem.Wipe(key)
+
+	_, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(eciesInfo)), key)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
 
+	return cipher.NewGCM(block)
+}
+
+// decryptLegacy decrypts values written before ECIES, when every secret had
+// its own private key and the AES key was derived from it alone.
+func decryptLegacy(ciphertext string, k *Key, additionalData []byte) (string, error) {
 	x, _ := elliptic.P256().ScalarMult(k.Private.X, k.Private.Y, k.Private.D.Bytes())
 	sharedSecret := x.Bytes()
+	defer securemem.Wipe(sharedSecret)
 
 	// Derive AES key using HKDF
 	hkdf := hkdf.New(sha256.New, sharedSecret, nil, nil)
 	aesKey := make([]byte, 32)
+	defer securemem.Wipe(aesKey)
+
 	_, err := io.ReadFull(hkdf, aesKey)
 	if err != nil {
 		return "", err
 	}
 
-	return new(aes.AES256).Decrypt(ciphertext, &aes.EncryptionKey{Key: aesKey})
+	return new(cryptaes.AES256).Decrypt(ciphertext, &cryptaes.EncryptionKey{Key: aesKey}, additionalData)
 }
@@ -1,10 +1,15 @@
 package ecc_test
 
 import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/hkdf"
 
+	"github.com/sunny-b/cryptkeeper/internal/crypt/aes"
 	"github.com/sunny-b/cryptkeeper/internal/crypt/ecc"
 )
 
@@ -24,18 +29,95 @@ func TestEncryptDecrypt(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			k, err := ecc.EphermalKey()
+			k, err := ecc.GenerateKeys()
 			assert.NoError(err)
 
 			// Test encryption
-			ciphertext, err := e.Encrypt(tt.plaintext, k)
+			ciphertext, err := e.Encrypt(tt.plaintext, k.Public, nil)
 			assert.NoError(err)
 			assert.NotEqual(tt.plaintext, ciphertext)
 
 			// Test decryption
-			decrypted, err := e.Decrypt(ciphertext, k)
+			decrypted, err := e.Decrypt(ciphertext, k.Private, nil)
 			assert.NoError(err)
 			assert.Equal(tt.plaintext, string(decrypted))
 		})
 	}
 }
+
+func TestEncryptIsRandomized(t *testing.T) {
+	assert := assert.New(t)
+	e := &ecc.ECC256{}
+
+	k, err := ecc.GenerateKeys()
+	assert.NoError(err)
+
+	first, err := e.Encrypt("secret", k.Public, nil)
+	assert.NoError(err)
+
+	second, err := e.Encrypt("secret", k.Public, nil)
+	assert.NoError(err)
+
+	assert.NotEqual(first, second)
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	assert := assert.New(t)
+	e := &ecc.ECC256{}
+
+	k, err := ecc.GenerateKeys()
+	assert.NoError(err)
+
+	other, err := ecc.GenerateKeys()
+	assert.NoError(err)
+
+	ciphertext, err := e.Encrypt("secret", k.Public, nil)
+	assert.NoError(err)
+
+	_, err = e.Decrypt(ciphertext, other.Private, nil)
+	assert.Error(err)
+
+	_, err = e.Decrypt("c2hvcnQ=", k.Private, nil)
+	assert.ErrorIs(err, ecc.ErrInvalidCiphertext)
+}
+
+func TestAdditionalData(t *testing.T) {
+	assert := assert.New(t)
+	e := &ecc.ECC256{}
+
+	k, err := ecc.GenerateKeys()
+	assert.NoError(err)
+
+	ciphertext, err := e.Encrypt("secret", k.Public, []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := e.Decrypt(ciphertext, k.Private, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = e.Decrypt(ciphertext, k.Private, []byte("BAR"))
+	assert.Error(err)
+
+	_, err = e.Decrypt(ciphertext, k.Private, nil)
+	assert.Error(err)
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	assert := assert.New(t)
+
+	k, err := ecc.EphermalKey()
+	assert.NoError(err)
+
+	// Before ECIES the AES key was derived from the per-secret key alone.
+	x, _ := elliptic.P256().ScalarMult(k.Private.X, k.Private.Y, k.Private.D.Bytes())
+	aesKey := make([]byte, 32)
+	_, err = io.ReadFull(hkdf.New(sha256.New, x.Bytes(), nil, nil), aesKey)
+	assert.NoError(err)
+
+	ciphertext, err := new(aes.AES256).Encrypt("secret", &aes.EncryptionKey{Key: aesKey}, nil)
+	assert.NoError(err)
+
+	decrypted, err := new(ecc.ECC256).Decrypt(ciphertext, k, nil)
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+}
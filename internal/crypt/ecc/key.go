@@ -1,22 +1,50 @@
 package ecc
 
 import (
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"io"
+	"sort"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
 )
 
+// legacyMACInfo separates the MAC keys derived for key files from before
+// the MAC key existed from every other key derived from the same material.
+const legacyMACInfo = "cryptkeeper ecc256 legacy mac key v1"
+
+// Keys is the ecc256 key file. New values are encrypted to the public key
+// and decrypted with the private key. A file that only holds the public key
+// can add secrets but not read them.
 type Keys struct {
-	KeyMap map[string]*Key `json:"keys"`
+	Private *ecdh.PrivateKey
+	Public  *ecdh.PublicKey
+
+	// KeyMap holds the per-secret keys of key files written before ECIES.
+	// They're only used to decrypt the values they were made for.
+	KeyMap map[string]*Key
+
+	// MACKey authenticates the config. It isn't derived from the private
+	// key, so key files from before ECIES keep their MAC key.
+	MACKey []byte
 }
 
-//nolint:musttag
-type Key struct {
-	Private *ecdsa.PrivateKey
+// keysJSON is the on-disk form of Keys.
+type keysJSON struct {
+	PrivateKey []byte          `json:"private_key,omitempty"`
+	PublicKey  []byte          `json:"public_key,omitempty"`
+	KeyMap     map[string]*Key `json:"keys,omitempty"`
+	MACKey     []byte          `json:"mac_key,omitempty"`
 }
 
 func GenerateKeys() (Keys, error) {
@@ -24,9 +52,178 @@ func GenerateKeys() (Keys, error) {
 		KeyMap: make(map[string]*Key),
 	}
 
+	err := keys.GenerateKeyPair()
+	if err != nil {
+		return Keys{}, err
+	}
+
+	err = keys.GenerateMACKey()
+	if err != nil {
+		return Keys{}, err
+	}
+
 	return keys, nil
 }
 
+// GenerateKeyPair sets a fresh P-256 key pair.
+func (k *Keys) GenerateKeyPair() error {
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	k.Private = private
+	k.Public = private.PublicKey()
+
+	return nil
+}
+
+// LockMemory moves the MAC key into memory that won't be swapped to disk.
+// The private keys are held by crypto/ecdh and crypto/ecdsa, which keep
+// their own copies.
+func (k *Keys) LockMemory() {
+	k.MACKey = securemem.Lock(k.MACKey)
+}
+
+// GenerateMACKey sets a fresh random MAC key.
+func (k *Keys) GenerateMACKey() error {
+	k.MACKey = make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, k.MACKey)
+	return err
+}
+
+// Secret returns the MAC key, which other keys can be derived from. Key
+// files from before the MAC key existed derive one from their private keys
+// in memory, so loading them never rewrites them. It's kept from then on,
+// so it survives the key file being given a key pair or losing per-secret
+// keys. Key files holding only the public key have none.
+func (k *Keys) Secret() []byte {
+	if len(k.MACKey) == 0 && !k.PublicOnly() {
+		k.MACKey = securemem.Lock(k.legacyMACKey())
+	}
+
+	return k.MACKey
+}
+
+// legacyMACKey derives a MAC key from the private key, or from the
+// per-secret keys of key files from before ECIES.
+func (k *Keys) legacyMACKey() []byte {
+	var material []byte
+	if k.Private != nil {
+		material = k.Private.Bytes()
+	} else {
+		names := make([]string, 0, len(k.KeyMap))
+		for name := range k.KeyMap {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			material = append(material, name...)
+			material = append(material, 0)
+			material = append(material, k.KeyMap[name].Private.D.Bytes()...)
+		}
+	}
+	defer securemem.Wipe(material)
+
+	macKey := make([]byte, 32)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, material, nil, []byte(legacyMACInfo)), macKey)
+
+	return macKey
+}
+
+// ID returns the short identifier recorded next to every value encrypted
+// to the public key. It's empty for key files from before ECIES that haven't
+// been given a key pair yet.
+func (k *Keys) ID() string {
+	if k.Public == nil {
+		return ""
+	}
+
+	return keyid.New(k.Public.Bytes())
+}
+
+// Fingerprint identifies the key pair in full by its public key, so a key
+// file holding only the public key has the same fingerprint. It's empty
+// when ID is.
+func (k *Keys) Fingerprint() string {
+	if k.Public == nil {
+		return ""
+	}
+
+	return keyid.Fingerprint(k.Public.Bytes())
+}
+
+// PublicOnly reports whether the key file can't decrypt anything.
+func (k *Keys) PublicOnly() bool {
+	return k.Private == nil && len(k.KeyMap) == 0
+}
+
+// PublicKeys returns a copy holding only the public key, safe to hand to
+// anyone who should be able to add secrets.
+func (k *Keys) PublicKeys() Keys {
+	return Keys{Public: k.Public}
+}
+
+func (k Keys) MarshalJSON() ([]byte, error) {
+	j := keysJSON{
+		KeyMap: k.KeyMap,
+		MACKey: k.MACKey,
+	}
+	if k.Private != nil {
+		j.PrivateKey = k.Private.Bytes()
+	}
+	if k.Public != nil {
+		j.PublicKey = k.Public.Bytes()
+	}
+
+	return json.Marshal(j)
+}
+
+func (k *Keys) UnmarshalJSON(data []byte) error {
+	var j keysJSON
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*k = Keys{
+		KeyMap: j.KeyMap,
+		MACKey: j.MACKey,
+	}
+	if k.KeyMap == nil {
+		k.KeyMap = make(map[string]*Key)
+	}
+
+	if len(j.PrivateKey) > 0 {
+		k.Private, err = ecdh.P256().NewPrivateKey(j.PrivateKey)
+		if err != nil {
+			return err
+		}
+		k.Public = k.Private.PublicKey()
+
+		return nil
+	}
+
+	if len(j.PublicKey) > 0 {
+		k.Public, err = ecdh.P256().NewPublicKey(j.PublicKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Key is a per-secret key from a key file written before ECIES.
+//
+//nolint:musttag
+type Key struct {
+	Private *ecdsa.PrivateKey
+}
+
+// EphermalKey generates a per-secret key the way key files from before ECIES
+// did.
 func EphermalKey() (*Key, error) {
 	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -36,6 +233,12 @@ func EphermalKey() (*Key, error) {
 	return &Key{k}, nil
 }
 
+// ID returns the short identifier recorded next to every value encrypted
+// with this key. It's derived from the public key only.
+func (k *Key) ID() string {
+	return keyid.New(elliptic.MarshalCompressed(k.Private.Curve, k.Private.X, k.Private.Y))
+}
+
 func (k *Key) MarshalJSON() ([]byte, error) {
 	privateKeyBytes, err := x509.MarshalECPrivateKey(k.Private)
 	if err != nil {
@@ -52,3 +52,33 @@ func TestCustomJSONMarshalingAndUnmarshaling(t *testing.T) {
 		})
 	}
 }
+
+func TestKeysJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	keys, err := ecc.GenerateKeys()
+	assert.NoError(err)
+
+	jsonData, err := json.Marshal(keys)
+	assert.NoError(err)
+
+	var newKeys ecc.Keys
+	assert.NoError(json.Unmarshal(jsonData, &newKeys))
+	assert.True(keys.Private.Equal(newKeys.Private))
+	assert.True(keys.Public.Equal(newKeys.Public))
+	assert.Equal(keys.MACKey, newKeys.MACKey)
+	assert.Equal(keys.ID(), newKeys.ID())
+	assert.False(newKeys.PublicOnly())
+
+	jsonData, err = json.Marshal(keys.PublicKeys())
+	assert.NoError(err)
+	assert.NotContains(string(jsonData), "private_key")
+	assert.NotContains(string(jsonData), "mac_key")
+
+	var public ecc.Keys
+	assert.NoError(json.Unmarshal(jsonData, &public))
+	assert.Nil(public.Private)
+	assert.True(keys.Public.Equal(public.Public))
+	assert.True(public.PublicOnly())
+	assert.Equal(keys.ID(), public.ID())
+}
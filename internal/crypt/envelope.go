@@ -0,0 +1,93 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	envelopePrefix    = "ck"
+	envelopeSeparator = ":"
+
+	// envelopeV1 values aren't bound to anything, envelopeV2 values carry
+	// the secret name and project ID as associated data. envelopeV3 values
+	// were padded before encryption, and carry their version in the
+	// associated data too.
+	envelopeV1 = "v1"
+	envelopeV2 = "v2"
+	envelopeV3 = "v3"
+)
+
+var ErrMalformedEnvelope = errors.New("malformed encrypted value")
+
+// envelope is the self-describing wrapper written around every encrypted
+// value:
+//
+//	ck:<version>:<algorithm>:<key id>:<payload>
+//
+// The payload is whatever the Encrypter produced. Values written before the
+// envelope existed are bare base64 and are still accepted by parseEnvelope.
+type envelope struct {
+	Version   string
+	Algorithm EncryptionType
+	KeyID     string
+	Payload   string
+}
+
+func newEnvelope(alg EncryptionType, keyID, payload string) *envelope {
+	return &envelope{
+		Version:   envelopeV2,
+		Algorithm: alg,
+		KeyID:     keyID,
+		Payload:   payload,
+	}
+}
+
+func (e *envelope) String() string {
+	return strings.Join([]string{envelopePrefix, e.Version, string(e.Algorithm), e.KeyID, e.Payload}, envelopeSeparator)
+}
+
+// Padded reports whether the plaintext was padded before encryption.
+func (e *envelope) Padded() bool {
+	return e.Version == envelopeV3
+}
+
+// Bound reports whether the value was encrypted with associated data.
+func (e *envelope) Bound() bool {
+	return e.Version != envelopeV1
+}
+
+// parseEnvelope splits an encrypted value into its parts. It returns a nil
+// envelope and no error for bare legacy values.
+func parseEnvelope(value string) (*envelope, error) {
+	// base64 never contains a colon, so anything without our prefix is a
+	// legacy value.
+	if !strings.HasPrefix(value, envelopePrefix+envelopeSeparator) {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(value, envelopeSeparator, 5)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("%w: expected %s:<version>:<algorithm>:<key id>:<payload>", ErrMalformedEnvelope, envelopePrefix)
+	}
+
+	e := &envelope{
+		Version:   parts[1],
+		Algorithm: EncryptionType(parts[2]),
+		KeyID:     parts[3],
+		Payload:   parts[4],
+	}
+
+	if e.Version != envelopeV1 && e.Version != envelopeV2 && e.Version != envelopeV3 {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrMalformedEnvelope, e.Version)
+	}
+	if err := validateEncryptionType(e.Algorithm); err != nil {
+		return nil, fmt.Errorf("%w: %s %q", ErrMalformedEnvelope, err, e.Algorithm)
+	}
+	if e.KeyID == "" || e.Payload == "" {
+		return nil, fmt.Errorf("%w: missing key id or payload", ErrMalformedEnvelope)
+	}
+
+	return e, nil
+}
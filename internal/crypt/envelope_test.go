@@ -0,0 +1,39 @@
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnvelope(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected *envelope
+		err      error
+	}{
+		{"Legacy value", "c2VjcmV0", nil, nil},
+		{"Unbound envelope", "ck:v1:aes256:0123456789abcdef:c2VjcmV0", &envelope{envelopeV1, AES256, "0123456789abcdef", "c2VjcmV0"}, nil},
+		{"Bound envelope", "ck:v2:aes256:0123456789abcdef:c2VjcmV0", newEnvelope(AES256, "0123456789abcdef", "c2VjcmV0"), nil},
+		{"Padded envelope", "ck:v3:aes256:0123456789abcdef:c2VjcmV0", &envelope{envelopeV3, AES256, "0123456789abcdef", "c2VjcmV0"}, nil},
+		{"Too few parts", "ck:v1:aes256:c2VjcmV0", nil, ErrMalformedEnvelope},
+		{"Unknown version", "ck:v9:aes256:0123456789abcdef:c2VjcmV0", nil, ErrMalformedEnvelope},
+		{"Unknown algorithm", "ck:v1:rot13:0123456789abcdef:c2VjcmV0", nil, ErrMalformedEnvelope},
+		{"Missing payload", "ck:v1:aes256:0123456789abcdef:", nil, ErrMalformedEnvelope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseEnvelope(tt.value)
+			assert.Equal(tt.expected, result)
+			assert.ErrorIs(err, tt.err)
+
+			if result != nil {
+				assert.Equal(tt.value, result.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrWrongKey = errors.New("wrong key")
+
+// fingerprinted is implemented by every key type. The fingerprint of an
+// asymmetric key is taken from its public key.
+type fingerprinted interface {
+	Fingerprint() string
+}
+
+// WithFingerprint makes the keeper refuse a key file holding any key other
+// than the one with the given fingerprint.
+func WithFingerprint(fingerprint string) Option {
+	return func(o *options) {
+		o.fingerprint = fingerprint
+	}
+}
+
+// Fingerprint returns the fingerprint of the loaded key. Two key files hold
+// the same key if and only if their fingerprints match.
+func (k *Keeper) Fingerprint() (string, error) {
+	if err := k.lazyInit(); err != nil {
+		return "", err
+	}
+
+	return keyFingerprint(k.encryptionKey), nil
+}
+
+// keyFingerprint returns the fingerprint of key. It's empty for ECC256 key
+// files from before ECIES that haven't been given a key pair yet.
+func keyFingerprint(key any) string {
+	if f, ok := key.(fingerprinted); ok {
+		return f.Fingerprint()
+	}
+
+	return ""
+}
+
+// checkFingerprint refuses a key that isn't the one the keeper was given
+// the fingerprint of.
+func (k *Keeper) checkFingerprint() error {
+	if k.fingerprint == "" {
+		return nil
+	}
+
+	fingerprint := keyFingerprint(k.encryptionKey)
+	if fingerprint == "" || fingerprint == k.fingerprint {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s holds the key %s, but the config expects %s", ErrWrongKey, k.KeySource(), fingerprint, k.fingerprint)
+}
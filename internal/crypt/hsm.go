@@ -0,0 +1,175 @@
+package crypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/pkcs11"
+	"github.com/sunny-b/cryptkeeper/internal/passphrase"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	// PKCS11ModuleEnvVar names the PKCS#11 module that drives the token,
+	// ahead of the one the config names.
+	PKCS11ModuleEnvVar = "CK_PKCS11_MODULE"
+
+	// PKCS11AllowedModulesEnvVar lists modules, besides the well-known
+	// ones, that configs may name. The module is loaded into the process
+	// and is given the PIN, so a committed config can only pick a module
+	// the user installed or allowed.
+	PKCS11AllowedModulesEnvVar = "CK_PKCS11_ALLOWED_MODULES"
+)
+
+var (
+	ErrNoPKCS11Token          = errors.New("pkcs11 needs the slot and key label of the HSM key")
+	ErrNoPKCS11Module         = errors.New("pkcs11 needs the absolute path of the PKCS#11 module in the config or " + PKCS11ModuleEnvVar)
+	ErrPKCS11ModuleNotAllowed = errors.New("the config's PKCS#11 module isn't a well-known one - add it to " + PKCS11AllowedModulesEnvVar + " or set " + PKCS11ModuleEnvVar)
+)
+
+// knownPKCS11Modules are where packages install common PKCS#11 modules.
+// Configs may name them without the user allowing them.
+var knownPKCS11Modules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib64/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib/aarch64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/opensc-pkcs11.so",
+	"/usr/lib64/opensc-pkcs11.so",
+	"/usr/lib/x86_64-linux-gnu/libykcs11.so",
+	"/opt/cloudhsm/lib/libcloudhsm_pkcs11.so",
+	"/usr/safenet/lunaclient/lib/libCryptoki2_64.so",
+}
+
+// PKCS11Key names the HSM key that wraps a project's data key, and holds
+// the data key it wrapped. The HSM key never leaves the token. Values are
+// encrypted with AES-256-GCM under the data key, which is unwrapped by the
+// token whenever a keeper is loaded. The token is driven by the module in
+// CK_PKCS11_MODULE, or else by the module the config names, if it's allowed.
+type PKCS11Key struct {
+	Module     string `json:"module,omitempty"`
+	Slot       uint   `json:"slot"`
+	KeyLabel   string `json:"key_label"`
+	WrappedKey string `json:"wrapped_key,omitempty"`
+}
+
+// WithPKCS11Key sets the HSM key and wrapped data key PKCS11 keepers use.
+func WithPKCS11Key(key *PKCS11Key) Option {
+	return func(o *options) {
+		o.pkcs11Key = key
+	}
+}
+
+// NewPKCS11Key generates a data key and wraps it with the HSM key named by
+// key, replacing its wrapped key. The token PIN is read from CK_PKCS11_PIN
+// or prompted for, and the module from CK_PKCS11_MODULE or key.
+func NewPKCS11Key(key *PKCS11Key) error {
+	w, err := key.wrapper()
+	if err != nil {
+		return err
+	}
+
+	key.WrappedKey, err = newDataKey(w)
+
+	return err
+}
+
+// pkcs11Wrapper wraps data keys with a key on a PKCS#11 token. Wrapped keys
+// are base64 encoded.
+type pkcs11Wrapper struct {
+	token pkcs11.Token
+}
+
+// pkcs11DataKey returns the wrapper for the keeper's HSM key and the data
+// key it wrapped.
+func (k *Keeper) pkcs11DataKey() (keyWrapper, string, error) {
+	w, err := k.pkcs11Key.wrapper()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return w, k.pkcs11Key.WrappedKey, nil
+}
+
+// wrapper returns the wrapper for the HSM key, on the token driven by the
+// module in CK_PKCS11_MODULE or the config.
+func (key *PKCS11Key) wrapper() (pkcs11Wrapper, error) {
+	if key == nil || key.KeyLabel == "" {
+		return pkcs11Wrapper{}, ErrNoPKCS11Token
+	}
+
+	module, err := key.module()
+	if err != nil {
+		return pkcs11Wrapper{}, err
+	}
+
+	return pkcs11Wrapper{pkcs11.Token{Module: module, Slot: key.Slot, Label: key.KeyLabel}}, nil
+}
+
+// module returns the PKCS#11 module in CK_PKCS11_MODULE, or else the one
+// the config names, as long as it's well-known or listed in
+// CK_PKCS11_ALLOWED_MODULES.
+func (key *PKCS11Key) module() (string, error) {
+	if module := os.Getenv(PKCS11ModuleEnvVar); module != "" {
+		if !filepath.IsAbs(module) {
+			return "", ErrNoPKCS11Module
+		}
+
+		return module, nil
+	}
+
+	if key.Module == "" || !filepath.IsAbs(key.Module) {
+		return "", ErrNoPKCS11Module
+	}
+
+	allowed := filepath.SplitList(os.Getenv(PKCS11AllowedModulesEnvVar))
+	if filepath.Clean(key.Module) != key.Module || !slices.Contains(knownPKCS11Modules, key.Module) && !slices.Contains(allowed, key.Module) {
+		return "", fmt.Errorf("%w: %s", ErrPKCS11ModuleNotAllowed, key.Module)
+	}
+
+	return key.Module, nil
+}
+
+func (w pkcs11Wrapper) wrap(dataKey []byte) (string, error) {
+	pin, err := w.readPIN()
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(pin)
+
+	wrapped, err := pkcs11.Wrap(w.token, pin, dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+func (w pkcs11Wrapper) unwrap(wrapped string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data key for %s", w)
+	}
+
+	pin, err := w.readPIN()
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(pin)
+
+	return pkcs11.Unwrap(w.token, pin, b)
+}
+
+func (w pkcs11Wrapper) String() string {
+	return w.token.String()
+}
+
+func (w pkcs11Wrapper) readPIN() ([]byte, error) {
+	return passphrase.ReadPIN(fmt.Sprintf("Enter PIN for the token in slot %d: ", w.token.Slot))
+}
@@ -0,0 +1,115 @@
+//go:build cgo
+
+package crypt_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/pkcs11/softhsm"
+	"github.com/sunny-b/cryptkeeper/internal/passphrase"
+)
+
+func TestKeeperPKCS11(t *testing.T) {
+	assert := assert.New(t)
+	token := softhsm.Setup(t)
+	t.Setenv(passphrase.PINEnvKey, softhsm.PIN)
+	t.Setenv(crypt.PKCS11ModuleEnvVar, token.Module)
+
+	hsmKey := &crypt.PKCS11Key{Slot: token.Slot, KeyLabel: token.Label}
+	assert.NoError(crypt.NewPKCS11Key(hsmKey))
+	assert.NotEmpty(hsmKey.WrappedKey)
+
+	keeper, err := crypt.NewKeeper(crypt.PKCS11, "", crypt.WithPKCS11Key(hsmKey), crypt.WithProjectID("project"))
+	assert.NoError(err)
+	assert.Contains(keeper.KeySource(), token.Label)
+
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(cipher, "ck:v2:pkcs11:"))
+
+	// A second keeper unwraps the same data key through the token.
+	reloaded, err := crypt.NewKeeper(crypt.PKCS11, "", crypt.WithPKCS11Key(hsmKey), crypt.WithProjectID("project"))
+	assert.NoError(err)
+
+	plainText, err := reloaded.Decrypt("FOO", cipher)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+
+	_, err = keeper.PlainKeyFile()
+	assert.ErrorIs(err, crypt.ErrNoKeyFile)
+	_, err = keeper.SplitKey(3, 2)
+	assert.ErrorIs(err, crypt.ErrNoKeyFile)
+
+	t.Setenv(passphrase.PINEnvKey, "0000")
+	_, err = crypt.NewKeeper(crypt.PKCS11, "", crypt.WithPKCS11Key(hsmKey))
+	assert.Error(err)
+}
+
+func TestKeeperPKCS11Module(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv(crypt.PKCS11ModuleEnvVar, "")
+	t.Setenv(crypt.PKCS11AllowedModulesEnvVar, "")
+
+	hsmKey := &crypt.PKCS11Key{Slot: 1, KeyLabel: "key", WrappedKey: "d3JhcHBlZA=="}
+
+	// The module is loaded into the process, so it's only ever taken as an
+	// absolute path.
+	for _, module := range []string{"", "libsofthsm2.so"} {
+		t.Setenv(crypt.PKCS11ModuleEnvVar, module)
+
+		_, err := crypt.NewKeeper(crypt.PKCS11, "", crypt.WithPKCS11Key(hsmKey))
+		assert.ErrorIs(err, crypt.ErrNoPKCS11Module)
+	}
+	t.Setenv(crypt.PKCS11ModuleEnvVar, "")
+
+	// A config can only name a module the user installed or allowed.
+	hsmKey.Module = "/tmp/project/evil.so"
+	_, err := crypt.NewKeeper(crypt.PKCS11, "", crypt.WithPKCS11Key(hsmKey))
+	assert.ErrorIs(err, crypt.ErrPKCS11ModuleNotAllowed)
+
+	hsmKey.Module = "/usr/lib/softhsm/../../../tmp/project/evil.so"
+	_, err = crypt.NewKeeper(crypt.PKCS11, "", crypt.WithPKCS11Key(hsmKey))
+	assert.ErrorIs(err, crypt.ErrPKCS11ModuleNotAllowed)
+
+	hsmKey.Module = "/tmp/project/evil.so"
+	t.Setenv(crypt.PKCS11AllowedModulesEnvVar, "/opt/hsm/module.so:/tmp/project/evil.so")
+	_, err = crypt.NewKeeper(crypt.PKCS11, "", crypt.WithPKCS11Key(hsmKey))
+	assert.Error(err)
+	assert.NotErrorIs(err, crypt.ErrPKCS11ModuleNotAllowed)
+}
+
+func TestKeeperPKCS11ConfigModule(t *testing.T) {
+	assert := assert.New(t)
+	token := softhsm.Setup(t)
+	t.Setenv(passphrase.PINEnvKey, softhsm.PIN)
+	t.Setenv(crypt.PKCS11ModuleEnvVar, "")
+	t.Setenv(crypt.PKCS11AllowedModulesEnvVar, token.Module)
+
+	hsmKey := &crypt.PKCS11Key{Module: token.Module, Slot: token.Slot, KeyLabel: token.Label}
+	assert.NoError(crypt.NewPKCS11Key(hsmKey))
+
+	keeper, err := crypt.NewKeeper(crypt.PKCS11, "", crypt.WithPKCS11Key(hsmKey))
+	assert.NoError(err)
+
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+
+	plainText, err := keeper.Decrypt("FOO", cipher)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+}
+
+func TestKeeperPKCS11WithoutToken(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := crypt.NewKeeper(crypt.PKCS11, "")
+	assert.ErrorIs(err, crypt.ErrNoPKCS11Token)
+
+	assert.ErrorIs(crypt.NewPKCS11Key(&crypt.PKCS11Key{Slot: 1}), crypt.ErrNoPKCS11Token)
+	assert.ErrorIs(crypt.GenerateKeys(crypt.PKCS11, filepath.Join(t.TempDir(), ".ckkey")), crypt.ErrNoKeyFile)
+}
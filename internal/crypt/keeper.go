@@ -1,39 +1,95 @@
 package crypt
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
+
+	"github.com/sunny-b/cryptkeeper/internal/agent"
 	"github.com/sunny-b/cryptkeeper/internal/crypt/aes"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/aessiv"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/age"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
 	"github.com/sunny-b/cryptkeeper/internal/crypt/ecc"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keywrap"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/pqhybrid"
 	"github.com/sunny-b/cryptkeeper/internal/crypt/rsa"
 	"github.com/sunny-b/cryptkeeper/internal/crypt/serpent"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/x25519"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/xchacha20"
 	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
 )
 
 var fs afero.Fs = afero.NewOsFs()
 
+// Encrypter encrypts and decrypts single values. additionalData is
+// authenticated with the value but not stored in it, so Decrypt fails unless
+// it's given the same additionalData as Encrypt.
 type Encrypter interface {
-	Encrypt(plaintext string, key any) (string, error)
-	Decrypt(cipherText string, key any) (string, error)
+	Encrypt(plaintext string, key any, additionalData []byte) (string, error)
+	Decrypt(cipherText string, key any, additionalData []byte) (string, error)
 }
 
 type Keeper struct {
+	options
+
 	encryptionType EncryptionType
 	keyPath        string
 
+	// source describes where the key came from when it wasn't read from
+	// keyPath.
+	source string
+
 	encrypter     Encrypter
 	encryptionKey any
+
+	// wrapped is set when the key file is protected with a passphrase or
+	// an SSH key. unlockedWith is the passphrase it was unwrapped with, kept
+	// so the file can be wrapped again when ECC256 rewrites it. It's nil when
+	// the key came from the agent. wrappedWithSSH is the fingerprint of the
+	// SSH key that protects it.
+	wrapped        bool
+	unlockedWith   []byte
+	wrappedWithSSH string
+
+	// retired keepers hold keys that older values may still be encrypted
+	// with. They're only ever used for decryption.
+	retired []*Keeper
+}
+
+// identifiable is implemented by every key type that has a single key ID.
+type identifiable interface {
+	ID() string
+}
+
+// lockable is implemented by key types whose secrets can be moved into
+// memory that won't be swapped to disk.
+type lockable interface {
+	LockMemory()
 }
 
-func NewKeeper(t EncryptionType, keyPath string) (*Keeper, error) {
+// keyFile is implemented by key types that aren't stored as JSON because
+// other tools read them too.
+type keyFile interface {
+	KeyFile() ([]byte, error)
+}
+
+func NewKeeper(t EncryptionType, keyPath string, opts ...Option) (*Keeper, error) {
 	k := &Keeper{
 		encryptionType: t,
 		keyPath:        keyPath,
 	}
 
+	for _, opt := range opts {
+		opt(&k.options)
+	}
+
 	err := k.lazyInit()
 	if err != nil {
 		return nil, err
@@ -42,11 +98,50 @@ func NewKeeper(t EncryptionType, keyPath string) (*Keeper, error) {
 	return k, nil
 }
 
-func GenerateKeys(enc EncryptionType, keyPath string) error {
+// GenerateKeys writes a new key of the given type to keyPath. Pass
+// WithPassphrase to protect the key file with a passphrase, and WithKeySize
+// to check the key size against the type.
+func GenerateKeys(enc EncryptionType, keyPath string, opts ...Option) error {
 	if err := validateEncryptionType(enc); err != nil {
 		return err
 	}
 
+	if WrapsDataKey(enc) {
+		return fmt.Errorf("%w, %s data keys are made by NewPKCS11Key, NewVaultTransitKey or NewKeyProviderKey", ErrNoKeyFile, enc)
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.keySize != 0 {
+		resized, err := ResizeEncryptionType(enc, o.keySize)
+		if err != nil {
+			return err
+		}
+		if resized != enc {
+			return fmt.Errorf("%w: %s keys are %d bits, use %s for %d-bit keys", ErrUnsupportedKeySize, enc, keySize(enc), resized, o.keySize)
+		}
+	}
+
+	var wrap wrapFunc
+	switch {
+	case o.sshKey != "":
+		wrap = func(b []byte) ([]byte, error) {
+			return wrapKeyFileWithSSH(b, o.sshKey, o.passphrase)
+		}
+	case o.passphrase != nil:
+		passphrase, err := o.passphrase()
+		if err != nil {
+			return err
+		}
+
+		wrap = func(b []byte) ([]byte, error) {
+			return wrapKeyFile(b, passphrase)
+		}
+	}
+
 	var keys any
 	var err error
 	switch enc {
@@ -54,10 +149,20 @@ func GenerateKeys(enc EncryptionType, keyPath string) error {
 		keys, err = aes.GenerateKeys()
 	case ECC256:
 		keys, err = ecc.GenerateKeys()
-	case RSA2048:
-		keys, err = rsa.GenerateKeys()
+	case RSA2048, RSA4096:
+		keys, err = rsa.GenerateKeys(keySize(enc))
 	case Serpent256:
 		keys, err = serpent.GenerateKeys()
+	case XChaCha20:
+		keys, err = xchacha20.GenerateKeys()
+	case AES256SIV:
+		keys, err = aessiv.GenerateKeys()
+	case MLKEM768X25519:
+		keys, err = pqhybrid.GenerateKeys()
+	case X25519:
+		keys, err = x25519.GenerateIdentity()
+	case Age:
+		keys, err = age.GenerateKeys()
 	default:
 		err = ErrUnknownEncryptionType
 	}
@@ -65,7 +170,7 @@ func GenerateKeys(enc EncryptionType, keyPath string) error {
 		return err
 	}
 
-	err = saveKeys(keys, keyPath)
+	err = writeKeys(keys, keyPath, wrap)
 	if err != nil {
 		return fmt.Errorf("failed to write encryption key: %w", err)
 	}
@@ -73,49 +178,146 @@ func GenerateKeys(enc EncryptionType, keyPath string) error {
 	return err
 }
 
+// AddRetired registers a keeper for a key that has been replaced. Values
+// whose envelope names that key are decrypted with it, so a project can hold
+// values under several keys while it's being migrated.
+func (k *Keeper) AddRetired(retired *Keeper) {
+	k.retired = append(k.retired, retired)
+}
+
+// Encrypt encrypts plainText and wraps it in an envelope naming the
+// algorithm and key that produced it. The value is bound to secretName and
+// the project ID, so it can't be moved to another secret or project.
 func (k *Keeper) Encrypt(secretName, plainText string) (string, error) {
 	if err := k.lazyInit(); err != nil {
 		return "", err
 	}
 
-	switch k.encryptionType {
-	case AES256, RSA2048, Serpent256:
-		return k.encrypter.Encrypt(plainText, k.encryptionKey)
-	}
-
-	keys, ok := k.encryptionKey.(*ecc.Keys)
-	if !ok {
-		return "", errors.New("corrupted key file")
-	}
-
-	key, err := ecc.EphermalKey()
-	if err != nil {
-		return "", errors.New("failed to ephermal key")
+	// age values are stored as they are, so the age CLI can read them. It
+	// wouldn't know to strip padding, so they're never padded.
+	version := envelopeV2
+	if k.padding != nil && k.encryptionType != Age {
+		version = envelopeV3
+		plainText = k.padding.pad(plainText)
 	}
 
-	cipher, err := k.encrypter.Encrypt(plainText, key)
+	cipher, err := k.encrypt(plainText, k.additionalData(secretName, version))
 	if err != nil {
 		return "", err
 	}
 
-	keys.KeyMap[secretName] = key
+	if k.encryptionType == Age {
+		return cipher, nil
+	}
 
-	err = saveKeys(keys, k.keyPath)
+	keyID, err := k.keyID()
 	if err != nil {
 		return "", err
 	}
 
-	return cipher, nil
+	env := newEnvelope(k.encryptionType, keyID, cipher)
+	env.Version = version
+
+	return env.String(), nil
 }
 
+// Decrypt decrypts a value written by Encrypt, picking the current or a
+// retired key based on its envelope. Values written before secrets were bound
+// to their names are decrypted with the current key, unless the keeper has a
+// project ID.
 func (k *Keeper) Decrypt(secretName, cipher string) (string, error) {
 	if err := k.lazyInit(); err != nil {
 		return "", err
 	}
 
-	switch k.encryptionType {
-	case AES256, RSA2048, Serpent256:
-		return k.encrypter.Decrypt(cipher, k.encryptionKey)
+	if age.IsValue(cipher) {
+		return k.decryptAge(secretName, cipher)
+	}
+
+	env, err := parseEnvelope(cipher)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", secretName, err)
+	}
+
+	keeper := k
+	if env == nil {
+		// Bare values predate the envelope and are always under the current
+		// key.
+		env = &envelope{Version: envelopeV1, Algorithm: k.encryptionType, Payload: cipher}
+	} else {
+		keeper, err = k.keeperFor(secretName, env)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if !env.Bound() {
+		if k.projectID != "" {
+			return "", fmt.Errorf("%s: %w", secretName, ErrUnboundValue)
+		}
+
+		return keeper.decrypt(secretName, env, nil)
+	}
+
+	plainText, err := keeper.decrypt(secretName, env, k.additionalData(secretName, env.Version))
+	if errors.Is(err, authenc.ErrOpen) {
+		return "", fmt.Errorf("%s: %w", secretName, ErrAuthenticationFailed)
+	}
+	if isNotRecipient(err) {
+		return "", fmt.Errorf("%s: %w", secretName, ErrNotRecipient)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", secretName, err)
+	}
+
+	if env.Padded() {
+		plainText, err = unpad(plainText)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", secretName, err)
+		}
+	}
+
+	return plainText, nil
+}
+
+// additionalData is authenticated alongside every value. It ties the value
+// to the secret it's stored under and to the project. Padded values also
+// carry their envelope version, so marking one as unpadded, or an unpadded
+// one as padded, makes it fail to authenticate.
+func (k *Keeper) additionalData(secretName, version string) []byte {
+	parts := []string{"cryptkeeper", k.projectID, secretName}
+	if version == envelopeV3 {
+		parts = append(parts, version)
+	}
+
+	return []byte(strings.Join(parts, "\x00"))
+}
+
+func (k *Keeper) encrypt(plainText string, additionalData []byte) (string, error) {
+	switch k.cipherType() {
+	case AES256, AES256SIV, RSA2048, RSA4096, Serpent256, XChaCha20:
+		return k.encrypter.Encrypt(plainText, k.encryptionKey, additionalData)
+	case X25519:
+		recipients, err := parseX25519Recipients(k.recipients)
+		if err != nil {
+			return "", err
+		}
+
+		return k.encrypter.Encrypt(plainText, recipients, additionalData)
+	case Age:
+		recipients, err := parseAgeRecipients(k.recipients)
+		if err != nil {
+			return "", err
+		}
+
+		return k.encrypter.Encrypt(plainText, recipients, nil)
+	case MLKEM768X25519:
+		keys, ok := k.encryptionKey.(*pqhybrid.Keys)
+		if !ok {
+			return "", errors.New("corrupted key file")
+		}
+
+		return k.encrypter.Encrypt(plainText, keys.Public(), additionalData)
 	}
 
 	keys, ok := k.encryptionKey.(*ecc.Keys)
@@ -123,12 +325,126 @@ func (k *Keeper) Decrypt(secretName, cipher string) (string, error) {
 		return "", errors.New("corrupted key file")
 	}
 
+	if keys.Public == nil {
+		// Key files from before ECIES only hold per-secret keys. They get a
+		// key pair the first time something is encrypted.
+		err := keys.GenerateKeyPair()
+		if err != nil {
+			return "", err
+		}
+
+		err = k.saveKeys(keys)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return k.encrypter.Encrypt(plainText, keys.Public, additionalData)
+}
+
+func (k *Keeper) decrypt(secretName string, env *envelope, additionalData []byte) (string, error) {
+	switch k.cipherType() {
+	case AES256, AES256SIV, RSA2048, RSA4096, Serpent256, XChaCha20, X25519, MLKEM768X25519:
+		return k.encrypter.Decrypt(env.Payload, k.encryptionKey, additionalData)
+	}
+
+	keys, ok := k.encryptionKey.(*ecc.Keys)
+	if !ok {
+		return "", errors.New("corrupted key file")
+	}
+
+	if keys.Public != nil && env.KeyID == keys.ID() {
+		if keys.Private == nil {
+			return "", ErrPublicKeyOnly
+		}
+
+		return k.encrypter.Decrypt(env.Payload, keys.Private, additionalData)
+	}
+
 	key, ok := keys.KeyMap[secretName]
 	if !ok {
+		if keys.PublicOnly() {
+			return "", ErrPublicKeyOnly
+		}
+
 		return "", errors.New("failed to find key for secret")
 	}
 
-	return k.encrypter.Decrypt(cipher, key)
+	return k.encrypter.Decrypt(env.Payload, key, additionalData)
+}
+
+// decryptAge decrypts a value in age's own format. It has no envelope, so
+// the first age keeper is used.
+func (k *Keeper) decryptAge(secretName, cipher string) (string, error) {
+	for _, keeper := range append([]*Keeper{k}, k.retired...) {
+		if keeper.encryptionType != Age {
+			continue
+		}
+
+		plainText, err := keeper.encrypter.Decrypt(cipher, keeper.encryptionKey, nil)
+		if isNotRecipient(err) {
+			return "", fmt.Errorf("%s: %w", secretName, ErrNotRecipient)
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", secretName, err)
+		}
+
+		return plainText, nil
+	}
+
+	return "", fmt.Errorf("%s: %w: it was encrypted with age", secretName, ErrKeyNotFound)
+}
+
+// keeperFor finds the keeper holding the key named by env.
+func (k *Keeper) keeperFor(secretName string, env *envelope) (*Keeper, error) {
+	for _, keeper := range append([]*Keeper{k}, k.retired...) {
+		if keeper.encryptionType == env.Algorithm && keeper.holds(secretName, env.KeyID) {
+			return keeper, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %w: it was encrypted with %s key %s", secretName, ErrKeyNotFound, env.Algorithm, env.KeyID)
+}
+
+// holds reports whether the keeper has the key with the given ID for
+// secretName.
+func (k *Keeper) holds(secretName, keyID string) bool {
+	// X25519 values name the recipients they were encrypted to rather than
+	// a key. Any identity may be one of them.
+	if k.encryptionType == X25519 {
+		return true
+	}
+
+	if id, err := k.keyID(); err == nil && id == keyID {
+		return true
+	}
+
+	// ECC256 key files from before ECIES keep a key per secret.
+	if keys, ok := k.encryptionKey.(*ecc.Keys); ok {
+		key, ok := keys.KeyMap[secretName]
+		return ok && key.ID() == keyID
+	}
+
+	return false
+}
+
+// keyID returns the ID of the key new values are encrypted with.
+func (k *Keeper) keyID() (string, error) {
+	if k.encryptionType == X25519 {
+		recipients, err := parseX25519Recipients(k.recipients)
+		if err != nil {
+			return "", err
+		}
+
+		return x25519.SetID(recipients), nil
+	}
+
+	key, ok := k.encryptionKey.(identifiable)
+	if !ok || key.ID() == "" {
+		return "", errors.New("corrupted key file")
+	}
+
+	return key.ID(), nil
 }
 
 func (k *Keeper) RemoveKey(secretName string) error {
@@ -136,8 +452,8 @@ func (k *Keeper) RemoveKey(secretName string) error {
 		return err
 	}
 
-	switch k.encryptionType {
-	case AES256, RSA2048, Serpent256:
+	switch k.cipherType() {
+	case AES256, AES256SIV, RSA2048, RSA4096, Serpent256, XChaCha20, X25519, Age, MLKEM768X25519:
 		return nil
 	}
 
@@ -146,9 +462,47 @@ func (k *Keeper) RemoveKey(secretName string) error {
 		return errors.New("corrupted key file")
 	}
 
+	// Only key files from before ECIES hold a key per secret.
+	if _, ok := keys.KeyMap[secretName]; !ok {
+		return nil
+	}
+
 	delete(keys.KeyMap, secretName)
 
-	return saveKeys(keys, k.keyPath)
+	return k.saveKeys(keys)
+}
+
+// WritePublicKey writes a key file holding only the public key to path.
+// With it, secrets can be added on machines that must not be able to read
+// them. Only ECC256 has a public key.
+func (k *Keeper) WritePublicKey(path string) error {
+	if err := k.lazyInit(); err != nil {
+		return err
+	}
+
+	keys, ok := k.encryptionKey.(*ecc.Keys)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoPublicKey, k.encryptionType)
+	}
+
+	if keys.Public == nil {
+		err := keys.GenerateKeyPair()
+		if err != nil {
+			return err
+		}
+
+		err = k.saveKeys(keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(keys.PublicKeys())
+	if err != nil {
+		return err
+	}
+
+	return fileutils.WriteFile(path, b, 0644)
 }
 
 func (k *Keeper) lazyInit() error {
@@ -166,57 +520,248 @@ func (k *Keeper) lazyInit() error {
 		if err != nil {
 			return err
 		}
+
+		err = k.checkFingerprint()
+		if err != nil {
+			k.encryptionKey = nil
+			return err
+		}
 	}
 
 	return nil
 }
-func saveKeys(keys any, keyPath string) error {
-	b, err := json.Marshal(keys)
+
+func (k *Keeper) saveKeys(keys any) error {
+	if k.source != "" {
+		return fmt.Errorf("can't rewrite the key: %w", ErrSuppliedKey)
+	}
+
+	var wrap wrapFunc
+	switch {
+	case k.wrappedWithSSH != "":
+		wrap = func(b []byte) ([]byte, error) {
+			return wrapKeyFileWithSSH(b, k.wrappedWithSSH, k.passphrase)
+		}
+	case k.wrapped:
+		if k.unlockedWith == nil {
+			pass, err := readPassphrase(k.keyPath, k.passphrase)
+			if err != nil {
+				return err
+			}
+
+			k.unlockedWith = pass
+		}
+
+		wrap = func(b []byte) ([]byte, error) {
+			return wrapKeyFile(b, k.unlockedWith)
+		}
+	}
+
+	err := writeKeys(keys, k.keyPath, wrap)
 	if err != nil {
 		return err
 	}
 
-	return fileutils.WriteFile(keyPath, b, 0644)
+	if k.wrapped && agent.Running() {
+		// Keep the agent in step with the rewritten key file, otherwise the
+		// next command would ask for the passphrase again.
+		var opts []Option
+		if k.unlockedWith != nil {
+			opts = append(opts, WithPassphrase(func() ([]byte, error) {
+				return k.unlockedWith, nil
+			}))
+		}
+
+		err = UnlockInAgent(k.keyPath, opts...)
+		if err != nil {
+			logrus.WithError(err).Debug("failed to update key in agent")
+		}
+	}
+
+	return nil
+}
+
+// wrapFunc wraps the contents of a key file.
+type wrapFunc func(b []byte) ([]byte, error)
+
+// writeKeys writes the key file, wrapping it when wrap is given. Key files
+// are only readable by their owner.
+func writeKeys(keys any, keyPath string, wrap wrapFunc) error {
+	b, err := marshalKeys(keys)
+	if err != nil {
+		return err
+	}
+
+	// The plain contents are wiped even once b holds the wrapped file.
+	defer securemem.Wipe(b)
+
+	if wrap != nil {
+		b, err = wrap(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	return fileutils.WriteFile(keyPath, b, 0600)
+}
+
+// marshalKeys returns the plain contents of the key file for keys. They're
+// the caller's to wipe.
+func marshalKeys(keys any) ([]byte, error) {
+	if f, ok := keys.(keyFile); ok {
+		b, err := f.KeyFile()
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.Clone(b), nil
+	}
+
+	return json.Marshal(keys)
 }
 
 func (k *Keeper) fetchEncrypter() error {
-	switch k.encryptionType {
+	switch k.cipherType() {
 	case AES256:
 		k.encrypter = new(aes.AES256)
 	case ECC256:
 		k.encrypter = new(ecc.ECC256)
-	case RSA2048:
-		k.encrypter = new(rsa.RSA2048)
+	case RSA2048, RSA4096:
+		k.encrypter = new(rsa.RSA)
 	case Serpent256:
 		k.encrypter = new(serpent.Serpent256)
+	case XChaCha20:
+		k.encrypter = new(xchacha20.XChaCha20)
+	case AES256SIV:
+		k.encrypter = new(aessiv.AES256SIV)
+	case MLKEM768X25519:
+		k.encrypter = new(pqhybrid.MLKEM768X25519)
+	case X25519:
+		k.encrypter = new(x25519.X25519)
+	case Age:
+		k.encrypter = new(age.Age)
 	}
 
 	return nil
 }
 
 func (k *Keeper) fetchKeys() error {
-	b, err := afero.ReadFile(fs, k.keyPath)
+	// Nothing ever needs a core dump of a process holding keys.
+	if err := securemem.DisableCoreDumps(); err != nil {
+		logrus.WithError(err).Debug("failed to disable core dumps")
+	}
+
+	// The key that wraps the data key never leaves the HSM, Vault or key
+	// provider, so there's no key file. They unwrap the data key instead.
+	if WrapsDataKey(k.encryptionType) {
+		return k.fetchDataKey()
+	}
+
+	b, err := k.readKeyFile()
 	if err != nil {
 		return err
 	}
 
+	if keywrap.IsWrapped(b) {
+		k.wrapped = true
+
+		f, err := keywrap.Parse(b)
+		if err != nil {
+			return fmt.Errorf("failed to parse wrapped key file: %w", err)
+		}
+		if f.Method == keywrap.MethodSSH && f.SSH != nil {
+			k.wrappedWithSSH = f.SSH.Fingerprint
+		}
+		if k.sshKey != "" && k.wrappedWithSSH != k.sshKey {
+			return fmt.Errorf("%w: %s, expected %s", ErrWrongSSHKey, k.KeySource(), k.sshKey)
+		}
+
+		b, k.unlockedWith, err = unlockKeyFile(b, k.KeySource(), k.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	return k.parseKeys(b)
+}
+
+// readKeyFile returns the contents of the key file, or of the key file
+// supplied by the environment if the keeper takes one, or of the key in the
+// key store.
+func (k *Keeper) readKeyFile() ([]byte, error) {
+	if k.suppliedKey {
+		b, source, err := suppliedKeyFile()
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			k.source = source
+			return b, nil
+		}
+	}
+
+	// Keepers that know which key they want look for it in the key store
+	// when there's no key file at their key path.
+	k.keyPath = FindKey(k.keyPath, k.fingerprint)
+
+	return afero.ReadFile(fs, k.keyPath)
+}
+
+// KeySource describes where the key was read from: its key path, or the
+// environment variable or file descriptor that supplied it.
+func (k *Keeper) KeySource() string {
+	if k.source != "" {
+		return k.source
+	}
+
+	return k.keyPath
+}
+
+// parseKeys loads the key from the plain contents of a key file and wipes
+// them, unless the key keeps them.
+func (k *Keeper) parseKeys(b []byte) error {
 	var key any
+	var err error
 	switch k.encryptionType {
 	case AES256:
 		key = new(aes.EncryptionKey)
 	case ECC256:
 		key = new(ecc.Keys)
-	case RSA2048:
+	case RSA2048, RSA4096:
 		key = new(rsa.Keys)
 	case Serpent256:
 		key = new(serpent.EncryptionKey)
+	case XChaCha20:
+		key = new(xchacha20.EncryptionKey)
+	case AES256SIV:
+		key = new(aessiv.EncryptionKey)
+	case MLKEM768X25519:
+		key = new(pqhybrid.Keys)
+	case X25519:
+		key = new(x25519.Identity)
+	case Age:
+		key, err = age.ParseKeys(b, k.readSSHPublicKey, func() ([]byte, error) {
+			return readPassphrase(k.KeySource(), k.passphrase)
+		})
+		if err != nil {
+			return err
+		}
+
+		k.encryptionKey = key
+
+		return nil
 	}
 
 	err = json.Unmarshal(b, key)
+	securemem.Wipe(b)
 	if err != nil {
 		return err
 	}
 
+	if l, ok := key.(lockable); ok {
+		l.LockMemory()
+	}
+
 	k.encryptionKey = key
 
 	return nil
@@ -232,10 +777,20 @@ func (k *Keeper) fetchKeys() error {
 // }
 
 func validateEncryptionType(t EncryptionType) error {
+	if WrapsDataKey(t) {
+		return nil
+	}
+
 	switch t {
-	case AES256, ECC256, RSA2048, Serpent256:
+	case AES256, AES256SIV, ECC256, RSA2048, RSA4096, Serpent256, XChaCha20, X25519, Age, MLKEM768X25519:
 		return nil
 	default:
 		return ErrUnknownEncryptionType
 	}
 }
+
+// readSSHPublicKey reads the public key next to a passphrase-protected SSH
+// key.
+func (k *Keeper) readSSHPublicKey() ([]byte, error) {
+	return afero.ReadFile(fs, k.keyPath+".pub")
+}
@@ -0,0 +1,844 @@
+package crypt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/aes"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/age"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/ecc"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/mnemonic"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/sshkey"
+)
+
+func newKeeper(t *testing.T, enc crypt.EncryptionType) *crypt.Keeper {
+	t.Helper()
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	err := crypt.GenerateKeys(enc, keyPath)
+	assert.NoError(t, err)
+
+	keeper, err := crypt.NewKeeper(enc, keyPath)
+	assert.NoError(t, err)
+
+	return keeper
+}
+
+func TestKeeperEncryptDecrypt(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.ECC256, crypt.RSA2048, crypt.Serpent256, crypt.XChaCha20, crypt.AES256SIV, crypt.MLKEM768X25519} {
+		t.Run(string(enc), func(t *testing.T) {
+			keeper := newKeeper(t, enc)
+
+			cipher, err := keeper.Encrypt("FOO", "bar")
+			assert.NoError(err)
+			assert.True(strings.HasPrefix(cipher, "ck:v2:"+string(enc)+":"))
+
+			plainText, err := keeper.Decrypt("FOO", cipher)
+			assert.NoError(err)
+			assert.Equal("bar", plainText)
+		})
+	}
+}
+
+func TestKeeperDecryptLegacyValue(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := aes.GenerateKeys()
+	assert.NoError(err)
+
+	b, err := json.Marshal(key)
+	assert.NoError(err)
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(os.WriteFile(keyPath, b, 0600))
+
+	// Values written before the envelope existed are bare base64 straight
+	// from the Encrypter, without any associated data.
+	legacy, err := new(aes.AES256).Encrypt("bar", key, nil)
+	assert.NoError(err)
+
+	keeper, err := crypt.NewKeeper(crypt.AES256, keyPath)
+	assert.NoError(err)
+
+	plainText, err := keeper.Decrypt("FOO", legacy)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+
+	strict, err := crypt.NewKeeper(crypt.AES256, keyPath, crypt.WithProjectID("project"))
+	assert.NoError(err)
+
+	_, err = strict.Decrypt("FOO", legacy)
+	assert.ErrorIs(err, crypt.ErrUnboundValue)
+}
+
+func TestKeeperBindsValues(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.ECC256, crypt.RSA2048, crypt.Serpent256, crypt.XChaCha20, crypt.AES256SIV, crypt.MLKEM768X25519} {
+		t.Run(string(enc), func(t *testing.T) {
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			assert.NoError(crypt.GenerateKeys(enc, keyPath))
+
+			keeper, err := crypt.NewKeeper(enc, keyPath, crypt.WithProjectID("project"))
+			assert.NoError(err)
+
+			cipher, err := keeper.Encrypt("FOO", "bar")
+			assert.NoError(err)
+
+			_, err = keeper.Decrypt("BAR", cipher)
+			assert.ErrorIs(err, crypt.ErrAuthenticationFailed)
+
+			other, err := crypt.NewKeeper(enc, keyPath, crypt.WithProjectID("other"))
+			assert.NoError(err)
+
+			_, err = other.Decrypt("FOO", cipher)
+			assert.ErrorIs(err, crypt.ErrAuthenticationFailed)
+
+			// A value that can't be read isn't reported as tampered with.
+			i := strings.LastIndex(cipher, ":")
+			_, err = keeper.Decrypt("FOO", cipher[:i+1]+"not base64!")
+			assert.Error(err)
+			assert.NotErrorIs(err, crypt.ErrAuthenticationFailed)
+		})
+	}
+}
+
+func TestKeeperDeterministic(t *testing.T) {
+	assert := assert.New(t)
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.AES256SIV, keyPath))
+
+	keeper, err := crypt.NewKeeper(crypt.AES256SIV, keyPath, crypt.WithProjectID("project"))
+	assert.NoError(err)
+
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+
+	again, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+	assert.Equal(cipher, again)
+
+	// The secret name and project are part of the input, so equal values of
+	// other secrets or projects still look different.
+	other, err := keeper.Encrypt("BAR", "bar")
+	assert.NoError(err)
+	assert.NotEqual(cipher, other)
+
+	otherProject, err := crypt.NewKeeper(crypt.AES256SIV, keyPath, crypt.WithProjectID("other"))
+	assert.NoError(err)
+
+	other, err = otherProject.Encrypt("FOO", "bar")
+	assert.NoError(err)
+	assert.NotEqual(cipher, other)
+}
+
+func TestKeeperDecryptWithRetiredKey(t *testing.T) {
+	assert := assert.New(t)
+
+	old := newKeeper(t, crypt.RSA2048)
+	current := newKeeper(t, crypt.AES256)
+
+	cipher, err := old.Encrypt("FOO", "bar")
+	assert.NoError(err)
+
+	_, err = current.Decrypt("FOO", cipher)
+	assert.ErrorIs(err, crypt.ErrKeyNotFound)
+
+	current.AddRetired(old)
+
+	plainText, err := current.Decrypt("FOO", cipher)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+}
+
+func TestKeeperDecryptWithWrongKey(t *testing.T) {
+	assert := assert.New(t)
+
+	cipher, err := newKeeper(t, crypt.AES256).Encrypt("FOO", "bar")
+	assert.NoError(err)
+
+	_, err = newKeeper(t, crypt.AES256).Decrypt("FOO", cipher)
+	assert.ErrorIs(err, crypt.ErrKeyNotFound)
+
+	_, err = newKeeper(t, crypt.AES256).Decrypt("FOO", "ck:v1:aes256:garbage")
+	assert.ErrorIs(err, crypt.ErrMalformedEnvelope)
+}
+
+func TestKeeperProtectedKey(t *testing.T) {
+	assert := assert.New(t)
+
+	passphrase := func() ([]byte, error) {
+		return []byte("hunter2"), nil
+	}
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.ECC256} {
+		t.Run(string(enc), func(t *testing.T) {
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			assert.NoError(crypt.GenerateKeys(enc, keyPath, crypt.WithPassphrase(passphrase)))
+
+			protected, err := crypt.IsProtected(keyPath)
+			assert.NoError(err)
+			assert.True(protected)
+
+			keeper, err := crypt.NewKeeper(enc, keyPath, crypt.WithPassphrase(passphrase))
+			assert.NoError(err)
+
+			cipher, err := keeper.Encrypt("FOO", "bar")
+			assert.NoError(err)
+
+			protected, err = crypt.IsProtected(keyPath)
+			assert.NoError(err)
+			assert.True(protected)
+
+			plainText, err := keeper.Decrypt("FOO", cipher)
+			assert.NoError(err)
+			assert.Equal("bar", plainText)
+
+			assert.NoError(crypt.UnprotectKey(keyPath, crypt.WithPassphrase(passphrase)))
+			assert.ErrorIs(crypt.UnprotectKey(keyPath), crypt.ErrKeyUnprotected)
+		})
+	}
+}
+
+func TestKeeperSSHProtectedKey(t *testing.T) {
+	assert := assert.New(t)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	_, sshKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+	block, err := ssh.MarshalPrivateKey(sshKey, "")
+	assert.NoError(err)
+	assert.NoError(os.Mkdir(filepath.Join(home, ".ssh"), 0700))
+	assert.NoError(os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), pem.EncodeToMemory(block), 0600))
+
+	fingerprint, err := sshkey.Resolve("")
+	assert.NoError(err)
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.ECC256} {
+		t.Run(string(enc), func(t *testing.T) {
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			assert.NoError(crypt.GenerateKeys(enc, keyPath, crypt.WithSSHKey(fingerprint)))
+
+			protectedWith, err := crypt.SSHKeyFingerprint(keyPath)
+			assert.NoError(err)
+			assert.Equal(fingerprint, protectedWith)
+
+			keeper, err := crypt.NewKeeper(enc, keyPath, crypt.WithSSHKey(fingerprint))
+			assert.NoError(err)
+
+			cipher, err := keeper.Encrypt("FOO", "bar")
+			assert.NoError(err)
+
+			plainText, err := keeper.Decrypt("FOO", cipher)
+			assert.NoError(err)
+			assert.Equal("bar", plainText)
+
+			_, err = crypt.NewKeeper(enc, keyPath, crypt.WithSSHKey("SHA256:other"))
+			assert.ErrorIs(err, crypt.ErrWrongSSHKey)
+
+			assert.NoError(crypt.UnprotectKey(keyPath))
+			assert.ErrorIs(crypt.UnprotectKey(keyPath), crypt.ErrKeyUnprotected)
+
+			assert.NoError(crypt.ProtectKeyWithSSH(keyPath, fingerprint))
+			assert.ErrorIs(crypt.ProtectKeyWithSSH(keyPath, fingerprint), crypt.ErrKeyProtected)
+		})
+	}
+}
+
+func TestKeeperPublicKeyOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	keeper := newKeeper(t, crypt.ECC256)
+
+	publicKeyPath := filepath.Join(t.TempDir(), ".ckkey.pub")
+	assert.NoError(keeper.WritePublicKey(publicKeyPath))
+
+	public, err := crypt.NewKeeper(crypt.ECC256, publicKeyPath)
+	assert.NoError(err)
+
+	cipher, err := public.Encrypt("FOO", "bar")
+	assert.NoError(err)
+
+	_, err = public.Decrypt("FOO", cipher)
+	assert.ErrorIs(err, crypt.ErrPublicKeyOnly)
+
+	_, err = public.MAC([]byte("config"))
+	assert.ErrorIs(err, crypt.ErrNoMACKey)
+
+	plainText, err := keeper.Decrypt("FOO", cipher)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+
+	assert.ErrorIs(newKeeper(t, crypt.AES256).WritePublicKey(publicKeyPath), crypt.ErrNoPublicKey)
+}
+
+func TestKeeperLegacyECCKeyFile(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := ecc.EphermalKey()
+	assert.NoError(err)
+
+	legacy, err := new(aes.AES256).Encrypt("bar", legacyECCKey(key), nil)
+	assert.NoError(err)
+
+	// Key files from before ECIES only hold a key per secret.
+	b, err := json.Marshal(map[string]any{"keys": map[string]*ecc.Key{"FOO": key}})
+	assert.NoError(err)
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(os.WriteFile(keyPath, b, 0600))
+
+	keeper, err := crypt.NewKeeper(crypt.ECC256, keyPath)
+	assert.NoError(err)
+
+	plainText, err := keeper.Decrypt("FOO", legacy)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+
+	cipher, err := keeper.Encrypt("BAR", "baz")
+	assert.NoError(err)
+
+	reloaded, err := crypt.NewKeeper(crypt.ECC256, keyPath)
+	assert.NoError(err)
+
+	plainText, err = reloaded.Decrypt("BAR", cipher)
+	assert.NoError(err)
+	assert.Equal("baz", plainText)
+
+	plainText, err = reloaded.Decrypt("FOO", legacy)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+}
+
+// legacyECCKey derives the AES key that values were encrypted with before
+// ECIES.
+func legacyECCKey(key *ecc.Key) *aes.EncryptionKey {
+	x, _ := elliptic.P256().ScalarMult(key.Private.X, key.Private.Y, key.Private.D.Bytes())
+
+	aesKey := make([]byte, 32)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, x.Bytes(), nil, nil), aesKey)
+
+	return &aes.EncryptionKey{Key: aesKey}
+}
+
+func TestGenerateKeysKeySize(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name string
+		enc  crypt.EncryptionType
+		bits int
+		want crypt.EncryptionType
+	}{
+		{"RSA 2048", crypt.RSA2048, 2048, crypt.RSA2048},
+		{"RSA 4096", crypt.RSA2048, 4096, crypt.RSA4096},
+		{"RSA 3072", crypt.RSA2048, 3072, ""},
+		{"AES 256", crypt.AES256, 256, crypt.AES256},
+		{"AES 128", crypt.AES256, 128, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resized, err := crypt.ResizeEncryptionType(tt.enc, tt.bits)
+			if tt.want == "" {
+				assert.ErrorIs(err, crypt.ErrUnsupportedKeySize)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tt.want, resized)
+		})
+	}
+
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	err := crypt.GenerateKeys(crypt.RSA2048, keyPath, crypt.WithKeySize(4096))
+	assert.ErrorIs(err, crypt.ErrUnsupportedKeySize)
+	assert.NoFileExists(keyPath)
+}
+
+func TestKeeperTeam(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.X25519, crypt.Age} {
+		t.Run(string(enc), func(t *testing.T) {
+			dir := t.TempDir()
+			alicePath := filepath.Join(dir, "alice")
+			bobPath := filepath.Join(dir, "bob")
+			evePath := filepath.Join(dir, "eve")
+
+			var recipients []string
+			for _, path := range []string{alicePath, bobPath, evePath} {
+				assert.NoError(crypt.GenerateKeys(enc, path))
+
+				keeper, err := crypt.NewKeeper(enc, path)
+				assert.NoError(err)
+
+				recipient, err := keeper.Recipient()
+				assert.NoError(err)
+				assert.NoError(crypt.ValidateRecipient(enc, recipient))
+
+				recipients = append(recipients, recipient)
+			}
+
+			// Eve isn't on the team.
+			team := recipients[:2]
+			teamSecret, err := crypt.NewTeamSecret(enc, team)
+			assert.NoError(err)
+
+			member := func(path string) *crypt.Keeper {
+				keeper, err := crypt.NewKeeper(enc, path,
+					crypt.WithProjectID("project"),
+					crypt.WithRecipients(team),
+					crypt.WithTeamSecret(teamSecret),
+				)
+				assert.NoError(err)
+
+				return keeper
+			}
+
+			cipher, err := member(alicePath).Encrypt("FOO", "bar")
+			assert.NoError(err)
+			if enc == crypt.Age {
+				assert.True(strings.HasPrefix(cipher, age.ArmorHeader))
+			}
+
+			plainText, err := member(bobPath).Decrypt("FOO", cipher)
+			assert.NoError(err)
+			assert.Equal("bar", plainText)
+
+			_, err = member(evePath).Decrypt("FOO", cipher)
+			assert.ErrorIs(err, crypt.ErrNotRecipient)
+
+			// Members share the MAC key, outsiders can't derive it.
+			aliceMAC, err := member(alicePath).MAC([]byte("config"))
+			assert.NoError(err)
+
+			valid, err := member(bobPath).VerifyMAC([]byte("config"), aliceMAC)
+			assert.NoError(err)
+			assert.True(valid)
+
+			_, err = member(evePath).MAC([]byte("config"))
+			assert.ErrorIs(err, crypt.ErrNotRecipient)
+		})
+	}
+}
+
+func TestKeeperPadding(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.ECC256, crypt.RSA2048, crypt.Serpent256, crypt.XChaCha20, crypt.AES256SIV, crypt.MLKEM768X25519} {
+		t.Run(string(enc), func(t *testing.T) {
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			assert.NoError(crypt.GenerateKeys(enc, keyPath))
+
+			padded, err := crypt.NewKeeper(enc, keyPath, crypt.WithPadding(&crypt.Padding{Block: 64}))
+			assert.NoError(err)
+
+			pin, err := padded.Encrypt("FOO", "1234")
+			assert.NoError(err)
+			assert.True(strings.HasPrefix(pin, "ck:v3:"+string(enc)+":"))
+
+			token, err := padded.Encrypt("FOO", strings.Repeat("x", 40))
+			assert.NoError(err)
+			assert.Len(token, len(pin))
+
+			plainText, err := padded.Decrypt("FOO", pin)
+			assert.NoError(err)
+			assert.Equal("1234", plainText)
+
+			// Padded values are marked, so a keeper without padding still
+			// strips it, and values from before padding was turned on still
+			// decrypt.
+			unpadded, err := crypt.NewKeeper(enc, keyPath)
+			assert.NoError(err)
+
+			plainText, err = unpadded.Decrypt("FOO", token)
+			assert.NoError(err)
+			assert.Equal(strings.Repeat("x", 40), plainText)
+
+			old, err := unpadded.Encrypt("FOO", "1234")
+			assert.NoError(err)
+			assert.Less(len(old), len(pin))
+
+			plainText, err = padded.Decrypt("FOO", old)
+			assert.NoError(err)
+			assert.Equal("1234", plainText)
+
+			// The envelope version is authenticated, so the padding can't
+			// be left on, or stripped from an unpadded value.
+			_, err = padded.Decrypt("FOO", strings.Replace(pin, "ck:v3:", "ck:v2:", 1))
+			assert.ErrorIs(err, crypt.ErrAuthenticationFailed)
+
+			_, err = padded.Decrypt("FOO", strings.Replace(old, "ck:v2:", "ck:v3:", 1))
+			assert.ErrorIs(err, crypt.ErrAuthenticationFailed)
+		})
+	}
+}
+
+func TestKeeperSplitCombineKey(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.ECC256, crypt.RSA2048, crypt.Serpent256, crypt.XChaCha20, crypt.AES256SIV, crypt.MLKEM768X25519} {
+		t.Run(string(enc), func(t *testing.T) {
+			keeper := newKeeper(t, enc)
+
+			cipher, err := keeper.Encrypt("FOO", "bar")
+			assert.NoError(err)
+
+			shares, err := keeper.SplitKey(5, 3)
+			assert.NoError(err)
+			assert.Len(shares, 5)
+
+			fingerprint, err := keeper.Fingerprint()
+			assert.NoError(err)
+			assert.Contains(shares[0], ":"+fingerprint+":")
+
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			alg, rebuiltFingerprint, err := crypt.CombineKey([]string{shares[4], shares[0], shares[2]}, keyPath)
+			assert.NoError(err)
+			assert.Equal(enc, alg)
+			assert.Equal(fingerprint, rebuiltFingerprint)
+
+			rebuilt, err := crypt.NewKeeper(enc, keyPath)
+			assert.NoError(err)
+
+			plainText, err := rebuilt.Decrypt("FOO", cipher)
+			assert.NoError(err)
+			assert.Equal("bar", plainText)
+		})
+	}
+}
+
+func TestKeeperSplitCombineTeamKey(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.X25519, crypt.Age} {
+		t.Run(string(enc), func(t *testing.T) {
+			keeper := newKeeper(t, enc)
+
+			recipient, err := keeper.Recipient()
+			assert.NoError(err)
+
+			shares, err := keeper.SplitKey(2, 2)
+			assert.NoError(err)
+
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			_, _, err = crypt.CombineKey(shares, keyPath)
+			assert.NoError(err)
+
+			rebuilt, err := crypt.NewKeeper(enc, keyPath)
+			assert.NoError(err)
+
+			rebuiltRecipient, err := rebuilt.Recipient()
+			assert.NoError(err)
+			assert.Equal(recipient, rebuiltRecipient)
+		})
+	}
+}
+
+func TestCombineKeyRejectsBadShares(t *testing.T) {
+	assert := assert.New(t)
+
+	keeper := newKeeper(t, crypt.AES256)
+	shares, err := keeper.SplitKey(5, 3)
+	assert.NoError(err)
+
+	again, err := keeper.SplitKey(5, 3)
+	assert.NoError(err)
+
+	other, err := newKeeper(t, crypt.AES256).SplitKey(5, 3)
+	assert.NoError(err)
+
+	// Swap two characters of the payload, as a typo would.
+	typo := []byte(shares[1])
+	i := strings.LastIndex(shares[1], ":") - 2
+	typo[i], typo[i-1] = typo[i-1], typo[i]
+	if typo[i] == typo[i-1] {
+		typo[i]++
+	}
+
+	for _, tt := range []struct {
+		name   string
+		shares []string
+		err    error
+	}{
+		{"Mistyped share", []string{shares[0], string(typo), shares[2]}, crypt.ErrShareChecksum},
+		{"Too few shares", shares[:2], crypt.ErrTooFewShares},
+		{"No shares", nil, crypt.ErrTooFewShares},
+		{"Shares of another key", []string{shares[0], shares[1], other[2]}, crypt.ErrMixedShares},
+		{"Shares of another split", []string{shares[0], shares[1], again[2]}, crypt.ErrMixedShares},
+		{"Repeated share", []string{shares[0], shares[1], shares[1]}, crypt.ErrMixedShares},
+		{"Not a share", []string{"hello"}, crypt.ErrMalformedShare},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			_, _, err := crypt.CombineKey(tt.shares, keyPath)
+			assert.ErrorIs(err, tt.err)
+			assert.NoFileExists(keyPath)
+		})
+	}
+}
+
+func TestKeeperSplitProtectedKey(t *testing.T) {
+	assert := assert.New(t)
+
+	passphrase := func() ([]byte, error) {
+		return []byte("hunter2"), nil
+	}
+	keyPath := filepath.Join(t.TempDir(), ".ckkey")
+	assert.NoError(crypt.GenerateKeys(crypt.AES256, keyPath, crypt.WithPassphrase(passphrase)))
+
+	keeper, err := crypt.NewKeeper(crypt.AES256, keyPath, crypt.WithPassphrase(passphrase))
+	assert.NoError(err)
+
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+
+	shares, err := keeper.SplitKey(3, 2)
+	assert.NoError(err)
+
+	// The rebuilt key is the plain key, so it works without the passphrase.
+	rebuiltPath := filepath.Join(t.TempDir(), ".ckkey")
+	_, _, err = crypt.CombineKey(shares[1:], rebuiltPath)
+	assert.NoError(err)
+
+	rebuilt, err := crypt.NewKeeper(crypt.AES256, rebuiltPath)
+	assert.NoError(err)
+
+	plainText, err := rebuilt.Decrypt("FOO", cipher)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+}
+
+func TestKeeperMnemonic(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.Serpent256} {
+		t.Run(string(enc), func(t *testing.T) {
+			keeper := newKeeper(t, enc)
+
+			cipher, err := keeper.Encrypt("FOO", "bar")
+			assert.NoError(err)
+
+			words, err := keeper.Mnemonic()
+			assert.NoError(err)
+			assert.Len(strings.Fields(words), 24)
+
+			fingerprint, err := keeper.Fingerprint()
+			assert.NoError(err)
+
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			imported, err := crypt.ImportMnemonic(enc, words, keyPath)
+			assert.NoError(err)
+			assert.Equal(fingerprint, imported)
+
+			importedKeeper, err := crypt.NewKeeper(enc, keyPath)
+			assert.NoError(err)
+
+			plainText, err := importedKeeper.Decrypt("FOO", cipher)
+			assert.NoError(err)
+			assert.Equal("bar", plainText)
+
+			_, err = crypt.ImportMnemonic(enc, words, keyPath)
+			assert.Error(err)
+		})
+	}
+
+	_, err := newKeeper(t, crypt.XChaCha20).Mnemonic()
+	assert.ErrorIs(err, crypt.ErrMnemonicUnsupported)
+
+	_, err = crypt.ImportMnemonic(crypt.AES256, "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", filepath.Join(t.TempDir(), ".ckkey"))
+	assert.ErrorIs(err, mnemonic.ErrInvalidLength)
+}
+
+func TestImportKeyFile(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.ECC256, crypt.Serpent256, crypt.XChaCha20, crypt.MLKEM768X25519} {
+		t.Run(string(enc), func(t *testing.T) {
+			keeper := newKeeper(t, enc)
+
+			cipher, err := keeper.Encrypt("FOO", "bar")
+			assert.NoError(err)
+
+			b, err := keeper.PlainKeyFile()
+			assert.NoError(err)
+
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			_, err = crypt.ImportKeyFile(enc, b, keyPath)
+			assert.NoError(err)
+
+			importedKeeper, err := crypt.NewKeeper(enc, keyPath)
+			assert.NoError(err)
+
+			plainText, err := importedKeeper.Decrypt("FOO", cipher)
+			assert.NoError(err)
+			assert.Equal("bar", plainText)
+		})
+	}
+
+	_, err := crypt.ImportKeyFile(crypt.AES256, []byte("not a key"), filepath.Join(t.TempDir(), ".ckkey"))
+	assert.Error(err)
+}
+
+func TestKeeperFingerprint(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enc := range []crypt.EncryptionType{crypt.AES256, crypt.ECC256, crypt.RSA2048, crypt.Serpent256, crypt.XChaCha20, crypt.AES256SIV, crypt.MLKEM768X25519, crypt.X25519, crypt.Age} {
+		t.Run(string(enc), func(t *testing.T) {
+			keyPath := filepath.Join(t.TempDir(), ".ckkey")
+			assert.NoError(crypt.GenerateKeys(enc, keyPath))
+
+			keeper, err := crypt.NewKeeper(enc, keyPath)
+			assert.NoError(err)
+
+			fingerprint, err := keeper.Fingerprint()
+			assert.NoError(err)
+			assert.Regexp(`^SHA256:[0-9a-f]{64}$`, fingerprint)
+
+			// The fingerprint is stable and doesn't depend on the keeper.
+			same, err := crypt.NewKeeper(enc, keyPath, crypt.WithFingerprint(fingerprint))
+			assert.NoError(err)
+
+			again, err := same.Fingerprint()
+			assert.NoError(err)
+			assert.Equal(fingerprint, again)
+
+			other, err := newKeeper(t, enc).Fingerprint()
+			assert.NoError(err)
+			assert.NotEqual(fingerprint, other)
+
+			_, err = crypt.NewKeeper(enc, keyPath, crypt.WithFingerprint(other))
+			assert.ErrorIs(err, crypt.ErrWrongKey)
+		})
+	}
+}
+
+func TestKeeperFingerprintPublicKeyOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	keeper := newKeeper(t, crypt.ECC256)
+	fingerprint, err := keeper.Fingerprint()
+	assert.NoError(err)
+
+	publicKeyPath := filepath.Join(t.TempDir(), ".ckkey.pub")
+	assert.NoError(keeper.WritePublicKey(publicKeyPath))
+
+	// The public key file holds the same key pair.
+	public, err := crypt.NewKeeper(crypt.ECC256, publicKeyPath, crypt.WithFingerprint(fingerprint))
+	assert.NoError(err)
+
+	publicFingerprint, err := public.Fingerprint()
+	assert.NoError(err)
+	assert.Equal(fingerprint, publicFingerprint)
+}
+
+func TestKeeperSuppliedKey(t *testing.T) {
+	keeper := newKeeper(t, crypt.AES256)
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(t, err)
+
+	plain, err := keeper.PlainKeyFile()
+	assert.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "ci.ckkey")
+	assert.NoError(t, os.WriteFile(keyFile, plain, 0600))
+
+	// There's no key file at the key path, only the supplied key.
+	missing := filepath.Join(t.TempDir(), ".ckkey")
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		err   error
+		found bool
+	}{
+		{"CK_KEY", map[string]string{crypt.KeyEnvVar: base64.StdEncoding.EncodeToString(plain) + "\n"}, nil, true},
+		{"CK_KEY_FILE", map[string]string{crypt.KeyFileEnvVar: keyFile}, nil, true},
+		{"Both", map[string]string{crypt.KeyEnvVar: base64.StdEncoding.EncodeToString(plain), crypt.KeyFileEnvVar: keyFile}, crypt.ErrAmbiguousSuppliedKey, false},
+		{"Neither", nil, os.ErrNotExist, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			t.Setenv(crypt.KeyEnvVar, "")
+			t.Setenv(crypt.KeyFileEnvVar, "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			supplied, err := crypt.NewKeeper(crypt.AES256, missing, crypt.WithSuppliedKey())
+			assert.ErrorIs(err, tt.err)
+			if !tt.found {
+				return
+			}
+
+			plainText, err := supplied.Decrypt("FOO", cipher)
+			assert.NoError(err)
+			assert.Equal("bar", plainText)
+
+			// Keepers that don't take a supplied key ignore it.
+			_, err = crypt.NewKeeper(crypt.AES256, missing)
+			assert.ErrorIs(err, os.ErrNotExist)
+		})
+	}
+}
+
+func TestKeeperSuppliedKeyFD(t *testing.T) {
+	assert := assert.New(t)
+
+	keeper := newKeeper(t, crypt.ECC256)
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+
+	plain, err := keeper.PlainKeyFile()
+	assert.NoError(err)
+
+	r, w, err := os.Pipe()
+	assert.NoError(err)
+	_, err = w.Write(plain)
+	assert.NoError(err)
+	assert.NoError(w.Close())
+
+	crypt.KeyFD = int(r.Fd())
+	t.Cleanup(func() { crypt.KeyFD = 0 })
+
+	// The pipe can only be read once, but every keeper gets the key.
+	missing := filepath.Join(t.TempDir(), ".ckkey")
+	for range 2 {
+		supplied, err := crypt.NewKeeper(crypt.ECC256, missing, crypt.WithSuppliedKey())
+		assert.NoError(err)
+		assert.Contains(supplied.KeySource(), "file descriptor")
+
+		plainText, err := supplied.Decrypt("FOO", cipher)
+		assert.NoError(err)
+		assert.Equal("bar", plainText)
+	}
+
+	// The keeper closed the descriptor after reading it. Closing r now keeps
+	// its finalizer from closing whatever reuses the descriptor later.
+	_ = r.Close()
+}
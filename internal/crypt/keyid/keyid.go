@@ -0,0 +1,28 @@
+package keyid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// idLength is the number of hex characters kept from the digest. Eight bytes
+// is plenty to tell keys apart while keeping ciphertexts short.
+const idLength = 16
+
+// FingerprintPrefix names the digest, as SSH fingerprints do.
+const FingerprintPrefix = "SHA256:"
+
+// New returns a short, stable identifier for the given key material. For
+// asymmetric keys the material should be the public key, so the ID can be
+// computed without access to the private half.
+func New(material []byte) string {
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:])[:idLength]
+}
+
+// Fingerprint returns the full digest of the same material New takes, for
+// comparing keys rather than labelling values. The ID is the start of it.
+func Fingerprint(material []byte) string {
+	sum := sha256.Sum256(material)
+	return FingerprintPrefix + hex.EncodeToString(sum[:])
+}
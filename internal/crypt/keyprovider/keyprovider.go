@@ -0,0 +1,236 @@
+// Package keyprovider wraps data keys with an external key provider: an
+// executable named cryptkeeper-keyprovider-<name> on $PATH, in the spirit of
+// git's credential helpers.
+//
+// The provider is run once per request with the action, wrap or unwrap, as
+// its only argument. It reads one JSON request from stdin and writes one
+// JSON response to stdout:
+//
+//	{"protocol": "cryptkeeper-keyprovider/v1", "action": "wrap",
+//	 "options": {"entry": "cryptkeeper/project"}, "data_key": "<base64>"}
+//	{"wrapped_key": "<opaque string>"}
+//
+//	{"protocol": "cryptkeeper-keyprovider/v1", "action": "unwrap",
+//	 "options": {"entry": "cryptkeeper/project"}, "wrapped_key": "<opaque string>"}
+//	{"data_key": "<base64>"}
+//
+// The options are the ones configured for the provider in .ckrc. They come
+// from the project, so providers must not run or load anything they name;
+// cryptkeeper only runs a provider with options the user has trusted. A
+// provider that fails responds with {"error": "<message>"} and exits
+// non-zero. Its stderr is the user's, and stdin isn't a terminal, so a
+// provider that has to prompt opens the terminal itself.
+package keyprovider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	// Protocol is sent with every request, so providers can refuse
+	// requests they don't understand.
+	Protocol = "cryptkeeper-keyprovider/v1"
+
+	// ExecutablePrefix is prepended to a provider's name to find its
+	// executable.
+	ExecutablePrefix = "cryptkeeper-keyprovider-"
+
+	ActionWrap   = "wrap"
+	ActionUnwrap = "unwrap"
+
+	// maxResponseSize bounds what is read from a provider.
+	maxResponseSize = 1 << 20
+)
+
+var (
+	ErrInvalidName = errors.New("key provider names may only contain lowercase letters, digits, '-' and '_'")
+	ErrNotFound    = errors.New("key provider not found on $PATH")
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Request is what cryptkeeper sends a provider.
+type Request struct {
+	Protocol   string            `json:"protocol"`
+	Action     string            `json:"action"`
+	Options    map[string]string `json:"options,omitempty"`
+	DataKey    []byte            `json:"data_key,omitempty"`
+	WrappedKey string            `json:"wrapped_key,omitempty"`
+}
+
+// Response is what a provider sends back. Error is set when the request
+// failed.
+type Response struct {
+	WrappedKey string `json:"wrapped_key,omitempty"`
+	DataKey    []byte `json:"data_key,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Provider names a key provider and the options it's sent with every
+// request.
+type Provider struct {
+	Name    string
+	Options map[string]string
+}
+
+// Wrap has the provider wrap dataKey and returns the wrapped key.
+func (p Provider) Wrap(dataKey []byte) (string, error) {
+	resp, err := p.call(Request{Action: ActionWrap, DataKey: dataKey})
+	if err != nil {
+		return "", err
+	}
+	if resp.WrappedKey == "" {
+		return "", fmt.Errorf("%s returned no wrapped key", p)
+	}
+
+	return resp.WrappedKey, nil
+}
+
+// Unwrap has the provider unwrap a key returned by Wrap. The data key is the
+// caller's to wipe.
+func (p Provider) Unwrap(wrapped string) ([]byte, error) {
+	resp, err := p.call(Request{Action: ActionUnwrap, WrappedKey: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.DataKey) == 0 {
+		return nil, fmt.Errorf("%s returned no data key", p)
+	}
+
+	return resp.DataKey, nil
+}
+
+func (p Provider) String() string {
+	return fmt.Sprintf("key provider %q", p.Name)
+}
+
+// Path returns the provider's executable.
+func (p Provider) Path() (string, error) {
+	if !validName.MatchString(p.Name) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, p.Name)
+	}
+
+	path, err := exec.LookPath(ExecutablePrefix + p.Name)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, ExecutablePrefix+p.Name)
+	}
+
+	return path, nil
+}
+
+// call runs the provider with req and decodes its response.
+func (p Provider) call(req Request) (Response, error) {
+	path, err := p.Path()
+	if err != nil {
+		return Response{}, err
+	}
+
+	req.Protocol = Protocol
+	req.Options = p.Options
+
+	in, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer securemem.Wipe(in)
+
+	var out bytes.Buffer
+	cmd := exec.Command(path, req.Action)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &limitedWriter{w: &out, n: maxResponseSize}
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	var resp Response
+	decodeErr := json.Unmarshal(out.Bytes(), &resp)
+	securemem.Wipe(out.Bytes())
+
+	switch {
+	case decodeErr == nil && resp.Error != "":
+		return Response{}, fmt.Errorf("%s failed to %s the data key: %s", p, req.Action, resp.Error)
+	case runErr != nil:
+		return Response{}, fmt.Errorf("%s failed to %s the data key: %w", p, req.Action, runErr)
+	case decodeErr != nil:
+		return Response{}, fmt.Errorf("%s returned an invalid response: %w", p, decodeErr)
+	}
+
+	return resp, nil
+}
+
+// limitedWriter fails writes past n bytes, so a runaway provider can't
+// exhaust memory.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(b []byte) (int, error) {
+	if len(b) > l.n {
+		return 0, errors.New("response too large")
+	}
+	l.n -= len(b)
+
+	return l.w.Write(b)
+}
+
+// Handler serves requests in a provider.
+type Handler interface {
+	Wrap(options map[string]string, dataKey []byte) (string, error)
+	Unwrap(options map[string]string, wrapped string) ([]byte, error)
+}
+
+// Serve reads a request from r, has h handle it and writes the response to
+// w. Providers call it from main and exit non-zero when it returns an
+// error; the error has already been sent in the response.
+func Serve(r io.Reader, w io.Writer, h Handler) error {
+	resp, err := handle(r, h)
+	if err != nil {
+		resp = Response{Error: err.Error()}
+	}
+
+	if encodeErr := json.NewEncoder(w).Encode(resp); encodeErr != nil && err == nil {
+		err = encodeErr
+	}
+
+	return err
+}
+
+func handle(r io.Reader, h Handler) (Response, error) {
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r, maxResponseSize)).Decode(&req); err != nil {
+		return Response{}, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Protocol != Protocol {
+		return Response{}, fmt.Errorf("unsupported protocol %q, expected %q", req.Protocol, Protocol)
+	}
+
+	switch req.Action {
+	case ActionWrap:
+		if len(req.DataKey) == 0 {
+			return Response{}, errors.New("no data key to wrap")
+		}
+
+		wrapped, err := h.Wrap(req.Options, req.DataKey)
+
+		return Response{WrappedKey: wrapped}, err
+	case ActionUnwrap:
+		if req.WrappedKey == "" {
+			return Response{}, errors.New("no wrapped key to unwrap")
+		}
+
+		dataKey, err := h.Unwrap(req.Options, req.WrappedKey)
+
+		return Response{DataKey: dataKey}, err
+	default:
+		return Response{}, fmt.Errorf("unknown action %q", req.Action)
+	}
+}
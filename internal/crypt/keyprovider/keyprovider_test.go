@@ -0,0 +1,166 @@
+package keyprovider_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider/providertest"
+)
+
+// reverser "wraps" keys by reversing them, and fails or misbehaves when its
+// options ask it to.
+type reverser struct{}
+
+func (reverser) Wrap(options map[string]string, dataKey []byte) (string, error) {
+	if err := misbehave(options); err != nil {
+		return "", err
+	}
+
+	key := slices.Clone(dataKey)
+	slices.Reverse(key)
+
+	return options["prefix"] + base64.StdEncoding.EncodeToString(key), nil
+}
+
+func (reverser) Unwrap(options map[string]string, wrapped string) ([]byte, error) {
+	if err := misbehave(options); err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(wrapped, options["prefix"]))
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(key)
+
+	return key, nil
+}
+
+func misbehave(options map[string]string) error {
+	switch options["behavior"] {
+	case "fail":
+		return errors.New("the vault is locked")
+	case "crash":
+		os.Exit(3)
+	case "garbage":
+		os.Stdout.WriteString("not json")
+		os.Exit(0)
+	}
+
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	providertest.Main(m, "reverser", reverser{})
+}
+
+func TestProvider(t *testing.T) {
+	assert := assert.New(t)
+	p := keyprovider.Provider{Name: "reverser", Options: map[string]string{"prefix": "rev:"}}
+
+	path, err := p.Path()
+	assert.NoError(err)
+	assert.True(strings.HasSuffix(path, keyprovider.ExecutablePrefix+"reverser"))
+
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	wrapped, err := p.Wrap(dataKey)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(wrapped, "rev:"))
+
+	unwrapped, err := p.Unwrap(wrapped)
+	assert.NoError(err)
+	assert.Equal(dataKey, unwrapped)
+}
+
+func TestProviderFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider keyprovider.Provider
+		err      error
+		contains string
+	}{
+		{"Invalid name", keyprovider.Provider{Name: "../reverser"}, keyprovider.ErrInvalidName, ""},
+		{"Not on PATH", keyprovider.Provider{Name: "missing"}, keyprovider.ErrNotFound, ""},
+		{"Error response", keyprovider.Provider{Name: "reverser", Options: map[string]string{"behavior": "fail"}}, nil, "the vault is locked"},
+		{"Crash", keyprovider.Provider{Name: "reverser", Options: map[string]string{"behavior": "crash"}}, nil, "exit status 3"},
+		{"Garbage", keyprovider.Provider{Name: "reverser", Options: map[string]string{"behavior": "garbage"}}, nil, "invalid response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := tt.provider.Wrap([]byte("key"))
+			assert.Error(err)
+			if tt.err != nil {
+				assert.ErrorIs(err, tt.err)
+			}
+			assert.Contains(err.Error(), tt.contains)
+
+			_, err = tt.provider.Unwrap("wrapped")
+			assert.Error(err)
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		resp    keyprovider.Response
+	}{
+		{
+			name:    "Wrap",
+			request: `{"protocol": "cryptkeeper-keyprovider/v1", "action": "wrap", "data_key": "AQID"}`,
+			resp:    keyprovider.Response{WrappedKey: "AwIB"},
+		},
+		{
+			name:    "Unwrap",
+			request: `{"protocol": "cryptkeeper-keyprovider/v1", "action": "unwrap", "wrapped_key": "AwIB"}`,
+			resp:    keyprovider.Response{DataKey: []byte{1, 2, 3}},
+		},
+		{
+			name:    "Handler error",
+			request: `{"protocol": "cryptkeeper-keyprovider/v1", "action": "wrap", "data_key": "AQID", "options": {"behavior": "fail"}}`,
+			resp:    keyprovider.Response{Error: "the vault is locked"},
+		},
+		{
+			name:    "Unknown protocol",
+			request: `{"protocol": "cryptkeeper-keyprovider/v9", "action": "wrap", "data_key": "AQID"}`,
+			resp:    keyprovider.Response{Error: `unsupported protocol "cryptkeeper-keyprovider/v9", expected "cryptkeeper-keyprovider/v1"`},
+		},
+		{
+			name:    "Unknown action",
+			request: `{"protocol": "cryptkeeper-keyprovider/v1", "action": "get"}`,
+			resp:    keyprovider.Response{Error: `unknown action "get"`},
+		},
+		{
+			name:    "Nothing to wrap",
+			request: `{"protocol": "cryptkeeper-keyprovider/v1", "action": "wrap"}`,
+			resp:    keyprovider.Response{Error: "no data key to wrap"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var out bytes.Buffer
+			err := keyprovider.Serve(strings.NewReader(tt.request), &out, reverser{})
+			assert.Equal(tt.resp.Error != "", err != nil)
+
+			var resp keyprovider.Response
+			assert.NoError(json.Unmarshal(out.Bytes(), &resp))
+			assert.Equal(tt.resp, resp)
+		})
+	}
+}
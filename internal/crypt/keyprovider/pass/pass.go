@@ -0,0 +1,202 @@
+// Package pass is the reference key provider. It wraps data keys with a
+// key derived from a secret kept in pass, the standard unix password
+// manager, or printed by a command - which makes it easy to try key
+// providers out locally.
+//
+// The "entry" option names a pass entry, whose first line is the secret.
+// Without it, the command in $CK_PASS_COMMAND is run with sh -c and what it
+// prints, less trailing newlines, is the secret. Options come from the
+// project's config, so the command is only ever read from the environment.
+package pass
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	// Name is the provider's name in .ckrc. Its executable is
+	// cryptkeeper-keyprovider-pass.
+	Name = "pass"
+
+	// CommandEnvVar holds the command that prints the secret.
+	CommandEnvVar = "CK_PASS_COMMAND"
+
+	// wrappedPrefix versions the wrapped keys Provider returns.
+	wrappedPrefix = "pass:v1:"
+
+	// info binds derived keys to this provider.
+	info = "cryptkeeper-keyprovider-pass v1"
+
+	saltSize = 16
+)
+
+var (
+	ErrNoSecret      = errors.New("the pass key provider needs an 'entry' option or $" + CommandEnvVar)
+	ErrTwoSecrets    = errors.New("the pass key provider takes an 'entry' option or $" + CommandEnvVar + ", not both")
+	ErrCommandOption = errors.New("the pass key provider doesn't run commands from the config - set $" + CommandEnvVar + " instead")
+	ErrEmptySecret   = errors.New("the secret is empty")
+	ErrInvalidFormat = errors.New("not a key wrapped by the pass key provider")
+	ErrWrongSecret   = errors.New("the secret doesn't unwrap this key - it was wrapped with a different one")
+)
+
+// Provider wraps data keys with AES-256-GCM under a key derived from the
+// secret with HKDF-SHA256 and a random salt.
+type Provider struct{}
+
+// Wrap wraps dataKey. The wrapped key is pass:v1: and the base64 encoded
+// salt, nonce and ciphertext.
+func (Provider) Wrap(options map[string]string, dataKey []byte) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(options, salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	out := append(salt, nonce...)
+	out = gcm.Seal(out, nonce, dataKey, []byte(wrappedPrefix))
+
+	return wrappedPrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Unwrap unwraps a key returned by Wrap.
+func (Provider) Unwrap(options map[string]string, wrapped string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(wrapped, wrappedPrefix)
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(b) < saltSize {
+		return nil, ErrInvalidFormat
+	}
+
+	gcm, err := newGCM(options, b[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	b = b[saltSize:]
+	if len(b) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrInvalidFormat
+	}
+
+	dataKey, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], []byte(wrappedPrefix))
+	if err != nil {
+		return nil, ErrWrongSecret
+	}
+
+	return dataKey, nil
+}
+
+// newGCM derives the wrapping key from the secret and salt.
+func newGCM(options map[string]string, salt []byte) (cipher.AEAD, error) {
+	secret, err := readSecret(options)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(secret)
+
+	key := make([]byte, 32)
+	defer securemem.Wipe(key)
+
+	_, err = io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// readSecret reads the secret named by the options, or printed by
+// $CK_PASS_COMMAND.
+func readSecret(options map[string]string) ([]byte, error) {
+	if _, ok := options["command"]; ok {
+		return nil, ErrCommandOption
+	}
+
+	entry, command := options["entry"], os.Getenv(CommandEnvVar)
+
+	var (
+		secret []byte
+		err    error
+	)
+
+	switch {
+	case entry != "" && command != "":
+		return nil, ErrTwoSecrets
+	case entry != "":
+		var out []byte
+		out, err = run("pass", "show", "--", entry)
+		secret = firstLine(out)
+		securemem.Wipe(out)
+	case command != "":
+		secret, err = run("sh", "-c", command)
+		secret = bytes.TrimRight(secret, "\r\n")
+	default:
+		return nil, ErrNoSecret
+	}
+
+	if err != nil {
+		securemem.Wipe(secret)
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	return secret, nil
+}
+
+// run runs name and returns what it printed. Its stderr and the terminal
+// are left to it, so gpg can ask for a passphrase.
+func run(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		cmd.Stdin = tty
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("%s failed: %w", name, err)
+	}
+
+	return out, nil
+}
+
+// firstLine returns a copy of the first line of b, where pass keeps the
+// password.
+func firstLine(b []byte) []byte {
+	line, _, _ := bytes.Cut(b, []byte("\n"))
+	return bytes.Clone(bytes.TrimRight(line, "\r"))
+}
@@ -0,0 +1,76 @@
+package pass_test
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider/pass"
+)
+
+func TestProvider(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh isn't installed")
+	}
+
+	assert := assert.New(t)
+	t.Setenv(pass.CommandEnvVar, "echo correct horse")
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+
+	wrapped, err := pass.Provider{}.Wrap(nil, dataKey)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(wrapped, "pass:v1:"))
+
+	// Every wrap uses a new salt and nonce.
+	again, err := pass.Provider{}.Wrap(nil, dataKey)
+	assert.NoError(err)
+	assert.NotEqual(wrapped, again)
+
+	unwrapped, err := pass.Provider{}.Unwrap(nil, wrapped)
+	assert.NoError(err)
+	assert.Equal(dataKey, unwrapped)
+
+	t.Setenv(pass.CommandEnvVar, "echo battery staple")
+	_, err = pass.Provider{}.Unwrap(nil, wrapped)
+	assert.ErrorIs(err, pass.ErrWrongSecret)
+}
+
+func TestProviderFailures(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh isn't installed")
+	}
+
+	t.Setenv(pass.CommandEnvVar, "echo secret")
+	wrapped, err := pass.Provider{}.Wrap(nil, []byte("data key"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		command string
+		options map[string]string
+		wrapped string
+		err     error
+	}{
+		{"No secret", "", nil, wrapped, pass.ErrNoSecret},
+		{"Two secrets", "echo secret", map[string]string{"entry": "project"}, wrapped, pass.ErrTwoSecrets},
+		{"Command option", "", map[string]string{"command": "echo secret"}, wrapped, pass.ErrCommandOption},
+		{"Empty secret", "true", nil, wrapped, pass.ErrEmptySecret},
+		{"Failing command", "exit 1", nil, wrapped, nil},
+		{"Unknown format", "echo secret", nil, "vault:v1:AAAA", pass.ErrInvalidFormat},
+		{"Truncated", "echo secret", nil, "pass:v1:AAAA", pass.ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(pass.CommandEnvVar, tt.command)
+
+			_, err := pass.Provider{}.Unwrap(tt.options, tt.wrapped)
+			assert.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package providertest runs test binaries as key providers, so tests can
+// exercise the real protocol without installing a provider.
+package providertest
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider"
+)
+
+// Main is called from TestMain. When the test binary is run as the provider
+// called name it serves the request with h and exits. Otherwise it puts a
+// copy of the test binary on $PATH as that provider and runs the tests.
+func Main(m *testing.M, name string, h keyprovider.Handler) {
+	executable := keyprovider.ExecutablePrefix + name
+
+	if strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe") == executable {
+		if err := keyprovider.Serve(os.Stdin, os.Stdout, h); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	dir, err := os.MkdirTemp("", "keyprovider")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := install(filepath.Join(dir, executable)); err != nil {
+		panic(err)
+	}
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// install copies the test binary to path.
+func install(path string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(self)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
@@ -0,0 +1,130 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+// dataHomeEnvVar is the XDG base directory for user data. The key store is
+// kept under it.
+const dataHomeEnvVar = "XDG_DATA_HOME"
+
+var ErrInvalidFingerprint = errors.New("invalid key fingerprint")
+
+// fingerprintPattern keeps a fingerprint from a committed config from
+// naming anything but a file in the key store.
+var fingerprintPattern = regexp.MustCompile(`^` + keyid.FingerprintPrefix + `[0-9a-f]{64}$`)
+
+// dataDir returns the per-user directory cryptkeeper keeps its data in.
+func dataDir() (string, error) {
+	dataHome := os.Getenv(dataHomeEnvVar)
+	if dataHome == "" || !filepath.IsAbs(dataHome) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dataHome, "cryptkeeper"), nil
+}
+
+// KeyStoreDir returns the per-user key store, where keys are kept outside
+// the projects that use them and found by their fingerprint.
+func KeyStoreDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "keys"), nil
+}
+
+// KeyStorePath returns where the key with the given fingerprint is kept in
+// the key store.
+func KeyStorePath(fingerprint string) (string, error) {
+	if !fingerprintPattern.MatchString(fingerprint) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidFingerprint, fingerprint)
+	}
+
+	dir, err := KeyStoreDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, strings.TrimPrefix(fingerprint, keyid.FingerprintPrefix)), nil
+}
+
+// InKeyStore reports whether keyPath is in the key store.
+func InKeyStore(keyPath string) bool {
+	dir, err := KeyStoreDir()
+	if err != nil {
+		return false
+	}
+
+	return filepath.Dir(keyPath) == dir
+}
+
+// FindKey returns keyPath if there's a key file there. Otherwise it returns
+// the path of the key with the given fingerprint in the key store, if the
+// key store has it, or keyPath if it doesn't.
+func FindKey(keyPath, fingerprint string) string {
+	if keyPath != "" && fileutils.FileExists(keyPath) || fingerprint == "" {
+		return keyPath
+	}
+
+	path, err := KeyStorePath(fingerprint)
+	if err != nil || !fileutils.FileExists(path) {
+		return keyPath
+	}
+
+	return path
+}
+
+// StoreKey moves the key file at keyPath into the key store and returns its
+// new path. fingerprint must be the fingerprint of the key it holds.
+func StoreKey(keyPath, fingerprint string) (string, error) {
+	path, err := KeyStorePath(fingerprint)
+	if err != nil {
+		return "", err
+	}
+	if fileutils.FileExists(path) {
+		return "", fmt.Errorf("the key store already holds this key at %s", path)
+	}
+
+	err = fs.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", err
+	}
+
+	// Projects and the key store are often on different file systems, so
+	// the key is copied rather than renamed.
+	b, err := afero.ReadFile(fs, keyPath)
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(b)
+
+	err = fileutils.WriteFile(path, b, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	err = fileutils.Remove(keyPath)
+	if err != nil {
+		return "", fmt.Errorf("copied the key to %s but failed to remove %s: %w", path, keyPath, err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,225 @@
+package keywrap
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	MethodArgon2id = "argon2id"
+	MethodSSH      = "ssh"
+
+	saltSize = 16
+)
+
+var (
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key file")
+	ErrWrongSSHKey     = errors.New("wrong SSH key or corrupted key file")
+	ErrUnknownMethod   = errors.New("unknown key wrapping method")
+)
+
+// File is a key file whose contents are encrypted with a wrapping key. It's
+// written in place of the plain key JSON.
+type File struct {
+	Method     string          `json:"wrap"`
+	Argon2id   *Argon2idParams `json:"argon2id,omitempty"`
+	SSH        *SSHParams      `json:"ssh,omitempty"`
+	Ciphertext []byte          `json:"ciphertext"`
+}
+
+// SSHParams are the inputs to derive the wrapping key from an SSH key.
+// Fingerprint finds the SSH key, and the salt is what it signs.
+type SSHParams struct {
+	Fingerprint string `json:"fingerprint"`
+	Salt        []byte `json:"salt"`
+}
+
+// DeriveFunc derives a wrapping key from salt, e.g. by signing it with an
+// SSH key. The key it returns is wiped once it has been used.
+type DeriveFunc func(salt []byte) ([]byte, error)
+
+// Argon2idParams are the inputs to derive the wrapping key from a passphrase.
+type Argon2idParams struct {
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// DefaultArgon2idParams follows the second recommended option of RFC 9106:
+// three passes over 64 MiB with four lanes.
+func DefaultArgon2idParams() (*Argon2idParams, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	return &Argon2idParams{
+		Salt:    salt,
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+	}, nil
+}
+
+func (p *Argon2idParams) deriveKey(passphrase []byte) []byte {
+	return argon2.IDKey(passphrase, p.Salt, p.Time, p.Memory, p.Threads, 32)
+}
+
+// IsWrapped reports whether the key file contents are wrapped.
+func IsWrapped(b []byte) bool {
+	var probe struct {
+		Method string `json:"wrap"`
+	}
+
+	return json.Unmarshal(b, &probe) == nil && probe.Method != ""
+}
+
+// Parse reads a wrapped key file.
+func Parse(b []byte) (*File, error) {
+	f := new(File)
+	err := json.Unmarshal(b, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// WrapWithPassphrase encrypts key under a key derived from passphrase.
+func WrapWithPassphrase(key, passphrase []byte) (*File, error) {
+	params, err := DefaultArgon2idParams()
+	if err != nil {
+		return nil, err
+	}
+
+	wrappingKey := params.deriveKey(passphrase)
+	defer securemem.Wipe(wrappingKey)
+
+	ciphertext, err := seal(wrappingKey, key, MethodArgon2id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		Method:     MethodArgon2id,
+		Argon2id:   params,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// UnwrapWithPassphrase returns the plain key file contents.
+func (f *File) UnwrapWithPassphrase(passphrase []byte) ([]byte, error) {
+	if f.Method != MethodArgon2id || f.Argon2id == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMethod, f.Method)
+	}
+
+	wrappingKey := f.Argon2id.deriveKey(passphrase)
+	defer securemem.Wipe(wrappingKey)
+
+	return open(wrappingKey, f.Ciphertext, f.Method)
+}
+
+// WrapWithSSH encrypts key under a key derived by the SSH key with the
+// given fingerprint.
+func WrapWithSSH(key []byte, fingerprint string, derive DeriveFunc) (*File, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	wrappingKey, err := derive(salt)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(wrappingKey)
+
+	ciphertext, err := seal(wrappingKey, key, MethodSSH)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		Method:     MethodSSH,
+		SSH:        &SSHParams{Fingerprint: fingerprint, Salt: salt},
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// UnwrapWithSSH returns the plain key file contents. derive must use the SSH
+// key named by f.SSH.Fingerprint.
+func (f *File) UnwrapWithSSH(derive DeriveFunc) ([]byte, error) {
+	if f.Method != MethodSSH || f.SSH == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMethod, f.Method)
+	}
+
+	wrappingKey, err := derive(f.SSH.Salt)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(wrappingKey)
+
+	plaintext, err := open(wrappingKey, f.Ciphertext, f.Method)
+	if errors.Is(err, ErrWrongPassphrase) {
+		return nil, ErrWrongSSHKey
+	}
+
+	return plaintext, err
+}
+
+func (f *File) Marshal() ([]byte, error) {
+	return json.Marshal(f)
+}
+
+// seal encrypts plaintext with AES-256-GCM, binding it to the wrapping
+// method so a file can't be passed off as wrapped by another method.
+func seal(wrappingKey, plaintext []byte, method string) ([]byte, error) {
+	aead, err := newAEAD(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, []byte(method)), nil
+}
+
+func open(wrappingKey, ciphertext []byte, method string) ([]byte, error) {
+	aead, err := newAEAD(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, ErrWrongPassphrase
+	}
+
+	plaintext, err := aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], []byte(method))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,70 @@
+package keywrap_test
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keywrap"
+)
+
+func TestWrapWithPassphrase(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte(`{"key":"c2VjcmV0"}`)
+
+	f, err := keywrap.WrapWithPassphrase(key, []byte("correct horse"))
+	assert.NoError(err)
+
+	b, err := f.Marshal()
+	assert.NoError(err)
+	assert.True(keywrap.IsWrapped(b))
+	assert.False(keywrap.IsWrapped(key))
+	assert.NotContains(string(b), "c2VjcmV0")
+
+	parsed, err := keywrap.Parse(b)
+	assert.NoError(err)
+
+	unwrapped, err := parsed.UnwrapWithPassphrase([]byte("correct horse"))
+	assert.NoError(err)
+	assert.Equal(key, unwrapped)
+
+	_, err = parsed.UnwrapWithPassphrase([]byte("battery staple"))
+	assert.ErrorIs(err, keywrap.ErrWrongPassphrase)
+}
+
+func TestWrapWithSSH(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte(`{"key":"c2VjcmV0"}`)
+	derive := func(secret string) keywrap.DeriveFunc {
+		return func(salt []byte) ([]byte, error) {
+			sum := sha256.Sum256(append([]byte(secret), salt...))
+			return sum[:], nil
+		}
+	}
+
+	f, err := keywrap.WrapWithSSH(key, "SHA256:abc", derive("alice"))
+	assert.NoError(err)
+
+	b, err := f.Marshal()
+	assert.NoError(err)
+	assert.True(keywrap.IsWrapped(b))
+	assert.NotContains(string(b), "c2VjcmV0")
+
+	parsed, err := keywrap.Parse(b)
+	assert.NoError(err)
+	assert.Equal(keywrap.MethodSSH, parsed.Method)
+	assert.Equal("SHA256:abc", parsed.SSH.Fingerprint)
+
+	unwrapped, err := parsed.UnwrapWithSSH(derive("alice"))
+	assert.NoError(err)
+	assert.Equal(key, unwrapped)
+
+	_, err = parsed.UnwrapWithSSH(derive("bob"))
+	assert.ErrorIs(err, keywrap.ErrWrongSSHKey)
+
+	_, err = parsed.UnwrapWithPassphrase([]byte("alice"))
+	assert.ErrorIs(err, keywrap.ErrUnknownMethod)
+}
@@ -0,0 +1,82 @@
+package crypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var ErrNoMACKey = errors.New("key can't be used to authenticate the config")
+
+// macInfo separates the MAC key from every other key derived from the same
+// material.
+const macInfo = "cryptkeeper config integrity v1"
+
+// secretKey is implemented by every key type that holds secret material a
+// MAC key can be derived from.
+type secretKey interface {
+	Secret() []byte
+}
+
+// MAC returns an HMAC-SHA256 of data under a key derived from the loaded
+// encryption key.
+func (k *Keeper) MAC(data []byte) ([]byte, error) {
+	if err := k.lazyInit(); err != nil {
+		return nil, err
+	}
+
+	macKey, err := k.macKey()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(data)
+
+	return mac.Sum(nil), nil
+}
+
+// VerifyMAC reports whether sum is a valid MAC of data.
+func (k *Keeper) VerifyMAC(data, sum []byte) (bool, error) {
+	expected, err := k.MAC(data)
+	if err != nil {
+		return false, err
+	}
+
+	return hmac.Equal(expected, sum), nil
+}
+
+// macKey derives the MAC key in memory. It never writes the key file.
+func (k *Keeper) macKey() ([]byte, error) {
+	secret, err := k.macSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	macKey := make([]byte, 32)
+	_, err = io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(macInfo)), macKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return macKey, nil
+}
+
+// macSecret returns the material the MAC key is derived from. Members of
+// projects with recipients each have their own identity, so they share the
+// team secret instead.
+func (k *Keeper) macSecret() ([]byte, error) {
+	if HasRecipients(k.encryptionType) {
+		return k.openTeamSecret()
+	}
+
+	key, ok := k.encryptionKey.(secretKey)
+	if !ok || len(key.Secret()) == 0 {
+		return nil, ErrNoMACKey
+	}
+
+	return key.Secret(), nil
+}
@@ -0,0 +1,70 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/aes"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/mnemonic"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/serpent"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+var ErrMnemonicUnsupported = errors.New("only aes256 and serpent256 keys can be written as a mnemonic")
+
+// Mnemonic returns the key as a 24-word BIP39 mnemonic, for keys that are a
+// single 32-byte secret. The mnemonic is the plain key, even if the key file
+// is protected.
+func (k *Keeper) Mnemonic() (string, error) {
+	if k.encryptionType != AES256 && k.encryptionType != Serpent256 {
+		return "", fmt.Errorf("%w, not %s keys", ErrMnemonicUnsupported, k.encryptionType)
+	}
+
+	if err := k.lazyInit(); err != nil {
+		return "", err
+	}
+
+	key, ok := k.encryptionKey.(secretKey)
+	if !ok {
+		return "", errors.New("corrupted key file")
+	}
+
+	return mnemonic.Encode(key.Secret())
+}
+
+// ImportMnemonic writes an unprotected key file to keyPath holding the key
+// in a mnemonic returned by Mnemonic. It returns the key's fingerprint.
+func ImportMnemonic(enc EncryptionType, words, keyPath string) (string, error) {
+	if enc != AES256 && enc != Serpent256 {
+		return "", fmt.Errorf("%w, not %s keys", ErrMnemonicUnsupported, enc)
+	}
+	if fileutils.FileExists(keyPath) {
+		return "", fmt.Errorf("key file already exists at %s", keyPath)
+	}
+
+	secret, err := mnemonic.Decode(words)
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(secret)
+
+	if len(secret) != 32 {
+		return "", fmt.Errorf("%w: %s keys are 24 words", mnemonic.ErrInvalidLength, enc)
+	}
+
+	var keys any
+	switch enc {
+	case AES256:
+		keys = &aes.EncryptionKey{Key: secret}
+	case Serpent256:
+		keys = &serpent.EncryptionKey{Key: secret}
+	}
+
+	err = writeKeys(keys, keyPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to write encryption key: %w", err)
+	}
+
+	return keyFingerprint(keys), nil
+}
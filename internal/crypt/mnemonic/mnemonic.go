@@ -0,0 +1,112 @@
+// Package mnemonic encodes short secrets as BIP39 mnemonics: one word from a
+// list of 2048 for every 11 bits, with the end of the last word holding the
+// start of the secret's SHA-256 as a checksum. A 32-byte key is 24 words.
+package mnemonic
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	bitsPerWord = 11
+
+	// prefixLength letters are enough to tell any two words apart, so
+	// words can be written down or typed in shortened to them.
+	prefixLength = 4
+)
+
+var (
+	ErrInvalidLength = errors.New("secrets must be 16 to 32 bytes long in steps of 4 - mnemonics 12 to 24 words in steps of 3")
+	ErrUnknownWord   = errors.New("unknown mnemonic word")
+	ErrChecksum      = errors.New("mnemonic checksum doesn't match - a word was mistyped or left out")
+)
+
+// english.txt is the English list from the BIP39 specification.
+//
+//go:embed english.txt
+var english string
+
+var (
+	words   = strings.Fields(english)
+	indexes = index(words)
+)
+
+func index(words []string) map[string]int {
+	indexes := make(map[string]int, 2*len(words))
+	for i, word := range words {
+		indexes[word] = i
+		if len(word) > prefixLength {
+			indexes[word[:prefixLength]] = i
+		}
+	}
+
+	return indexes
+}
+
+// Encode returns the mnemonic for secret, which must be 16 to 32 bytes long
+// in steps of 4.
+func Encode(secret []byte) (string, error) {
+	if len(secret) < 16 || len(secret) > 32 || len(secret)%4 != 0 {
+		return "", fmt.Errorf("%w: got %d bytes", ErrInvalidLength, len(secret))
+	}
+
+	// The checksum is a bit for every 4 bytes of the secret, so it always
+	// fits in the first byte of the hash.
+	sum := sha256.Sum256(secret)
+	b := append(append(make([]byte, 0, len(secret)+1), secret...), sum[0])
+	defer securemem.Wipe(b)
+
+	mnemonic := make([]string, (len(secret)*8+len(secret)/4)/bitsPerWord)
+	for i := range mnemonic {
+		index := 0
+		for bit := i * bitsPerWord; bit < (i+1)*bitsPerWord; bit++ {
+			index = index<<1 | int(b[bit/8]>>(7-bit%8)&1)
+		}
+
+		mnemonic[i] = words[index]
+	}
+
+	return strings.Join(mnemonic, " "), nil
+}
+
+// Decode returns the secret in a mnemonic written by Encode. Words are
+// separated by whitespace and may be shortened to their first four letters.
+func Decode(mnemonic string) ([]byte, error) {
+	fields := strings.Fields(strings.ToLower(mnemonic))
+	if len(fields) < 12 || len(fields) > 24 || len(fields)%3 != 0 {
+		return nil, fmt.Errorf("%w: got %d words", ErrInvalidLength, len(fields))
+	}
+
+	b := make([]byte, (len(fields)*bitsPerWord+7)/8)
+	defer securemem.Wipe(b)
+
+	for i, word := range fields {
+		index, ok := indexes[word]
+		if !ok {
+			return nil, fmt.Errorf("%w %d: %q", ErrUnknownWord, i+1, word)
+		}
+
+		for j := 0; j < bitsPerWord; j++ {
+			bit := i*bitsPerWord + j
+			b[bit/8] |= byte(index>>(bitsPerWord-1-j)&1) << (7 - bit%8)
+		}
+	}
+
+	checksumBits := len(fields) / 3
+	secret := make([]byte, len(fields)*4/3)
+	copy(secret, b)
+
+	sum := sha256.Sum256(secret)
+	if b[len(secret)]>>(8-checksumBits) != sum[0]>>(8-checksumBits) {
+		securemem.Wipe(secret)
+		return nil, ErrChecksum
+	}
+
+	return secret, nil
+}
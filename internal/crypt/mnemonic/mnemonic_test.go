@@ -0,0 +1,133 @@
+package mnemonic
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordList(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Len(words, 1<<bitsPerWord)
+	assert.Equal("abandon", words[0])
+	assert.Equal("zoo", words[len(words)-1])
+
+	// Every word is told apart by its first four letters.
+	prefixes := make(map[string]bool, len(words))
+	for _, word := range words {
+		prefix := word[:min(len(word), prefixLength)]
+		assert.False(prefixes[prefix], "prefix %q is ambiguous", prefix)
+		prefixes[prefix] = true
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	assert := assert.New(t)
+
+	// Test vectors from the BIP39 reference implementation.
+	tests := []struct {
+		secret   string
+		mnemonic string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		},
+		{
+			"808080808080808080808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+		},
+		{
+			"8080808080808080808080808080808080808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic bless",
+		},
+		{
+			"3e141609b97933b66a060dcddc71fad1d91677db872031e85f4c015c5e7e8982",
+			"dignity pass list indicate nasty swamp pool script soccer toe leaf photo multiply desk host tomato cradle drill spread actor shine dismiss champion exotic",
+		},
+		{
+			"15da872c95a13dd738fbf50e427583ad61f18fd99f628c417a61cf8343c90419",
+			"beyond stage sleep clip because twist token leaf atom beauty genius food business side grid unable middle armed observe pair crouch tonight away coconut",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mnemonic, func(t *testing.T) {
+			secret, err := hex.DecodeString(tt.secret)
+			assert.NoError(err)
+
+			mnemonic, err := Encode(secret)
+			assert.NoError(err)
+			assert.Equal(tt.mnemonic, mnemonic)
+
+			decoded, err := Decode(mnemonic)
+			assert.NoError(err)
+			assert.Equal(secret, decoded)
+		})
+	}
+}
+
+func TestDecodeNormalizes(t *testing.T) {
+	assert := assert.New(t)
+
+	mnemonic := "  DIGN pass list indi\nnasty swam pool scri soccer toe leaf phot multiply desk host toma cradle drill spre actor shine dism cham exot\n"
+
+	secret, err := Decode(mnemonic)
+	assert.NoError(err)
+	assert.Equal("3e141609b97933b66a060dcddc71fad1d91677db872031e85f4c015c5e7e8982", hex.EncodeToString(secret))
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	valid := strings.Fields("dignity pass list indicate nasty swamp pool script soccer toe leaf photo multiply desk host tomato cradle drill spread actor shine dismiss champion exotic")
+	replace := func(i int, word string) string {
+		words := append([]string{}, valid...)
+		words[i] = word
+		return strings.Join(words, " ")
+	}
+
+	tests := []struct {
+		name     string
+		mnemonic string
+		err      error
+	}{
+		{"Mistyped word", replace(5, "swan"), ErrUnknownWord},
+		{"Ambiguous prefix", replace(5, "swa"), ErrUnknownWord},
+		{"Wrong word", replace(5, "swap"), ErrChecksum},
+		{"Repeated word", replace(0, "pass"), ErrChecksum},
+		{"Missing word", strings.Join(valid[1:], " "), ErrInvalidLength},
+		{"Empty", "", ErrInvalidLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.mnemonic)
+			assert.ErrorIs(err, tt.err)
+		})
+	}
+}
+
+func TestEncodeInvalidLength(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, n := range []int{0, 12, 17, 36, 64} {
+		_, err := Encode(make([]byte, n))
+		assert.ErrorIs(err, ErrInvalidLength, "%d bytes", n)
+	}
+}
@@ -0,0 +1,123 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Option configures a Keeper.
+type Option func(*options)
+
+type options struct {
+	// projectID is bound to every value as associated data, so values can't
+	// be copied between projects.
+	projectID string
+
+	// passphrase unlocks wrapped key files. GenerateKeys wraps the new key
+	// file with it.
+	passphrase func() ([]byte, error)
+
+	// sshKey is the fingerprint of the SSH key that protects the key file.
+	// GenerateKeys wraps the new key file with it.
+	sshKey string
+
+	// padding is applied to every value before it's encrypted. Values are
+	// encrypted at their own length when it's nil.
+	padding *Padding
+
+	// fingerprint is the fingerprint of the key the keeper expects to
+	// find. Any other key is refused.
+	fingerprint string
+
+	// suppliedKey makes the keeper take a key supplied by the environment
+	// ahead of its key path.
+	suppliedKey bool
+
+	// keySize is the size in bits GenerateKeys is asked for.
+	keySize int
+
+	// recipients and teamSecret are only used by X25519. Values are
+	// encrypted to every recipient, and the team secret authenticates the
+	// config.
+	recipients []string
+	teamSecret string
+
+	// pkcs11Key is only used by PKCS11. It names the HSM key and holds the
+	// data key it wrapped.
+	pkcs11Key *PKCS11Key
+
+	// transitKey is only used by VaultTransit. It names the transit key
+	// and holds the data key it wrapped.
+	transitKey *VaultTransitKey
+
+	// providerKey is only used by KeyProvider. It names the key provider
+	// and holds the data key it wrapped.
+	providerKey *KeyProviderKey
+}
+
+// WithProjectID binds every value to the given project. Keepers with a
+// project ID also refuse values written before secrets were bound to their
+// names.
+func WithProjectID(id string) Option {
+	return func(o *options) {
+		o.projectID = id
+	}
+}
+
+// WithPassphrase sets where the passphrase for wrapped key files comes
+// from. Keepers default to CK_PASSPHRASE or a terminal prompt.
+func WithPassphrase(passphrase func() ([]byte, error)) Option {
+	return func(o *options) {
+		o.passphrase = passphrase
+	}
+}
+
+// WithSSHKey protects generated key files with the SSH key with the given
+// fingerprint instead of a passphrase. Keepers refuse key files protected
+// with any other SSH key.
+func WithSSHKey(fingerprint string) Option {
+	return func(o *options) {
+		o.sshKey = fingerprint
+	}
+}
+
+// WithPadding pads every value with the given policy before it's encrypted,
+// hiding its length. Padded values are marked as such, so they're decrypted
+// whatever the keeper's policy is.
+func WithPadding(p *Padding) Option {
+	return func(o *options) {
+		o.padding = p
+	}
+}
+
+// WithKeySize makes GenerateKeys fail unless the encryption type generates
+// keys of the given size in bits. Use ResizeEncryptionType to pick the type.
+func WithKeySize(bits int) Option {
+	return func(o *options) {
+		o.keySize = bits
+	}
+}
+
+// WithRecipients sets the public keys X25519 values are encrypted to.
+func WithRecipients(recipients []string) Option {
+	return func(o *options) {
+		o.recipients = recipients
+	}
+}
+
+// WithTeamSecret sets the sealed team secret written by NewTeamSecret.
+func WithTeamSecret(sealed string) Option {
+	return func(o *options) {
+		o.teamSecret = sealed
+	}
+}
+
+// NewProjectID returns a random identifier for a new project.
+func NewProjectID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
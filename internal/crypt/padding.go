@@ -0,0 +1,95 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxPaddingBlock keeps a typo in the block size from turning every value
+// into megabytes of padding.
+const maxPaddingBlock = 64 * 1024
+
+var (
+	ErrInvalidPadding = errors.New("invalid padding - expected a block size in bytes, 'pow2' or 'none'")
+	ErrBadPadding     = errors.New("value's padding is corrupted")
+)
+
+// Padding hides the length of values by padding the plaintext before it's
+// encrypted. Without it the length of every value in the config is the
+// length of the plaintext plus a constant. At most one field is set.
+type Padding struct {
+	// Block pads values to the next multiple of Block bytes.
+	Block int `json:"block,omitempty"`
+
+	// PowerOfTwo pads values to the next power of two.
+	PowerOfTwo bool `json:"power_of_two,omitempty"`
+}
+
+// ParsePadding parses a padding policy as given on the command line: a
+// block size in bytes, "pow2" for the next power of two, or "none", which
+// returns nil.
+func ParsePadding(s string) (*Padding, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return nil, nil
+	case "pow2", "power-of-two":
+		return &Padding{PowerOfTwo: true}, nil
+	}
+
+	block, err := strconv.Atoi(s)
+	if err != nil || block < 2 || block > maxPaddingBlock {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPadding, s)
+	}
+
+	return &Padding{Block: block}, nil
+}
+
+func (p *Padding) String() string {
+	if p == nil {
+		return "none"
+	}
+	if p.PowerOfTwo {
+		return "next power of two"
+	}
+
+	return fmt.Sprintf("%d-byte blocks", p.Block)
+}
+
+// size returns the padded size of a plaintext of n bytes. There's always
+// room for at least the 0x80 marker.
+func (p *Padding) size(n int) int {
+	if p.PowerOfTwo {
+		size := 1
+		for size <= n {
+			size <<= 1
+		}
+
+		return size
+	}
+
+	return (n/p.Block + 1) * p.Block
+}
+
+// pad appends a 0x80 byte and as many zero bytes as needed, as in ISO/IEC
+// 7816-4, so the padding can be stripped without knowing the policy.
+func (p *Padding) pad(plainText string) string {
+	padding := make([]byte, p.size(len(plainText))-len(plainText))
+	padding[0] = 0x80
+
+	return plainText + string(padding)
+}
+
+// unpad strips the padding added by pad.
+func unpad(padded string) (string, error) {
+	i := len(padded) - 1
+	for i >= 0 && padded[i] == 0 {
+		i--
+	}
+	if i < 0 || padded[i] != 0x80 {
+		return "", ErrBadPadding
+	}
+
+	return padded[:i], nil
+}
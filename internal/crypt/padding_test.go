@@ -0,0 +1,70 @@
+package crypt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePadding(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		value    string
+		expected *Padding
+		err      error
+	}{
+		{"none", nil, nil},
+		{"", nil, nil},
+		{"pow2", &Padding{PowerOfTwo: true}, nil},
+		{"32", &Padding{Block: 32}, nil},
+		{"1", nil, ErrInvalidPadding},
+		{"1000000", nil, ErrInvalidPadding},
+		{"huge", nil, ErrInvalidPadding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			result, err := ParsePadding(tt.value)
+			assert.Equal(tt.expected, result)
+			assert.ErrorIs(err, tt.err)
+		})
+	}
+}
+
+func TestPad(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name      string
+		padding   *Padding
+		plainText string
+		size      int
+	}{
+		{"Block, empty", &Padding{Block: 16}, "", 16},
+		{"Block, PIN", &Padding{Block: 16}, "1234", 16},
+		{"Block, full block", &Padding{Block: 16}, strings.Repeat("x", 16), 32},
+		{"Block, trailing zeros", &Padding{Block: 16}, "bar\x00\x00", 16},
+		{"Power of two, empty", &Padding{PowerOfTwo: true}, "", 1},
+		{"Power of two, PIN", &Padding{PowerOfTwo: true}, "1234", 8},
+		{"Power of two, token", &Padding{PowerOfTwo: true}, strings.Repeat("x", 40), 64},
+		{"Power of two, exact", &Padding{PowerOfTwo: true}, strings.Repeat("x", 32), 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			padded := tt.padding.pad(tt.plainText)
+			assert.Len(padded, tt.size)
+
+			plainText, err := unpad(padded)
+			assert.NoError(err)
+			assert.Equal(tt.plainText, plainText)
+		})
+	}
+
+	_, err := unpad("bar\x00")
+	assert.ErrorIs(err, ErrBadPadding)
+	_, err = unpad("")
+	assert.ErrorIs(err, ErrBadPadding)
+}
@@ -0,0 +1,28 @@
+// Package pkcs11 wraps data keys with a key that never leaves a PKCS#11
+// token, such as an HSM.
+package pkcs11
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrKeyNotFound   = errors.New("no secret key with that label on the token")
+	ErrAmbiguousKey  = errors.New("more than one secret key with that label on the token")
+	ErrModuleMissing = errors.New("failed to load the PKCS#11 module")
+	ErrUnsupported   = errors.New("this build of cryptkeeper has no PKCS#11 support - it needs cgo")
+)
+
+// Token names the key data keys are wrapped with: the module that drives
+// the token, the slot the token is in, and the label of an AES key on it
+// that's allowed to wrap and unwrap.
+type Token struct {
+	Module string
+	Slot   uint
+	Label  string
+}
+
+func (t Token) String() string {
+	return fmt.Sprintf("key %q in slot %d of %s", t.Label, t.Slot, t.Module)
+}
@@ -0,0 +1,150 @@
+//go:build cgo
+
+// Package softhsm sets up SoftHSM2 tokens for tests. Tests using it are
+// skipped when SoftHSM2 isn't installed.
+package softhsm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	p11 "github.com/miekg/pkcs11"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/pkcs11"
+)
+
+const (
+	// PIN is the user PIN of every token Setup creates.
+	PIN = "1234"
+
+	// KeyLabel is the label of the wrapping key Setup generates.
+	KeyLabel = "cryptkeeper-test"
+
+	soPIN = "123456"
+
+	// moduleEnvVar points at the SoftHSM2 module when it isn't in one of
+	// the usual places.
+	moduleEnvVar = "SOFTHSM2_MODULE"
+)
+
+// modulePaths are where distributions and Homebrew install the module.
+var modulePaths = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib/aarch64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib64/pkcs11/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+}
+
+var slotPattern = regexp.MustCompile(`reassigned to slot (\d+)`)
+
+// Setup initializes a fresh SoftHSM2 token in a temporary directory and
+// generates an AES wrapping key labelled KeyLabel on it. It skips the test
+// if SoftHSM2 isn't installed.
+func Setup(t testing.TB) pkcs11.Token {
+	t.Helper()
+
+	module := findModule()
+	if module == "" {
+		t.Skip("SoftHSM2 isn't installed - set " + moduleEnvVar + " if it's somewhere unusual")
+	}
+	util, err := exec.LookPath("softhsm2-util")
+	if err != nil {
+		t.Skip("softhsm2-util isn't installed")
+	}
+
+	dir := t.TempDir()
+	tokens := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokens, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := filepath.Join(dir, "softhsm2.conf")
+	err = os.WriteFile(conf, []byte(fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\nlog.level = ERROR\n", tokens)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	out, err := exec.Command(util, "--init-token", "--free", "--label", "cryptkeeper", "--pin", PIN, "--so-pin", soPIN).CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to initialize token: %v: %s", err, out)
+	}
+
+	match := slotPattern.FindSubmatch(out)
+	if match == nil {
+		t.Fatalf("failed to find the token's slot in: %s", out)
+	}
+	slot, err := strconv.ParseUint(string(match[1]), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := pkcs11.Token{Module: module, Slot: uint(slot), Label: KeyLabel}
+	if err := generateKey(token); err != nil {
+		t.Fatalf("failed to generate the wrapping key: %v", err)
+	}
+
+	return token
+}
+
+func findModule() string {
+	if module := os.Getenv(moduleEnvVar); module != "" {
+		return module
+	}
+
+	for _, path := range modulePaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// generateKey generates an AES key that can wrap and unwrap but never be
+// read, as an HSM key would be.
+func generateKey(token pkcs11.Token) error {
+	ctx := p11.New(token.Module)
+	if ctx == nil {
+		return pkcs11.ErrModuleMissing
+	}
+	defer ctx.Destroy()
+
+	if err := ctx.Initialize(); err != nil {
+		return err
+	}
+	defer ctx.Finalize()
+
+	session, err := ctx.OpenSession(token.Slot, p11.CKF_SERIAL_SESSION|p11.CKF_RW_SESSION)
+	if err != nil {
+		return err
+	}
+	defer ctx.CloseSession(session)
+
+	if err := ctx.Login(session, p11.CKU_USER, PIN); err != nil {
+		return err
+	}
+	defer ctx.Logout(session)
+
+	_, err = ctx.GenerateKey(session, []*p11.Mechanism{p11.NewMechanism(p11.CKM_AES_KEY_GEN, nil)}, []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, p11.CKO_SECRET_KEY),
+		p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_AES),
+		p11.NewAttribute(p11.CKA_VALUE_LEN, 32),
+		p11.NewAttribute(p11.CKA_LABEL, token.Label),
+		p11.NewAttribute(p11.CKA_TOKEN, true),
+		p11.NewAttribute(p11.CKA_PRIVATE, true),
+		p11.NewAttribute(p11.CKA_SENSITIVE, true),
+		p11.NewAttribute(p11.CKA_EXTRACTABLE, false),
+		p11.NewAttribute(p11.CKA_WRAP, true),
+		p11.NewAttribute(p11.CKA_UNWRAP, true),
+	})
+
+	return err
+}
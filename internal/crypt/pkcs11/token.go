@@ -0,0 +1,136 @@
+//go:build cgo
+
+package pkcs11
+
+import (
+	"errors"
+	"fmt"
+
+	p11 "github.com/miekg/pkcs11"
+)
+
+// wrapMechanism is AES key wrap (RFC 3394). Data keys are 32 bytes, so
+// they don't need the padded variant, which fewer tokens support.
+var wrapMechanism = []*p11.Mechanism{p11.NewMechanism(p11.CKM_AES_KEY_WRAP, nil)}
+
+// Wrap wraps dataKey with the token's key. The data key is only ever a
+// session object on the token, destroyed once it's wrapped.
+func Wrap(t Token, pin, dataKey []byte) ([]byte, error) {
+	var wrapped []byte
+	err := t.withKey(pin, func(ctx *p11.Ctx, session p11.SessionHandle, key p11.ObjectHandle) error {
+		obj, err := ctx.CreateObject(session, dataKeyTemplate(p11.NewAttribute(p11.CKA_VALUE, dataKey)))
+		if err != nil {
+			return fmt.Errorf("failed to load the data key into the token: %w", err)
+		}
+		defer ctx.DestroyObject(session, obj)
+
+		wrapped, err = ctx.WrapKey(session, wrapMechanism, key, obj)
+		if err != nil {
+			return fmt.Errorf("failed to wrap the data key with %s: %w", t, err)
+		}
+
+		return nil
+	})
+
+	return wrapped, err
+}
+
+// Unwrap unwraps a data key returned by Wrap. The plain data key is the
+// caller's to wipe.
+func Unwrap(t Token, pin, wrapped []byte) ([]byte, error) {
+	var dataKey []byte
+	err := t.withKey(pin, func(ctx *p11.Ctx, session p11.SessionHandle, key p11.ObjectHandle) error {
+		obj, err := ctx.UnwrapKey(session, wrapMechanism, key, wrapped, dataKeyTemplate())
+		if err != nil {
+			return fmt.Errorf("failed to unwrap the data key with %s: %w", t, err)
+		}
+		defer ctx.DestroyObject(session, obj)
+
+		attrs, err := ctx.GetAttributeValue(session, obj, []*p11.Attribute{p11.NewAttribute(p11.CKA_VALUE, nil)})
+		if err != nil {
+			return fmt.Errorf("failed to read the unwrapped data key: %w", err)
+		}
+		if len(attrs) != 1 {
+			return errors.New("failed to read the unwrapped data key")
+		}
+
+		dataKey = attrs[0].Value
+
+		return nil
+	})
+
+	return dataKey, err
+}
+
+// dataKeyTemplate describes a data key as a session object that can be
+// read back and wrapped.
+func dataKeyTemplate(extra ...*p11.Attribute) []*p11.Attribute {
+	return append([]*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, p11.CKO_SECRET_KEY),
+		p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_AES),
+		p11.NewAttribute(p11.CKA_TOKEN, false),
+		p11.NewAttribute(p11.CKA_SENSITIVE, false),
+		p11.NewAttribute(p11.CKA_EXTRACTABLE, true),
+	}, extra...)
+}
+
+// withKey loads the module, logs into the token with pin and calls fn with
+// the handle of the token's key.
+func (t Token) withKey(pin []byte, fn func(ctx *p11.Ctx, session p11.SessionHandle, key p11.ObjectHandle) error) error {
+	ctx := p11.New(t.Module)
+	if ctx == nil {
+		return fmt.Errorf("%w: %s", ErrModuleMissing, t.Module)
+	}
+	defer ctx.Destroy()
+
+	err := ctx.Initialize()
+	if err != nil && !errors.Is(err, p11.Error(p11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		return fmt.Errorf("failed to initialize %s: %w", t.Module, err)
+	}
+	defer ctx.Finalize()
+
+	session, err := ctx.OpenSession(t.Slot, p11.CKF_SERIAL_SESSION|p11.CKF_RW_SESSION)
+	if err != nil {
+		return fmt.Errorf("failed to open slot %d: %w", t.Slot, err)
+	}
+	defer ctx.CloseSession(session)
+
+	err = ctx.Login(session, p11.CKU_USER, string(pin))
+	if err != nil && !errors.Is(err, p11.Error(p11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return fmt.Errorf("failed to log into the token in slot %d: %w", t.Slot, err)
+	}
+	defer ctx.Logout(session)
+
+	key, err := t.findKey(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, session, key)
+}
+
+// findKey returns the handle of the only secret key with the token's label.
+func (t Token) findKey(ctx *p11.Ctx, session p11.SessionHandle) (p11.ObjectHandle, error) {
+	err := ctx.FindObjectsInit(session, []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, p11.CKO_SECRET_KEY),
+		p11.NewAttribute(p11.CKA_LABEL, t.Label),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer ctx.FindObjectsFinal(session)
+
+	keys, _, err := ctx.FindObjects(session, 2)
+	if err != nil {
+		return 0, err
+	}
+
+	switch len(keys) {
+	case 0:
+		return 0, fmt.Errorf("%w: %q in slot %d", ErrKeyNotFound, t.Label, t.Slot)
+	case 1:
+		return keys[0], nil
+	default:
+		return 0, fmt.Errorf("%w: %q in slot %d", ErrAmbiguousKey, t.Label, t.Slot)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package pkcs11
+
+// Wrap needs cgo to load the module.
+func Wrap(t Token, pin, dataKey []byte) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+// Unwrap needs cgo to load the module.
+func Unwrap(t Token, pin, wrapped []byte) ([]byte, error) {
+	return nil, ErrUnsupported
+}
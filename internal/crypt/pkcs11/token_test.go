@@ -0,0 +1,72 @@
+//go:build cgo
+
+package pkcs11_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/pkcs11"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/pkcs11/softhsm"
+)
+
+func TestWrapUnwrap(t *testing.T) {
+	assert := assert.New(t)
+	token := softhsm.Setup(t)
+
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	assert.NoError(err)
+
+	wrapped, err := pkcs11.Wrap(token, []byte(softhsm.PIN), dataKey)
+	assert.NoError(err)
+	assert.Len(wrapped, 40)
+	assert.False(bytes.Contains(wrapped, dataKey))
+
+	unwrapped, err := pkcs11.Unwrap(token, []byte(softhsm.PIN), wrapped)
+	assert.NoError(err)
+	assert.Equal(dataKey, unwrapped)
+}
+
+func TestUnwrapFailures(t *testing.T) {
+	token := softhsm.Setup(t)
+
+	dataKey := make([]byte, 32)
+	wrapped, err := pkcs11.Wrap(token, []byte(softhsm.PIN), dataKey)
+	assert.NoError(t, err)
+
+	tampered := bytes.Clone(wrapped)
+	tampered[0] ^= 1
+
+	otherLabel := token
+	otherLabel.Label = "missing"
+
+	missingModule := token
+	missingModule.Module = "/nonexistent/libpkcs11.so"
+
+	tests := []struct {
+		name    string
+		token   pkcs11.Token
+		pin     string
+		wrapped []byte
+		err     error
+	}{
+		{"Wrong PIN", token, "0000", wrapped, nil},
+		{"Tampered", token, softhsm.PIN, tampered, nil},
+		{"Unknown label", otherLabel, softhsm.PIN, wrapped, pkcs11.ErrKeyNotFound},
+		{"Missing module", missingModule, softhsm.PIN, wrapped, pkcs11.ErrModuleMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pkcs11.Unwrap(tt.token, []byte(tt.pin), tt.wrapped)
+			assert.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+//go:build go1.26
+
+package pqhybrid
+
+import (
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/mlkem/mlkemtest"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Encapsulation is randomized, so its known-answer tests need the
+// derandomized ML-KEM from crypto/mlkem/mlkemtest, which is new in Go 1.26.
+
+func TestKnownAnswerMLKEM768Encapsulate(t *testing.T) {
+	assert := assert.New(t)
+	v := readVectors(t)["mlkem768"]
+
+	dk, err := mlkem.NewDecapsulationKey768(unhex(t, v.Seed))
+	assert.NoError(err)
+
+	sharedKey, ciphertext, err := mlkemtest.Encapsulate768(dk.EncapsulationKey(), unhex(t, v.Message))
+	assert.NoError(err)
+	assert.Equal(v.Ciphertext, hex.EncodeToString(ciphertext))
+	assert.Equal(v.SharedKey, hex.EncodeToString(sharedKey))
+}
+
+func TestKnownAnswerEncapsulate(t *testing.T) {
+	assert := assert.New(t)
+	v := readVectors(t)["mlkem768x25519"]
+
+	keys, err := NewKeys(unhex(t, v.Seed))
+	assert.NoError(err)
+	pub := keys.Public()
+
+	sharedM, ciphertextM, err := mlkemtest.Encapsulate768(pub.encapsulationKey, unhex(t, v.Message))
+	assert.NoError(err)
+
+	ephemeral, err := ecdh.X25519().NewPrivateKey(unhex(t, v.Ephemeral))
+	assert.NoError(err)
+
+	sharedKey, ciphertext, err := encapsulate(pub, sharedM, ciphertextM, ephemeral)
+	assert.NoError(err)
+	assert.Equal(v.Ciphertext, hex.EncodeToString(ciphertext))
+	assert.Equal(v.SharedKey, hex.EncodeToString(sharedKey))
+}
@@ -0,0 +1,86 @@
+package pqhybrid
+
+import (
+	"crypto/mlkem"
+	"crypto/sha3"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// vector is a known-answer test vector from testdata/kat.json. Byte fields
+// are hex encoded.
+type vector struct {
+	Seed                 string `json:"seed"`
+	Message              string `json:"message"`
+	Ephemeral            string `json:"ephemeral"`
+	EncapsulationKeySHA3 string `json:"encapsulation_key_sha3"`
+	PublicKeySHA3        string `json:"public_key_sha3"`
+	Ciphertext           string `json:"ciphertext"`
+	SharedKey            string `json:"shared_key"`
+}
+
+func readVectors(t *testing.T) map[string]vector {
+	t.Helper()
+
+	b, err := os.ReadFile("testdata/kat.json")
+	assert.NoError(t, err)
+
+	vectors := make(map[string]vector)
+	assert.NoError(t, json.Unmarshal(b, &vectors))
+
+	return vectors
+}
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	assert.NoError(t, err)
+
+	return b
+}
+
+func sha3Hex(b []byte) string {
+	sum := sha3.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// TestKnownAnswerMLKEM768 checks the ML-KEM-768 half against the self-test
+// vector of Go's FIPS 140 module.
+func TestKnownAnswerMLKEM768(t *testing.T) {
+	assert := assert.New(t)
+	v := readVectors(t)["mlkem768"]
+
+	dk, err := mlkem.NewDecapsulationKey768(unhex(t, v.Seed))
+	assert.NoError(err)
+	assert.Equal(v.EncapsulationKeySHA3, sha3Hex(dk.EncapsulationKey().Bytes()))
+
+	sharedKey, err := dk.Decapsulate(unhex(t, v.Ciphertext))
+	assert.NoError(err)
+	assert.Equal(v.SharedKey, hex.EncodeToString(sharedKey))
+}
+
+func TestKnownAnswerDecapsulate(t *testing.T) {
+	assert := assert.New(t)
+	v := readVectors(t)["mlkem768x25519"]
+
+	keys, err := NewKeys(unhex(t, v.Seed))
+	assert.NoError(err)
+	assert.Equal(v.PublicKeySHA3, sha3Hex(keys.Public().Bytes()))
+
+	sharedKey, err := Decapsulate(keys, unhex(t, v.Ciphertext))
+	assert.NoError(err)
+	assert.Equal(v.SharedKey, hex.EncodeToString(sharedKey))
+
+	// A tampered ciphertext decapsulates to an unrelated key rather than
+	// failing, as ML-KEM rejects implicitly.
+	tampered := unhex(t, v.Ciphertext)
+	tampered[0] ^= 1
+	sharedKey, err = Decapsulate(keys, tampered)
+	assert.NoError(err)
+	assert.NotEqual(v.SharedKey, hex.EncodeToString(sharedKey))
+}
@@ -0,0 +1,154 @@
+package pqhybrid
+
+import (
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha3"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	// SeedSize is the size of the seed both private keys are expanded
+	// from.
+	SeedSize = 32
+
+	// PublicKeySize is the size of the ML-KEM-768 encapsulation key
+	// followed by the X25519 public key.
+	PublicKeySize = mlkem.EncapsulationKeySize768 + x25519KeySize
+
+	x25519KeySize = 32
+)
+
+var ErrInvalidSeed = errors.New("invalid ML-KEM-768+X25519 key seed")
+
+// Keys is an ML-KEM-768 and an X25519 key pair, both expanded from a
+// single seed. Only the seed is stored.
+type Keys struct {
+	Seed []byte `json:"seed"`
+
+	decapsulationKey *mlkem.DecapsulationKey768
+	x25519           *ecdh.PrivateKey
+}
+
+// PublicKey is the public half of Keys that values are encrypted to.
+type PublicKey struct {
+	encapsulationKey *mlkem.EncapsulationKey768
+	x25519           *ecdh.PublicKey
+}
+
+func GenerateKeys() (*Keys, error) {
+	seed := make([]byte, SeedSize)
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, err
+	}
+
+	return NewKeys(seed)
+}
+
+// NewKeys expands seed into both key pairs with SHAKE256: the first 64
+// bytes are the ML-KEM-768 seed and the last 32 are the X25519 private key.
+func NewKeys(seed []byte) (*Keys, error) {
+	k := &Keys{Seed: seed}
+	if err := k.expand(); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (k *Keys) expand() error {
+	if len(k.Seed) != SeedSize {
+		return ErrInvalidSeed
+	}
+
+	expanded := sha3.SumSHAKE256(k.Seed, mlkem.SeedSize+x25519KeySize)
+	defer securemem.Wipe(expanded)
+
+	dk, err := mlkem.NewDecapsulationKey768(expanded[:mlkem.SeedSize])
+	if err != nil {
+		return err
+	}
+
+	x, err := ecdh.X25519().NewPrivateKey(expanded[mlkem.SeedSize:])
+	if err != nil {
+		return err
+	}
+
+	k.decapsulationKey, k.x25519 = dk, x
+
+	return nil
+}
+
+// UnmarshalJSON restores the key pairs from the stored seed.
+func (k *Keys) UnmarshalJSON(data []byte) error {
+	type keys Keys
+
+	err := json.Unmarshal(data, (*keys)(k))
+	if err != nil {
+		return err
+	}
+
+	return k.expand()
+}
+
+// Public returns the public key values are encrypted to.
+func (k *Keys) Public() *PublicKey {
+	return &PublicKey{
+		encapsulationKey: k.decapsulationKey.EncapsulationKey(),
+		x25519:           k.x25519.PublicKey(),
+	}
+}
+
+// ID returns the short identifier recorded next to every value encrypted
+// with this key. It's derived from the public keys only.
+func (k *Keys) ID() string {
+	return keyid.New(k.Public().Bytes())
+}
+
+// Fingerprint identifies the key pair in full by its public key.
+func (k *Keys) Fingerprint() string {
+	return keyid.Fingerprint(k.Public().Bytes())
+}
+
+// LockMemory moves the seed into memory that won't be swapped to disk. The
+// expanded keys are held by crypto/mlkem and crypto/ecdh, which keep their
+// own copies.
+func (k *Keys) LockMemory() {
+	k.Seed = securemem.Lock(k.Seed)
+}
+
+// Secret returns the seed, which other keys can be derived from.
+func (k *Keys) Secret() []byte {
+	return k.Seed
+}
+
+// Bytes returns the ML-KEM-768 encapsulation key followed by the X25519
+// public key.
+func (p *PublicKey) Bytes() []byte {
+	return append(p.encapsulationKey.Bytes(), p.x25519.Bytes()...)
+}
+
+// ParsePublicKey parses the output of PublicKey.Bytes.
+func ParsePublicKey(b []byte) (*PublicKey, error) {
+	if len(b) != PublicKeySize {
+		return nil, errors.New("invalid ML-KEM-768+X25519 public key")
+	}
+
+	ek, err := mlkem.NewEncapsulationKey768(b[:mlkem.EncapsulationKeySize768])
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := ecdh.X25519().NewPublicKey(b[mlkem.EncapsulationKeySize768:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublicKey{encapsulationKey: ek, x25519: x}, nil
+}
@@ -0,0 +1,187 @@
+package pqhybrid
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha3"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+// CiphertextSize is the size of an encapsulated key: the ML-KEM-768
+// ciphertext followed by the ephemeral X25519 public key.
+const CiphertextSize = mlkem.CiphertextSize768 + x25519KeySize
+
+// label is X-Wing's domain separator, which ends the combiner input.
+const label = "\\.//^\\"
+
+var ErrCiphertextTooShort = errors.New("ciphertext too short")
+
+// MLKEM768X25519 encrypts every value under a fresh data key that's
+// encapsulated with both ML-KEM-768 and X25519. Recovering the data key
+// means breaking both, so values stay safe against a future quantum
+// computer as long as ML-KEM holds, and against flaws in the much younger
+// ML-KEM as long as X25519 holds.
+//
+// The payload is the encapsulated key followed by the AES-256-GCM nonce and
+// ciphertext.
+type MLKEM768X25519 struct{}
+
+// Encapsulate returns a fresh shared key and its encapsulation to pub.
+func Encapsulate(pub *PublicKey) (sharedKey, ciphertext []byte, err error) {
+	sharedM, ciphertextM := pub.encapsulationKey.Encapsulate()
+	defer securemem.Wipe(sharedM)
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encapsulate(pub, sharedM, ciphertextM, ephemeral)
+}
+
+// encapsulate finishes Encapsulate once the random parts are picked, so
+// known-answer tests can pick them.
+func encapsulate(pub *PublicKey, sharedM, ciphertextM []byte, ephemeral *ecdh.PrivateKey) (sharedKey, ciphertext []byte, err error) {
+	sharedX, err := ephemeral.ECDH(pub.x25519)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ciphertextX := ephemeral.PublicKey().Bytes()
+
+	sharedKey = combine(sharedM, sharedX, ciphertextX, pub.x25519.Bytes())
+	securemem.Wipe(sharedX)
+	ciphertext = make([]byte, 0, CiphertextSize)
+	ciphertext = append(ciphertext, ciphertextM...)
+	ciphertext = append(ciphertext, ciphertextX...)
+
+	return sharedKey, ciphertext, nil
+}
+
+// Decapsulate returns the shared key encapsulated in ciphertext.
+func Decapsulate(k *Keys, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) != CiphertextSize {
+		return nil, fmt.Errorf("invalid encapsulated key size %d", len(ciphertext))
+	}
+
+	ciphertextM, ciphertextX := ciphertext[:mlkem.CiphertextSize768], ciphertext[mlkem.CiphertextSize768:]
+
+	sharedM, err := k.decapsulationKey.Decapsulate(ciphertextM)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(sharedM)
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(ciphertextX)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedX, err := k.x25519.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(sharedX)
+
+	return combine(sharedM, sharedX, ciphertextX, k.x25519.PublicKey().Bytes()), nil
+}
+
+// combine hashes both shared secrets into one key the way X-Wing does. The
+// X25519 ciphertext and public key are included because X25519, unlike
+// ML-KEM, doesn't bind its shared secret to them.
+func combine(sharedM, sharedX, ciphertextX, publicX []byte) []byte {
+	h := sha3.New256()
+	h.Write(sharedM)
+	h.Write(sharedX)
+	h.Write(ciphertextX)
+	h.Write(publicX)
+	h.Write([]byte(label))
+
+	return h.Sum(nil)
+}
+
+// Encrypt encrypts plaintext to the given *PublicKey, authenticating
+// additionalData alongside it.
+func (m *MLKEM768X25519) Encrypt(plaintext string, key any, additionalData []byte) (string, error) {
+	pub, ok := key.(*PublicKey)
+	if !ok {
+		return "", errors.New("invalid encryption key")
+	}
+
+	sharedKey, encapsulated, err := Encapsulate(pub)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(sharedKey)
+	securemem.Wipe(sharedKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	out := append(encapsulated, nonce...)
+	out = aead.Seal(out, nonce, []byte(plaintext), additionalData)
+
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Decrypt decrypts a value with the given *Keys. additionalData must match
+// what was passed to Encrypt.
+func (m *MLKEM768X25519) Decrypt(cipherText string, key any, additionalData []byte) (string, error) {
+	k, ok := key.(*Keys)
+	if !ok {
+		return "", errors.New("invalid decryption key")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < CiphertextSize {
+		return "", ErrCiphertextTooShort
+	}
+
+	sharedKey, err := Decapsulate(k, raw[:CiphertextSize])
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(sharedKey)
+	securemem.Wipe(sharedKey)
+	if err != nil {
+		return "", err
+	}
+
+	rest := raw[CiphertextSize:]
+	if len(rest) < aead.NonceSize() {
+		return "", ErrCiphertextTooShort
+	}
+
+	b, err := authenc.Open(aead, rest[:aead.NonceSize()], rest[aead.NonceSize():], additionalData)
+	defer securemem.Wipe(b)
+
+	return string(b), err
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,98 @@
+package pqhybrid_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/pqhybrid"
+)
+
+func TestMLKEM768X25519(t *testing.T) {
+	assert := assert.New(t)
+	keys, err := pqhybrid.GenerateKeys()
+	assert.NoError(err)
+
+	m := &pqhybrid.MLKEM768X25519{}
+
+	// Table-driven tests
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"Normal text", "Hello, world!"},
+		{"Empty text", ""},
+		{"Special characters", "!@#$\n%^\t&*()"},
+		{"Long text", "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Vivamus lacinia odio vitae vestibulum."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cipherText, err := m.Encrypt(tt.plaintext, keys.Public(), nil)
+			assert.NoError(err)
+
+			decrypted, err := m.Decrypt(cipherText, keys, nil)
+			assert.NoError(err)
+			assert.Equal(tt.plaintext, decrypted)
+		})
+	}
+}
+
+func TestAdditionalData(t *testing.T) {
+	assert := assert.New(t)
+	m := &pqhybrid.MLKEM768X25519{}
+
+	keys, err := pqhybrid.GenerateKeys()
+	assert.NoError(err)
+
+	ciphertext, err := m.Encrypt("secret", keys.Public(), []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := m.Decrypt(ciphertext, keys, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = m.Decrypt(ciphertext, keys, []byte("BAR"))
+	assert.Error(err)
+}
+
+func TestDecryptWithWrongKey(t *testing.T) {
+	assert := assert.New(t)
+	m := &pqhybrid.MLKEM768X25519{}
+
+	alice, err := pqhybrid.GenerateKeys()
+	assert.NoError(err)
+	bob, err := pqhybrid.GenerateKeys()
+	assert.NoError(err)
+
+	ciphertext, err := m.Encrypt("secret", alice.Public(), nil)
+	assert.NoError(err)
+
+	_, err = m.Decrypt(ciphertext, bob, nil)
+	assert.Error(err)
+
+	_, err = m.Decrypt("c2hvcnQ=", alice, nil)
+	assert.ErrorIs(err, pqhybrid.ErrCiphertextTooShort)
+}
+
+func TestKeysJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	keys, err := pqhybrid.GenerateKeys()
+	assert.NoError(err)
+
+	b, err := json.Marshal(keys)
+	assert.NoError(err)
+
+	parsed := new(pqhybrid.Keys)
+	assert.NoError(json.Unmarshal(b, parsed))
+	assert.Equal(keys.ID(), parsed.ID())
+	assert.Equal(keys.Public().Bytes(), parsed.Public().Bytes())
+
+	pub, err := pqhybrid.ParsePublicKey(keys.Public().Bytes())
+	assert.NoError(err)
+	assert.Equal(keys.Public().Bytes(), pub.Bytes())
+
+	assert.ErrorIs(json.Unmarshal([]byte(`{"seed":"c2hvcnQ="}`), new(pqhybrid.Keys)), pqhybrid.ErrInvalidSeed)
+}
@@ -0,0 +1,313 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+
+	"github.com/sunny-b/cryptkeeper/internal/agent"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/age"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keywrap"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/sshkey"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+	"github.com/sunny-b/cryptkeeper/internal/passphrase"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+var (
+	ErrKeyProtected   = errors.New("key file is already protected")
+	ErrKeyUnprotected = errors.New("key file isn't protected with a passphrase or SSH key")
+	ErrExternalKey    = errors.New("key file is an SSH key managed outside cryptkeeper")
+	ErrWrongSSHKey    = errors.New("key file is protected with a different SSH key")
+)
+
+type unlockedKey struct {
+	plain      []byte
+	passphrase []byte
+}
+
+var (
+	// unlocked remembers key files that were already unwrapped by this
+	// process, keyed by a digest of the wrapped contents, so a command that
+	// loads the key several times only asks for the passphrase once.
+	unlocked   = make(map[[sha256.Size]byte]unlockedKey)
+	unlockedMu sync.Mutex
+)
+
+// IsProtected reports whether the key file at keyPath is wrapped with a
+// passphrase.
+func IsProtected(keyPath string) (bool, error) {
+	b, err := afero.ReadFile(fs, keyPath)
+	if err != nil {
+		return false, err
+	}
+
+	return keywrap.IsWrapped(b), nil
+}
+
+// SSHKeyFingerprint returns the fingerprint of the SSH key that protects the
+// key file at keyPath, or an empty string if it isn't protected with one.
+func SSHKeyFingerprint(keyPath string) (string, error) {
+	b, err := afero.ReadFile(fs, keyPath)
+	if err != nil {
+		return "", err
+	}
+	if !keywrap.IsWrapped(b) {
+		return "", nil
+	}
+
+	f, err := keywrap.Parse(b)
+	if err != nil {
+		return "", err
+	}
+	if f.Method != keywrap.MethodSSH || f.SSH == nil {
+		return "", nil
+	}
+
+	return f.SSH.Fingerprint, nil
+}
+
+// IsExternalKey reports whether the key file at keyPath is an SSH key used
+// as an age identity. cryptkeeper never rewrites, moves or deletes those.
+func IsExternalKey(keyPath string) (bool, error) {
+	b, err := afero.ReadFile(fs, keyPath)
+	if err != nil {
+		return false, err
+	}
+
+	return age.IsSSHKey(b), nil
+}
+
+// ProtectKey wraps the plain key file at keyPath with a passphrase.
+func ProtectKey(keyPath string, passphrase []byte) error {
+	b, err := afero.ReadFile(fs, keyPath)
+	if err != nil {
+		return err
+	}
+	if keywrap.IsWrapped(b) {
+		return ErrKeyProtected
+	}
+	if age.IsSSHKey(b) {
+		return ErrExternalKey
+	}
+
+	wrapped, err := wrapKeyFile(b, passphrase)
+	securemem.Wipe(b)
+	if err != nil {
+		return err
+	}
+
+	return fileutils.WriteFile(keyPath, wrapped, 0600)
+}
+
+// ProtectKeyWithSSH wraps the plain key file at keyPath with a key derived
+// from the SSH key with the given fingerprint, so it's unlocked by ssh-agent
+// or a key in ~/.ssh instead of a passphrase.
+func ProtectKeyWithSSH(keyPath, fingerprint string) error {
+	b, err := afero.ReadFile(fs, keyPath)
+	if err != nil {
+		return err
+	}
+	if keywrap.IsWrapped(b) {
+		return ErrKeyProtected
+	}
+	if age.IsSSHKey(b) {
+		return ErrExternalKey
+	}
+
+	wrapped, err := wrapKeyFileWithSSH(b, fingerprint, nil)
+	securemem.Wipe(b)
+	if err != nil {
+		return err
+	}
+
+	return fileutils.WriteFile(keyPath, wrapped, 0600)
+}
+
+// UnprotectKey replaces the wrapped key file at keyPath with its plain
+// contents.
+func UnprotectKey(keyPath string, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b, err := afero.ReadFile(fs, keyPath)
+	if err != nil {
+		return err
+	}
+	if !keywrap.IsWrapped(b) {
+		return ErrKeyUnprotected
+	}
+
+	plain, _, err := unlockKeyFile(b, keyPath, o.passphrase)
+	if err != nil {
+		return err
+	}
+	defer securemem.Wipe(plain)
+
+	return fileutils.WriteFile(keyPath, plain, 0600)
+}
+
+func wrapKeyFile(b, passphrase []byte) ([]byte, error) {
+	f, err := keywrap.WrapWithPassphrase(b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := f.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	remember(wrapped, b, passphrase)
+
+	return wrapped, nil
+}
+
+func wrapKeyFileWithSSH(b []byte, fingerprint string, getPassphrase func() ([]byte, error)) ([]byte, error) {
+	f, err := keywrap.WrapWithSSH(b, fingerprint, sshDeriver(fingerprint, getPassphrase))
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := f.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	remember(wrapped, b, nil)
+
+	return wrapped, nil
+}
+
+// sshDeriver derives wrapping keys with the SSH key with the given
+// fingerprint. getPassphrase is only asked when the SSH key is an encrypted
+// key file rather than a key in ssh-agent.
+func sshDeriver(fingerprint string, getPassphrase func() ([]byte, error)) keywrap.DeriveFunc {
+	return func(salt []byte) ([]byte, error) {
+		return sshkey.Derive(fingerprint, salt, func(path string) ([]byte, error) {
+			return readPassphrase(path, getPassphrase)
+		})
+	}
+}
+
+// UnlockInAgent unwraps the key file at keyPath and hands the plain key to
+// the running agent, so later commands don't need the passphrase.
+func UnlockInAgent(keyPath string, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !agent.Running() {
+		return agent.ErrNotRunning
+	}
+
+	b, err := afero.ReadFile(fs, keyPath)
+	if err != nil {
+		return err
+	}
+	if !keywrap.IsWrapped(b) {
+		return ErrKeyUnprotected
+	}
+
+	plain, _, err := unlockKeyFile(b, keyPath, o.passphrase)
+	if err != nil {
+		return err
+	}
+	defer securemem.Wipe(plain)
+
+	return agent.Add(agentKeyID(b), plain)
+}
+
+// agentKeyID identifies a wrapped key file in the agent. It's derived from
+// the file contents, so a rotated key is never confused with the old one.
+func agentKeyID(wrapped []byte) string {
+	sum := sha256.Sum256(wrapped)
+	return hex.EncodeToString(sum[:])
+}
+
+// unlockKeyFile unwraps a wrapped key file. It asks for the passphrase
+// unless this process already unlocked the same file or the agent holds it.
+// The passphrase is only returned when it was asked for. The plain key file
+// is the caller's to wipe.
+func unlockKeyFile(b []byte, keyPath string, getPassphrase func() ([]byte, error)) ([]byte, []byte, error) {
+	unlockedMu.Lock()
+	cached, ok := unlocked[sha256.Sum256(b)]
+	unlockedMu.Unlock()
+	if ok {
+		return bytes.Clone(cached.plain), cached.passphrase, nil
+	}
+
+	plain, err := agent.Get(agentKeyID(b))
+	if err == nil {
+		remember(b, plain, nil)
+		return plain, nil, nil
+	}
+	if !errors.Is(err, agent.ErrNotRunning) && !errors.Is(err, agent.ErrNotFound) {
+		logrus.WithError(err).Debug("failed to fetch key from agent")
+	}
+
+	f, err := keywrap.Parse(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse wrapped key file: %w", err)
+	}
+
+	if f.Method == keywrap.MethodSSH {
+		if f.SSH == nil {
+			return nil, nil, fmt.Errorf("%w: %q", keywrap.ErrUnknownMethod, f.Method)
+		}
+
+		plain, err = f.UnwrapWithSSH(sshDeriver(f.SSH.Fingerprint, getPassphrase))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unlock %s: %w", keyPath, err)
+		}
+
+		remember(b, plain, nil)
+
+		return plain, nil, nil
+	}
+
+	pass, err := readPassphrase(keyPath, getPassphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plain, err = f.UnwrapWithPassphrase(pass)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unlock %s: %w", keyPath, err)
+	}
+
+	return plain, remember(b, plain, pass).passphrase, nil
+}
+
+func readPassphrase(keyPath string, getPassphrase func() ([]byte, error)) ([]byte, error) {
+	if getPassphrase != nil {
+		return getPassphrase()
+	}
+
+	return passphrase.Read(fmt.Sprintf("Enter passphrase for %s: ", keyPath))
+}
+
+// remember caches locked copies of the plain key file and passphrase until
+// securemem.WipeAll wipes them.
+func remember(wrapped, plain, passphrase []byte) unlockedKey {
+	key := unlockedKey{
+		plain:      securemem.Lock(bytes.Clone(plain)),
+		passphrase: securemem.Lock(bytes.Clone(passphrase)),
+	}
+
+	unlockedMu.Lock()
+	defer unlockedMu.Unlock()
+
+	unlocked[sha256.Sum256(wrapped)] = key
+
+	return key
+}
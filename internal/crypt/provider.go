@@ -0,0 +1,147 @@
+package crypt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+)
+
+// trustInfo separates the IDs of trusted key providers from other hashes.
+const trustInfo = "cryptkeeper trusted key provider v1"
+
+var (
+	ErrNoKeyProvider        = errors.New("keyprovider needs the name of the key provider")
+	ErrUntrustedKeyProvider = errors.New("the config runs a key provider, or sends it options, you haven't trusted on this machine (review the key_provider block of the config and run 'cryptkeeper trust')")
+)
+
+// KeyProviderKey names the key provider that wraps a project's data key,
+// the options it's sent, and holds the data key it wrapped. The provider is
+// the cryptkeeper-keyprovider-<name> executable on $PATH. Values are
+// encrypted locally with AES-256-GCM under the data key.
+type KeyProviderKey struct {
+	Name       string            `json:"name"`
+	Options    map[string]string `json:"options,omitempty"`
+	WrappedKey string            `json:"wrapped_key,omitempty"`
+}
+
+// WithKeyProviderKey sets the key provider and wrapped data key KeyProvider
+// keepers use.
+func WithKeyProviderKey(key *KeyProviderKey) Option {
+	return func(o *options) {
+		o.providerKey = key
+	}
+}
+
+// NewKeyProviderKey generates a data key and has the key provider named by
+// key wrap it, replacing its wrapped key. The provider is trusted with the
+// key's options from then on.
+func NewKeyProviderKey(key *KeyProviderKey) error {
+	w, err := key.wrapper()
+	if err != nil {
+		return err
+	}
+
+	key.WrappedKey, err = newDataKey(w)
+	if err != nil {
+		return err
+	}
+
+	return key.Trust()
+}
+
+// trustPath returns the record that the user trusts the key provider with
+// the key's options. Like sealed markers, it's kept outside the project,
+// where whoever can write the config can't add it.
+func (key *KeyProviderKey) trustPath() (string, []byte, error) {
+	b, err := json.Marshal(KeyProviderKey{Name: key.Name, Options: key.Options})
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := dataDir()
+	if err != nil {
+		return "", nil, err
+	}
+
+	id := sha256.Sum256(append([]byte(trustInfo+"\n"), b...))
+
+	return filepath.Join(dir, "trusted", hex.EncodeToString(id[:])), b, nil
+}
+
+// Trusted reports whether the user trusts the key provider with the key's
+// options. Options come from the project's config, so a provider is never
+// run with options the user hasn't reviewed.
+func (key *KeyProviderKey) Trusted() (bool, error) {
+	path, _, err := key.trustPath()
+	if err != nil {
+		return false, err
+	}
+
+	return fileutils.FileExists(path), nil
+}
+
+// Trust records that the user trusts the key provider with the key's
+// options.
+func (key *KeyProviderKey) Trust() error {
+	path, b, err := key.trustPath()
+	if err != nil {
+		return err
+	}
+
+	err = fs.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return fileutils.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// providerWrapper has a key provider wrap data keys. Wrapped keys are
+// whatever the provider returns.
+type providerWrapper struct {
+	keyprovider.Provider
+}
+
+// providerDataKey returns the wrapper for the keeper's key provider and the
+// data key it wrapped. Key providers are only run with options the user
+// trusts.
+func (k *Keeper) providerDataKey() (keyWrapper, string, error) {
+	w, err := k.providerKey.wrapper()
+	if err != nil {
+		return nil, "", err
+	}
+
+	trusted, err := k.providerKey.Trusted()
+	if err != nil {
+		return nil, "", err
+	}
+	if !trusted {
+		return nil, "", fmt.Errorf("%s: %w", w, ErrUntrustedKeyProvider)
+	}
+
+	return w, k.providerKey.WrappedKey, nil
+}
+
+// wrapper returns the wrapper for the key provider.
+func (key *KeyProviderKey) wrapper() (providerWrapper, error) {
+	if key == nil || key.Name == "" {
+		return providerWrapper{}, ErrNoKeyProvider
+	}
+
+	return providerWrapper{keyprovider.Provider{Name: key.Name, Options: maps.Clone(key.Options)}}, nil
+}
+
+func (w providerWrapper) wrap(dataKey []byte) (string, error) {
+	return w.Wrap(dataKey)
+}
+
+func (w providerWrapper) unwrap(wrapped string) ([]byte, error) {
+	return w.Unwrap(wrapped)
+}
@@ -0,0 +1,114 @@
+package crypt_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider/pass"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyprovider/providertest"
+)
+
+// TestMain runs the test binary as the pass key provider when cryptkeeper
+// calls it.
+func TestMain(m *testing.M) {
+	providertest.Main(m, pass.Name, pass.Provider{})
+}
+
+func TestKeeperKeyProvider(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh isn't installed")
+	}
+
+	assert := assert.New(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv(pass.CommandEnvVar, "echo correct horse")
+
+	providerKey := &crypt.KeyProviderKey{Name: pass.Name}
+	assert.NoError(crypt.NewKeyProviderKey(providerKey))
+	assert.True(strings.HasPrefix(providerKey.WrappedKey, "pass:v1:"))
+
+	keeper, err := crypt.NewKeeper(crypt.KeyProvider, "", crypt.WithKeyProviderKey(providerKey), crypt.WithProjectID("project"))
+	assert.NoError(err)
+	assert.Contains(keeper.KeySource(), pass.Name)
+
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(cipher, "ck:v2:keyprovider:"))
+
+	reloaded, err := crypt.NewKeeper(crypt.KeyProvider, "", crypt.WithKeyProviderKey(providerKey), crypt.WithProjectID("project"))
+	assert.NoError(err)
+
+	plainText, err := reloaded.Decrypt("FOO", cipher)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+
+	_, err = keeper.PlainKeyFile()
+	assert.ErrorIs(err, crypt.ErrNoKeyFile)
+	assert.ErrorIs(crypt.GenerateKeys(crypt.KeyProvider, filepath.Join(t.TempDir(), ".ckkey")), crypt.ErrNoKeyFile)
+
+	// Key providers are asked every time, so they can still refuse.
+	t.Setenv(pass.CommandEnvVar, "echo battery staple")
+	_, err = crypt.NewKeeper(crypt.KeyProvider, "", crypt.WithKeyProviderKey(providerKey), crypt.WithProjectID("project"))
+	assert.Error(err)
+	t.Setenv(pass.CommandEnvVar, "echo correct horse")
+
+	rewrapped := *providerKey
+	assert.NoError(crypt.NewKeyProviderKey(&rewrapped))
+	rotated, err := crypt.NewKeeper(crypt.KeyProvider, "", crypt.WithKeyProviderKey(&rewrapped), crypt.WithProjectID("project"))
+	assert.NoError(err)
+
+	_, err = rotated.Decrypt("FOO", cipher)
+	assert.ErrorIs(err, crypt.ErrKeyNotFound)
+}
+
+func TestKeeperKeyProviderFailures(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh isn't installed")
+	}
+
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv(pass.CommandEnvVar, "echo failures")
+
+	providerKey := &crypt.KeyProviderKey{Name: pass.Name}
+	assert.NoError(t, crypt.NewKeyProviderKey(providerKey))
+
+	unknown := &crypt.KeyProviderKey{Name: "unknown"}
+	assert.NoError(t, unknown.Trust())
+
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, k *crypt.KeyProviderKey)
+		err    error
+	}{
+		{"No name", func(t *testing.T, k *crypt.KeyProviderKey) { k.Name = "" }, crypt.ErrNoKeyProvider},
+		{"Unknown provider", func(t *testing.T, k *crypt.KeyProviderKey) { k.Name = "unknown" }, keyprovider.ErrNotFound},
+		{"Untrusted provider", func(t *testing.T, k *crypt.KeyProviderKey) { k.Name = "untrusted" }, crypt.ErrUntrustedKeyProvider},
+		{"Untrusted options", func(t *testing.T, k *crypt.KeyProviderKey) {
+			k.Options = map[string]string{"entry": "cryptkeeper/other"}
+		}, crypt.ErrUntrustedKeyProvider},
+		{"Wrong secret", func(t *testing.T, k *crypt.KeyProviderKey) { t.Setenv(pass.CommandEnvVar, "echo wrong") }, nil},
+		{"No wrapped key", func(t *testing.T, k *crypt.KeyProviderKey) { k.WrappedKey = "" }, nil},
+		{"Tampered", func(t *testing.T, k *crypt.KeyProviderKey) {
+			k.WrappedKey = strings.Replace(k.WrappedKey, "pass:v1:", "pass:v1:A", 1)
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := *providerKey
+			tt.tamper(t, &k)
+
+			_, err := crypt.NewKeeper(crypt.KeyProvider, "", crypt.WithKeyProviderKey(&k))
+			assert.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
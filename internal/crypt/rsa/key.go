@@ -6,6 +6,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
 )
 
 //nolint:musttag
@@ -13,6 +15,22 @@ type Keys struct {
 	Private *rsa.PrivateKey
 }
 
+// ID returns the short identifier recorded next to every value encrypted
+// with this key. It's derived from the public key only.
+func (k *Keys) ID() string {
+	return keyid.New(x509.MarshalPKCS1PublicKey(&k.Private.PublicKey))
+}
+
+// Fingerprint identifies the key pair in full by its public key.
+func (k *Keys) Fingerprint() string {
+	return keyid.Fingerprint(x509.MarshalPKCS1PublicKey(&k.Private.PublicKey))
+}
+
+// Secret returns the private exponent, which other keys can be derived from.
+func (k *Keys) Secret() []byte {
+	return k.Private.D.Bytes()
+}
+
 func (k *Keys) MarshalJSON() ([]byte, error) {
 	privateKeyBytes := x509.MarshalPKCS1PrivateKey(k.Private)
 	privateKeyPem := pem.EncodeToMemory(
@@ -23,7 +23,7 @@ func TestCustomJSONMarshalingAndUnmarshalingRSA(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			keys, err := rsa.GenerateKeys()
+			keys, err := rsa.GenerateKeys(tt.keyLength)
 			assert.NoError(err)
 
 			jsonData, err := json.Marshal(keys)
@@ -0,0 +1,149 @@
+package rsa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+// dataKeySize is the size of the AES-256 key generated for every value.
+const dataKeySize = 32
+
+var ErrInvalidCiphertext = errors.New("invalid rsa ciphertext")
+
+// RSA is hybrid RSA encryption for keys of any size. Every value is
+// encrypted with a random AES-256-GCM data key, and the data key is wrapped
+// with RSA-OAEP, so values aren't limited by the OAEP size limit:
+//
+//	base64(wrapped data key || nonce || ciphertext)
+//
+// The wrapped data key is exactly as long as the modulus. Values written
+// before hybrid encryption are RSA-OAEP over the plaintext and are exactly
+// that long in total, which is how Decrypt tells them apart.
+type RSA struct{}
+
+func GenerateKeys(bits int) (*Keys, error) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keys{key}, nil
+}
+
+// Encrypt encrypts plaintext with a fresh data key. additionalData is used
+// as the OAEP label and authenticated by GCM, so decryption fails unless the
+// same additionalData is given.
+func (r *RSA) Encrypt(plaintext string, key any, additionalData []byte) (string, error) {
+	keys, ok := key.(*Keys)
+	if !ok {
+		return "", errors.New("invalid encryption key")
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(dataKey)
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &keys.Private.PublicKey, dataKey, additionalData)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	out := append(wrappedKey, nonce...)
+	out = aead.Seal(out, nonce, []byte(plaintext), additionalData)
+
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+func (r *RSA) Decrypt(ciphertext string, key any, additionalData []byte) (string, error) {
+	keys, ok := key.(*Keys)
+	if !ok {
+		return "", errors.New("invalid decryption key")
+	}
+
+	rawCipherText, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	size := keys.Private.Size()
+	if len(rawCipherText) == size {
+		return decryptLegacy(rawCipherText, keys, additionalData)
+	}
+	if len(rawCipherText) < size {
+		return "", ErrInvalidCiphertext
+	}
+
+	// The additional data is the OAEP label, so a value moved to another
+	// secret or project fails here rather than when it's opened.
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, keys.Private, rawCipherText[:size], additionalData)
+	if errors.Is(err, rsa.ErrDecryption) {
+		return "", authenc.ErrOpen
+	}
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(dataKey)
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := rawCipherText[size:]
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	plaintext, err := authenc.Open(aead, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(plaintext)
+
+	return string(plaintext), nil
+}
+
+func newAEAD(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// decryptLegacy decrypts values written before hybrid encryption, when the
+// plaintext itself was encrypted with RSA-OAEP.
+func decryptLegacy(rawCipherText []byte, keys *Keys, additionalData []byte) (string, error) {
+	plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, keys.Private, rawCipherText, additionalData)
+	if errors.Is(err, rsa.ErrDecryption) {
+		return "", authenc.ErrOpen
+	}
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(plaintext)
+
+	return string(plaintext), nil
+}
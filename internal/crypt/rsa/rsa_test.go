@@ -0,0 +1,102 @@
+package rsa_test
+
+import (
+	"crypto/rand"
+	stdrsa "crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/rsa"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	assert := assert.New(t)
+	r := &rsa.RSA{}
+
+	keys, err := rsa.GenerateKeys(2048)
+	assert.NoError(err)
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"Normal text", "Hello, world!"},
+		{"Empty text", ""},
+		{"Special characters", "!@#$\n%^\t&*()"},
+		{"Long text", "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Vivamus lacinia odio vitae vestibulum."},
+		{"Past the OAEP limit", strings.Repeat("-----BEGIN CERTIFICATE-----\n", 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ciphertext, err := r.Encrypt(tt.plaintext, keys, nil)
+			assert.NoError(err)
+			assert.NotEqual(tt.plaintext, ciphertext)
+
+			decrypted, err := r.Decrypt(ciphertext, keys, nil)
+			assert.NoError(err)
+			assert.Equal(tt.plaintext, string(decrypted))
+		})
+	}
+}
+
+func TestAdditionalData(t *testing.T) {
+	assert := assert.New(t)
+	r := &rsa.RSA{}
+
+	keys, err := rsa.GenerateKeys(2048)
+	assert.NoError(err)
+
+	ciphertext, err := r.Encrypt("secret", keys, []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := r.Decrypt(ciphertext, keys, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = r.Decrypt(ciphertext, keys, []byte("BAR"))
+	assert.Error(err)
+
+	_, err = r.Decrypt(ciphertext, keys, nil)
+	assert.Error(err)
+}
+
+func TestKeySizes(t *testing.T) {
+	assert := assert.New(t)
+	r := &rsa.RSA{}
+
+	keys, err := rsa.GenerateKeys(4096)
+	assert.NoError(err)
+
+	plaintext := strings.Repeat("x", 4096)
+
+	ciphertext, err := r.Encrypt(plaintext, keys, nil)
+	assert.NoError(err)
+
+	decrypted, err := r.Decrypt(ciphertext, keys, nil)
+	assert.NoError(err)
+	assert.Equal(plaintext, decrypted)
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	assert := assert.New(t)
+
+	keys, err := rsa.GenerateKeys(2048)
+	assert.NoError(err)
+
+	// Before hybrid encryption the plaintext itself was encrypted with OAEP.
+	raw, err := stdrsa.EncryptOAEP(sha256.New(), rand.Reader, &keys.Private.PublicKey, []byte("secret"), []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := new(rsa.RSA).Decrypt(base64.StdEncoding.EncodeToString(raw), keys, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = new(rsa.RSA).Decrypt(base64.StdEncoding.EncodeToString(raw[:100]), keys, []byte("FOO"))
+	assert.ErrorIs(err, rsa.ErrInvalidCiphertext)
+}
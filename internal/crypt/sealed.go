@@ -0,0 +1,69 @@
+package crypt
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+)
+
+// sealedInfo separates the ID of a sealed marker from the MAC of any config.
+const sealedInfo = "cryptkeeper sealed config v1"
+
+// sealedPath returns the marker recording that configs authenticated by the
+// keeper's key are sealed. Its name is derived from the MAC key, so it's
+// kept outside the project, where whoever can write the config can't remove
+// it, and only the key's holders can tell which project it belongs to.
+func (k *Keeper) sealedPath() (string, error) {
+	id, err := k.MAC([]byte(sealedInfo))
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "sealed", hex.EncodeToString(id)), nil
+}
+
+// Sealed reports whether a config authenticated by the keeper's key has
+// been sealed or verified on this machine. Its MAC must not go missing once
+// it has.
+func (k *Keeper) Sealed() (bool, error) {
+	path, err := k.sealedPath()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = fs.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// MarkSealed records that a config authenticated by the keeper's key has
+// been sealed.
+func (k *Keeper) MarkSealed() error {
+	sealed, err := k.Sealed()
+	if err != nil || sealed {
+		return err
+	}
+
+	path, err := k.sealedPath()
+	if err != nil {
+		return err
+	}
+
+	err = fs.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return fileutils.WriteFile(path, nil, 0600)
+}
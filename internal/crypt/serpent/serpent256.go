@@ -9,6 +9,10 @@ import (
 	"io"
 
 	"github.com/aead/serpent"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
 )
 
 type EncryptionKey struct {
@@ -19,6 +23,28 @@ type Serpent256 struct {
 	aead cipher.AEAD
 }
 
+// ID returns the short identifier recorded next to every value encrypted
+// with this key.
+func (e *EncryptionKey) ID() string {
+	return keyid.New(e.Key)
+}
+
+// Fingerprint identifies the key in full, so two key files can be compared
+// without looking at the key itself.
+func (e *EncryptionKey) Fingerprint() string {
+	return keyid.Fingerprint(e.Key)
+}
+
+// Secret returns the raw key, which other keys can be derived from.
+func (e *EncryptionKey) Secret() []byte {
+	return e.Key
+}
+
+// LockMemory moves the key into memory that won't be swapped to disk.
+func (e *EncryptionKey) LockMemory() {
+	e.Key = securemem.Lock(e.Key)
+}
+
 func GenerateKeys() (*EncryptionKey, error) {
 	key := make([]byte, 32)
 	if _, err := io.ReadFull(rand.Reader, key); err != nil {
@@ -28,7 +54,7 @@ func GenerateKeys() (*EncryptionKey, error) {
 	return &EncryptionKey{key}, nil
 }
 
-func (s *Serpent256) Encrypt(plainText string, key any) (string, error) {
+func (s *Serpent256) Encrypt(plainText string, key any, additionalData []byte) (string, error) {
 	e, ok := key.(*EncryptionKey)
 	if !ok {
 		return "", errors.New("invalid encryption key")
@@ -51,12 +77,12 @@ func (s *Serpent256) Encrypt(plainText string, key any) (string, error) {
 		return "", err
 	}
 
-	ciphertext := s.aead.Seal(nonce, nonce, []byte(plainText), nil)
+	ciphertext := s.aead.Seal(nonce, nonce, []byte(plainText), additionalData)
 
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-func (s *Serpent256) Decrypt(cipherText string, key any) (string, error) {
+func (s *Serpent256) Decrypt(cipherText string, key any, additionalData []byte) (string, error) {
 	e, ok := key.(*EncryptionKey)
 	if !ok {
 		return "", errors.New("invalid encryption key")
@@ -86,7 +112,8 @@ func (s *Serpent256) Decrypt(cipherText string, key any) (string, error) {
 
 	nonce, cipher := rawCiphertext[:nonceSize], rawCiphertext[nonceSize:]
 
-	b, err := s.aead.Open(nil, nonce, cipher, nil)
+	b, err := authenc.Open(s.aead, nonce, cipher, additionalData)
+	defer securemem.Wipe(b)
 
 	return string(b), err
 }
@@ -27,13 +27,34 @@ func TestEncryptDecrypt(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ciphertext, err := r.Encrypt(tt.plaintext, keys)
+			ciphertext, err := r.Encrypt(tt.plaintext, keys, nil)
 			assert.NoError(err)
 			assert.NotEqual(tt.plaintext, ciphertext)
 
-			decrypted, err := r.Decrypt(ciphertext, keys)
+			decrypted, err := r.Decrypt(ciphertext, keys, nil)
 			assert.NoError(err)
 			assert.Equal(tt.plaintext, string(decrypted))
 		})
 	}
 }
+
+func TestAdditionalData(t *testing.T) {
+	assert := assert.New(t)
+	r := &serpent.Serpent256{}
+
+	keys, err := serpent.GenerateKeys()
+	assert.NoError(err)
+
+	ciphertext, err := r.Encrypt("secret", keys, []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := r.Decrypt(ciphertext, keys, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = r.Decrypt(ciphertext, keys, []byte("BAR"))
+	assert.Error(err)
+
+	_, err = r.Decrypt(ciphertext, keys, nil)
+	assert.Error(err)
+}
@@ -0,0 +1,141 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8), the field
+// AES uses. Every byte of the secret is shared with its own random
+// polynomial, so shares are one byte longer than the secret: the first byte
+// is the share's x coordinate.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+// MaxShares is the number of distinct non-zero x coordinates.
+const MaxShares = 255
+
+var (
+	ErrInvalidThreshold = errors.New("threshold must be at least 2 and no more than the number of shares")
+	ErrEmptySecret      = errors.New("secret is empty")
+	ErrTooFewShares     = errors.New("at least two shares are needed")
+	ErrMalformedShares  = errors.New("shares have different lengths or repeat an x coordinate")
+)
+
+// Split splits secret into n shares, any threshold of which recover it.
+func Split(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 2 || threshold > n || n > MaxShares {
+		return nil, fmt.Errorf("%w (got %d of %d, at most %d shares)", ErrInvalidThreshold, threshold, n, MaxShares)
+	}
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	// coefficients[0] is the secret byte, the rest are random.
+	coefficients := make([]byte, threshold)
+	defer securemem.Wipe(coefficients)
+
+	for j, b := range secret {
+		coefficients[0] = b
+		if _, err := io.ReadFull(rand.Reader, coefficients[1:]); err != nil {
+			return nil, err
+		}
+
+		for _, share := range shares {
+			share[j+1] = evaluate(coefficients, share[0])
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine recovers the secret from shares made by Split. Given fewer shares
+// than the threshold it returns garbage rather than an error, so callers
+// need their own way to check the result.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrTooFewShares
+	}
+
+	size := len(shares[0])
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) != size || size < 2 || share[0] == 0 || seen[share[0]] {
+			return nil, ErrMalformedShares
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, size-1)
+	for j := range secret {
+		secret[j] = interpolate(shares, j+1)
+	}
+
+	return secret, nil
+}
+
+// evaluate returns the polynomial with the given coefficients at x, using
+// Horner's method.
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+
+	return y
+}
+
+// interpolate returns the value at x = 0 of the polynomial through the
+// shares' points for byte j, with Lagrange interpolation.
+func interpolate(shares [][]byte, j int) byte {
+	var y byte
+	for i, si := range shares {
+		// The basis polynomial for point i at 0 is the product of
+		// x_m / (x_m - x_i) over every other point m. Subtraction is XOR.
+		basis := byte(1)
+		for m, sm := range shares {
+			if m == i {
+				continue
+			}
+
+			basis = mul(basis, mul(sm[0], inverse(sm[0]^si[0])))
+		}
+
+		y ^= mul(si[j], basis)
+	}
+
+	return y
+}
+
+// mul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x + 1 without
+// branching on secret data.
+func mul(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= a & -(b & 1)
+		a = a<<1 ^ 0x1b&-(a>>7)
+		b >>= 1
+	}
+
+	return p
+}
+
+// inverse returns a^254, which is a's multiplicative inverse for a != 0.
+func inverse(a byte) byte {
+	// a^254 = a^2 * a^4 * ... * a^128.
+	result := byte(1)
+	square := a
+	for i := 0; i < 7; i++ {
+		square = mul(square, square)
+		result = mul(result, square)
+	}
+
+	return result
+}
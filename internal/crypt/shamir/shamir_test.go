@@ -0,0 +1,91 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestField(t *testing.T) {
+	assert := assert.New(t)
+
+	// The example from FIPS 197, section 4.2.
+	assert.Equal(byte(0xc1), mul(0x57, 0x83))
+	// {53} and {ca} are each other's inverse, as in the AES S-box.
+	assert.Equal(byte(0x01), mul(0x53, 0xca))
+	assert.Equal(byte(0xca), inverse(0x53))
+
+	for a := 1; a < 256; a++ {
+		assert.Equal(byte(1), mul(byte(a), inverse(byte(a))), "inverse of %#x", a)
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	assert := assert.New(t)
+	secret := []byte(`{"key":"c2VjcmV0IGtleSBtYXRlcmlhbA=="}`)
+
+	tests := []struct {
+		name      string
+		n         int
+		threshold int
+		use       []int
+	}{
+		{"2 of 2", 2, 2, []int{0, 1}},
+		{"3 of 5, first shares", 5, 3, []int{0, 1, 2}},
+		{"3 of 5, last shares", 5, 3, []int{4, 3, 2}},
+		{"3 of 5, all shares", 5, 3, []int{0, 1, 2, 3, 4}},
+		{"5 of 5", 5, 5, []int{3, 0, 4, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := Split(secret, tt.n, tt.threshold)
+			assert.NoError(err)
+			assert.Len(shares, tt.n)
+
+			var picked [][]byte
+			for _, i := range tt.use {
+				picked = append(picked, shares[i])
+			}
+
+			combined, err := Combine(picked)
+			assert.NoError(err)
+			assert.Equal(secret, combined)
+
+			// One share short of the threshold doesn't give the secret.
+			if tt.threshold > 2 {
+				combined, err = Combine(picked[:tt.threshold-1])
+				assert.NoError(err)
+				assert.False(bytes.Equal(secret, combined))
+			}
+		})
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Split([]byte("secret"), 3, 1)
+	assert.ErrorIs(err, ErrInvalidThreshold)
+	_, err = Split([]byte("secret"), 3, 4)
+	assert.ErrorIs(err, ErrInvalidThreshold)
+	_, err = Split([]byte("secret"), 256, 2)
+	assert.ErrorIs(err, ErrInvalidThreshold)
+	_, err = Split(nil, 3, 2)
+	assert.ErrorIs(err, ErrEmptySecret)
+}
+
+func TestCombineInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	shares, err := Split([]byte("secret"), 3, 2)
+	assert.NoError(err)
+
+	_, err = Combine(shares[:1])
+	assert.ErrorIs(err, ErrTooFewShares)
+	_, err = Combine([][]byte{shares[0], shares[0]})
+	assert.ErrorIs(err, ErrMalformedShares)
+	_, err = Combine([][]byte{shares[0], shares[1][:3]})
+	assert.ErrorIs(err, ErrMalformedShares)
+}
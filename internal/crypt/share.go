@@ -0,0 +1,205 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/shamir"
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	sharePrefix    = "ck-share"
+	shareVersion   = "v1"
+	shareSeparator = ":"
+	checksumLength = 8
+)
+
+var (
+	ErrMalformedShare = errors.New("malformed key share")
+	ErrShareChecksum  = errors.New("key share checksum doesn't match - it was mistyped or damaged")
+	ErrMixedShares    = errors.New("key shares belong to different keys or splits")
+	ErrTooFewShares   = errors.New("not enough key shares")
+)
+
+// share is one of the printable shares written by SplitKey:
+//
+//	ck-share:v1:<algorithm>:<fingerprint>:<threshold>:<payload>:<checksum>
+//
+// The payload is a Shamir share of the plain key file, and the checksum is
+// the start of the SHA-256 of everything before it. The fingerprint is the
+// one 'key info' and the config show.
+type share struct {
+	Algorithm   EncryptionType
+	Fingerprint string
+	Threshold   int
+	Payload     []byte
+}
+
+func (s *share) String() string {
+	body := strings.Join([]string{
+		sharePrefix,
+		shareVersion,
+		string(s.Algorithm),
+		s.Fingerprint,
+		strconv.Itoa(s.Threshold),
+		base64.RawURLEncoding.EncodeToString(s.Payload),
+	}, shareSeparator)
+
+	return body + shareSeparator + shareChecksum(body)
+}
+
+func shareChecksum(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])[:checksumLength]
+}
+
+func parseShare(value string) (*share, error) {
+	value = strings.TrimSpace(value)
+
+	i := strings.LastIndex(value, shareSeparator)
+	if !strings.HasPrefix(value, sharePrefix+shareSeparator) || i < 0 {
+		return nil, fmt.Errorf("%w: expected %s:%s:...", ErrMalformedShare, sharePrefix, shareVersion)
+	}
+
+	body, checksum := value[:i], value[i+1:]
+	if checksum != shareChecksum(body) {
+		return nil, ErrShareChecksum
+	}
+
+	parts := strings.Split(body, shareSeparator)
+	if len(parts) == 7 {
+		// The fingerprint has a separator of its own.
+		parts = slices.Replace(parts, 3, 5, parts[3]+shareSeparator+parts[4])
+	}
+	if len(parts) != 6 || parts[1] != shareVersion {
+		return nil, fmt.Errorf("%w: unsupported format", ErrMalformedShare)
+	}
+
+	threshold, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid threshold %q", ErrMalformedShare, parts[4])
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformedShare, err)
+	}
+
+	return &share{
+		Algorithm:   EncryptionType(parts[2]),
+		Fingerprint: parts[3],
+		Threshold:   threshold,
+		Payload:     payload,
+	}, nil
+}
+
+// SplitKey splits the plain contents of the key file into n printable
+// shares, any threshold of which rebuild it with CombineKey. Each share
+// carries the fingerprint of the key it belongs to.
+func (k *Keeper) SplitKey(n, threshold int) ([]string, error) {
+	if WrapsDataKey(k.encryptionType) {
+		return nil, ErrNoKeyFile
+	}
+	if err := k.lazyInit(); err != nil {
+		return nil, err
+	}
+
+	b, err := marshalKeys(k.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(b)
+
+	payloads, err := shamir.Split(b, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := shareFingerprint(k.encryptionKey, b)
+
+	shares := make([]string, len(payloads))
+	for i, payload := range payloads {
+		s := &share{Algorithm: k.encryptionType, Fingerprint: fingerprint, Threshold: threshold, Payload: payload}
+		shares[i] = s.String()
+		securemem.Wipe(payload)
+	}
+
+	return shares, nil
+}
+
+// CombineKey rebuilds a key file from shares written by SplitKey and writes
+// it, unprotected, to keyPath. It returns the key's type and fingerprint.
+func CombineKey(shares []string, keyPath string) (EncryptionType, string, error) {
+	if len(shares) == 0 {
+		return "", "", fmt.Errorf("%w: got none", ErrTooFewShares)
+	}
+
+	parsed := make([]*share, len(shares))
+	payloads := make([][]byte, len(shares))
+	for i, value := range shares {
+		s, err := parseShare(value)
+		if err != nil {
+			return "", "", fmt.Errorf("share %d: %w", i+1, err)
+		}
+
+		parsed[i], payloads[i] = s, s.Payload
+	}
+
+	s := parsed[0]
+	for i, other := range parsed[1:] {
+		if other.Algorithm != s.Algorithm || other.Fingerprint != s.Fingerprint || other.Threshold != s.Threshold {
+			return "", "", fmt.Errorf("share %d: %w", i+2, ErrMixedShares)
+		}
+	}
+
+	if len(parsed) < s.Threshold {
+		return "", "", fmt.Errorf("%w: got %d, need %d", ErrTooFewShares, len(parsed), s.Threshold)
+	}
+	if err := validateEncryptionType(s.Algorithm); err != nil {
+		return "", "", fmt.Errorf("%w: %s %q", ErrMalformedShare, err, s.Algorithm)
+	}
+
+	b, err := shamir.Combine(payloads)
+	if errors.Is(err, shamir.ErrMalformedShares) {
+		return "", "", fmt.Errorf("%w: %s", ErrMixedShares, err)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	defer securemem.Wipe(b)
+
+	// Shares from different splits of the same key combine into garbage,
+	// which won't parse or won't have the right fingerprint.
+	k := &Keeper{encryptionType: s.Algorithm, keyPath: keyPath}
+	err = k.parseKeys(bytes.Clone(b))
+	if err != nil || shareFingerprint(k.encryptionKey, b) != s.Fingerprint {
+		return "", "", fmt.Errorf("%w: they don't rebuild key %s", ErrMixedShares, s.Fingerprint)
+	}
+
+	err = fileutils.WriteFile(keyPath, b, 0600)
+	if err != nil {
+		return "", "", err
+	}
+
+	return s.Algorithm, s.Fingerprint, nil
+}
+
+// shareFingerprint names the key shares belong to. It's the key's own
+// fingerprint, or a digest of the key file for keys without one.
+func shareFingerprint(key any, b []byte) string {
+	if fingerprint := keyFingerprint(key); fingerprint != "" {
+		return fingerprint
+	}
+
+	return keyid.Fingerprint(b)
+}
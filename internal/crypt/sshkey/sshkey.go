@@ -0,0 +1,264 @@
+package sshkey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	// signedContext is prepended to the salt before it's signed, so the
+	// signature can't be mistaken for one made for anything else.
+	signedContext = "cryptkeeper key wrap v1\x00"
+	hkdfInfo      = "cryptkeeper ssh key wrap"
+)
+
+var (
+	ErrNotFound       = errors.New("no matching SSH key in ssh-agent or ~/.ssh")
+	ErrUnsupportedKey = errors.New("unsupported SSH key - only ssh-ed25519 and ssh-rsa keys make the same signature every time")
+)
+
+// DefaultKeyFiles are the private keys in ~/.ssh that are tried after the
+// keys in ssh-agent.
+var DefaultKeyFiles = []string{"id_ed25519", "id_rsa"}
+
+// candidate is an SSH key that may be able to sign. signer is only called
+// once the public key matched, so encrypted key files are only unlocked when
+// they're needed.
+type candidate struct {
+	public ssh.PublicKey
+	source string
+	signer func() (ssh.Signer, error)
+}
+
+// Fingerprint returns the SHA256 fingerprint of pub, as ssh-keygen -l
+// prints it.
+func Fingerprint(pub ssh.PublicKey) string {
+	return ssh.FingerprintSHA256(pub)
+}
+
+// Resolve returns the fingerprint of the SSH key named by s, which is a
+// fingerprint, the path to a public or private key file, or empty to pick
+// the first supported key in ssh-agent or ~/.ssh.
+func Resolve(s string) (string, error) {
+	if strings.HasPrefix(s, "SHA256:") {
+		return s, nil
+	}
+
+	if s != "" {
+		pub, err := readPublicKey(s)
+		if err != nil {
+			return "", err
+		}
+		if !supported(pub) {
+			return "", fmt.Errorf("%w: %s is %s", ErrUnsupportedKey, s, pub.Type())
+		}
+
+		return Fingerprint(pub), nil
+	}
+
+	candidates, closer := findCandidates()
+	defer closer()
+
+	for _, c := range candidates {
+		if supported(c.public) {
+			return Fingerprint(c.public), nil
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+// Derive derives a 32 byte wrapping key by signing salt with the SSH key
+// with the given fingerprint. Keys in ssh-agent are preferred over key
+// files. passphrase is called for encrypted key files.
+func Derive(fingerprint string, salt []byte, passphrase func(path string) ([]byte, error)) ([]byte, error) {
+	candidates, closer := findCandidates()
+	defer closer()
+
+	for _, c := range candidates {
+		if Fingerprint(c.public) != fingerprint {
+			continue
+		}
+		if !supported(c.public) {
+			return nil, fmt.Errorf("%w: %s is %s", ErrUnsupportedKey, fingerprint, c.public.Type())
+		}
+
+		signer, err := c.signer()
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) && passphrase != nil {
+			signer, err = parseEncrypted(c.source, passphrase)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key from %s: %w", c.source, err)
+		}
+
+		return derive(signer, salt)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, fingerprint)
+}
+
+func derive(signer ssh.Signer, salt []byte) ([]byte, error) {
+	message := append([]byte(signedContext), salt...)
+
+	var sig *ssh.Signature
+	var err error
+	if signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// PKCS #1 v1.5 signatures are deterministic, but the algorithm has
+		// to be pinned so agents don't pick a different hash.
+		algSigner, ok := signer.(ssh.AlgorithmSigner)
+		if !ok {
+			return nil, errors.New("SSH key can't make rsa-sha2-256 signatures")
+		}
+
+		sig, err = algSigner.SignWithAlgorithm(rand.Reader, message, ssh.KeyAlgoRSASHA256)
+	} else {
+		sig, err = signer.Sign(rand.Reader, message)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with SSH key: %w", err)
+	}
+
+	// The signature is as secret as the key derived from it.
+	defer securemem.Wipe(sig.Blob)
+
+	key := make([]byte, 32)
+	_, err = io.ReadFull(hkdf.New(sha256.New, sig.Blob, salt, []byte(hkdfInfo)), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// supported reports whether pub makes deterministic signatures. ECDSA and
+// security key signatures differ every time, so they can't derive a key.
+func supported(pub ssh.PublicKey) bool {
+	switch pub.Type() {
+	case ssh.KeyAlgoED25519, ssh.KeyAlgoRSA:
+		return true
+	default:
+		return false
+	}
+}
+
+// findCandidates lists the keys in ssh-agent followed by the default key
+// files. The returned func closes the connection to the agent.
+func findCandidates() ([]candidate, func()) {
+	var candidates []candidate
+	closer := func() {}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err == nil {
+			closer = func() { _ = conn.Close() }
+
+			signers, err := agent.NewClient(conn).Signers()
+			if err == nil {
+				for _, s := range signers {
+					s := s
+					candidates = append(candidates, candidate{
+						public: s.PublicKey(),
+						source: "ssh-agent",
+						signer: func() (ssh.Signer, error) { return s, nil },
+					})
+				}
+			}
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return candidates, closer
+	}
+
+	for _, name := range DefaultKeyFiles {
+		path := filepath.Join(home, ".ssh", name)
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		pub, err := privateKeyPublic(path, b)
+		if err != nil {
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			public: pub,
+			source: path,
+			signer: func() (ssh.Signer, error) { return ssh.ParsePrivateKey(b) },
+		})
+	}
+
+	return candidates, closer
+}
+
+// readPublicKey reads the public key from a public key file, or from a
+// private key file or the .pub file next to it.
+func readPublicKey(path string) (ssh.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if pub, _, _, _, err := ssh.ParseAuthorizedKey(b); err == nil {
+		return pub, nil
+	}
+
+	return privateKeyPublic(path, b)
+}
+
+// privateKeyPublic returns the public half of a private key file without
+// asking for its passphrase.
+func privateKeyPublic(path string, b []byte) (ssh.PublicKey, error) {
+	signer, err := ssh.ParsePrivateKey(b)
+	if err == nil {
+		return signer.PublicKey(), nil
+	}
+
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return nil, err
+	}
+	if missing.PublicKey != nil {
+		return missing.PublicKey, nil
+	}
+
+	// Older PEM keys don't carry their public key in the clear.
+	pub, err := os.ReadFile(path + ".pub")
+	if err != nil {
+		return nil, fmt.Errorf("%s is protected with a passphrase and has no .pub file", path)
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey(pub)
+	return key, err
+}
+
+func parseEncrypted(path string, passphrase func(path string) ([]byte, error)) (ssh.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pass, err := passphrase(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKeyWithPassphrase(b, pass)
+}
@@ -0,0 +1,203 @@
+package sshkey_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/sshkey"
+)
+
+// isolate points ssh-agent and ~/.ssh at empty locations for the test.
+func isolate(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(home, ".ssh"), 0700))
+
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	return home
+}
+
+// writeKey writes key to ~/.ssh/name and returns its public key.
+func writeKey(t *testing.T, home, name string, key any, passphrase []byte) ssh.PublicKey {
+	t.Helper()
+
+	var block *pem.Block
+	var err error
+	if passphrase == nil {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", passphrase)
+	}
+	assert.NoError(t, err)
+
+	path := filepath.Join(home, ".ssh", name)
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0600))
+
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NoError(t, err)
+
+	return signer.PublicKey()
+}
+
+// startAgent serves an in-memory ssh-agent holding key on SSH_AUTH_SOCK.
+func startAgent(t *testing.T, key any) ssh.PublicKey {
+	t.Helper()
+
+	keyring := agent.NewKeyring()
+	assert.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key}))
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", sock)
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NoError(t, err)
+
+	return signer.PublicKey()
+}
+
+func TestDerive(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  any
+	}{
+		{"ed25519", edKey},
+		{"RSA", rsaKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			home := isolate(t)
+
+			pub := writeKey(t, home, "id_key", tt.key, nil)
+			sshkey.DefaultKeyFiles = []string{"id_key"}
+			t.Cleanup(func() { sshkey.DefaultKeyFiles = []string{"id_ed25519", "id_rsa"} })
+
+			fingerprint := sshkey.Fingerprint(pub)
+			salt := []byte("0123456789abcdef")
+
+			fromFile, err := sshkey.Derive(fingerprint, salt, nil)
+			assert.NoError(err)
+			assert.Len(fromFile, 32)
+
+			// Signatures are deterministic, so the agent derives the same
+			// key as the file.
+			startAgent(t, tt.key)
+			assert.NoError(os.Remove(filepath.Join(home, ".ssh", "id_key")))
+
+			fromAgent, err := sshkey.Derive(fingerprint, salt, nil)
+			assert.NoError(err)
+			assert.Equal(fromFile, fromAgent)
+
+			other, err := sshkey.Derive(fingerprint, []byte("fedcba9876543210"), nil)
+			assert.NoError(err)
+			assert.NotEqual(fromFile, other)
+		})
+	}
+}
+
+func TestDeriveProtectedKeyFile(t *testing.T) {
+	assert := assert.New(t)
+	home := isolate(t)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+	pub := writeKey(t, home, "id_ed25519", key, []byte("hunter2"))
+
+	var asked string
+	derived, err := sshkey.Derive(sshkey.Fingerprint(pub), []byte("salt"), func(path string) ([]byte, error) {
+		asked = path
+		return []byte("hunter2"), nil
+	})
+	assert.NoError(err)
+	assert.Len(derived, 32)
+	assert.Equal(filepath.Join(home, ".ssh", "id_ed25519"), asked)
+}
+
+func TestDeriveNotFound(t *testing.T) {
+	assert := assert.New(t)
+	home := isolate(t)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+	writeKey(t, home, "id_ed25519", key, nil)
+
+	_, err = sshkey.Derive("SHA256:missing", []byte("salt"), nil)
+	assert.ErrorIs(err, sshkey.ErrNotFound)
+}
+
+func TestResolve(t *testing.T) {
+	assert := assert.New(t)
+	home := isolate(t)
+
+	_, err := sshkey.Resolve("")
+	assert.ErrorIs(err, sshkey.ErrNotFound)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+	pub := writeKey(t, home, "id_ed25519", edKey, nil)
+	fingerprint := sshkey.Fingerprint(pub)
+
+	resolved, err := sshkey.Resolve("")
+	assert.NoError(err)
+	assert.Equal(fingerprint, resolved)
+
+	resolved, err = sshkey.Resolve(filepath.Join(home, ".ssh", "id_ed25519"))
+	assert.NoError(err)
+	assert.Equal(fingerprint, resolved)
+
+	pubPath := filepath.Join(home, "id_ed25519.pub")
+	assert.NoError(os.WriteFile(pubPath, ssh.MarshalAuthorizedKey(pub), 0644))
+	resolved, err = sshkey.Resolve(pubPath)
+	assert.NoError(err)
+	assert.Equal(fingerprint, resolved)
+
+	resolved, err = sshkey.Resolve(fingerprint)
+	assert.NoError(err)
+	assert.Equal(fingerprint, resolved)
+
+	// ECDSA signatures differ every time.
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	writeKey(t, home, "id_ecdsa", ecKey, nil)
+	_, err = sshkey.Resolve(filepath.Join(home, ".ssh", "id_ecdsa"))
+	assert.ErrorIs(err, sshkey.ErrUnsupportedKey)
+}
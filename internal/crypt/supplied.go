@@ -0,0 +1,171 @@
+package crypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+
+	"github.com/sunny-b/cryptkeeper/internal/fileutils"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	// KeyEnvVar holds the base64 of a key file, for CI jobs that keep the
+	// key in a masked variable rather than on disk.
+	KeyEnvVar = "CK_KEY"
+
+	// KeyFileEnvVar names a key file to use instead of the configured one.
+	KeyFileEnvVar = "CK_KEY_FILE"
+)
+
+var (
+	ErrSuppliedKey          = errors.New("key is supplied by CK_KEY, CK_KEY_FILE or --key-fd - unset it to work on the key file itself")
+	ErrAmbiguousSuppliedKey = errors.New("only one of CK_KEY, CK_KEY_FILE and --key-fd can be set")
+)
+
+// KeyFD is an inherited file descriptor to read the key file from instead
+// of the configured key path. It's only read once. Zero means none.
+var KeyFD int
+
+var (
+	keyFDMu     sync.Mutex
+	keyFDPlain  []byte
+	keyFDNumber int
+)
+
+// WithSuppliedKey makes the keeper use a key supplied by CK_KEY,
+// CK_KEY_FILE or KeyFD ahead of the key file at its key path.
+func WithSuppliedKey() Option {
+	return func(o *options) {
+		o.suppliedKey = true
+	}
+}
+
+// KeySupplied reports whether a key is supplied by CK_KEY, CK_KEY_FILE or
+// KeyFD.
+func KeySupplied() bool {
+	return KeyFD > 0 || os.Getenv(KeyEnvVar) != "" || os.Getenv(KeyFileEnvVar) != ""
+}
+
+// PlainKeyFile returns the plain contents of the key file, even if it's
+// protected, in the form CK_KEY and CK_KEY_FILE take. They're the caller's
+// to wipe.
+func (k *Keeper) PlainKeyFile() ([]byte, error) {
+	if WrapsDataKey(k.encryptionType) {
+		return nil, ErrNoKeyFile
+	}
+	if err := k.lazyInit(); err != nil {
+		return nil, err
+	}
+
+	return marshalKeys(k.encryptionKey)
+}
+
+// ImportKeyFile writes the plain contents of a key file returned by
+// PlainKeyFile, unprotected, to keyPath and wipes them. It returns the
+// key's fingerprint.
+func ImportKeyFile(enc EncryptionType, b []byte, keyPath string) (string, error) {
+	defer securemem.Wipe(b)
+
+	if WrapsDataKey(enc) {
+		return "", ErrNoKeyFile
+	}
+	if err := validateEncryptionType(enc); err != nil {
+		return "", err
+	}
+	if fileutils.FileExists(keyPath) {
+		return "", fmt.Errorf("key file already exists at %s", keyPath)
+	}
+
+	k := &Keeper{encryptionType: enc, keyPath: keyPath}
+	if err := k.parseKeys(bytes.Clone(b)); err != nil {
+		return "", fmt.Errorf("not a plain %s key file: %w", enc, err)
+	}
+
+	err := fileutils.WriteFile(keyPath, b, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to write encryption key: %w", err)
+	}
+
+	return shareFingerprint(k.encryptionKey, b), nil
+}
+
+// suppliedKeyFile returns the plain contents of the supplied key file, for
+// the caller to wipe, and where they came from. It returns nil when no key
+// is supplied.
+func suppliedKeyFile() ([]byte, string, error) {
+	encoded := os.Getenv(KeyEnvVar)
+	path := os.Getenv(KeyFileEnvVar)
+
+	supplied := 0
+	for _, set := range []bool{encoded != "", path != "", KeyFD > 0} {
+		if set {
+			supplied++
+		}
+	}
+	if supplied > 1 {
+		return nil, "", ErrAmbiguousSuppliedKey
+	}
+
+	switch {
+	case encoded != "":
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, "", fmt.Errorf("%s isn't valid base64: %w", KeyEnvVar, err)
+		}
+
+		return b, KeyEnvVar, nil
+	case path != "":
+		b, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s: %w", KeyFileEnvVar, err)
+		}
+
+		return b, path, nil
+	case KeyFD > 0:
+		b, err := readKeyFD(KeyFD)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return b, fmt.Sprintf("file descriptor %d", KeyFD), nil
+	}
+
+	return nil, "", nil
+}
+
+// readKeyFD reads the key file from an inherited file descriptor. Pipes can
+// only be read once, so the contents are kept in locked memory for every
+// keeper that needs them.
+func readKeyFD(fd int) ([]byte, error) {
+	keyFDMu.Lock()
+	defer keyFDMu.Unlock()
+
+	if keyFDPlain != nil && keyFDNumber == fd {
+		return bytes.Clone(keyFDPlain), nil
+	}
+
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if f == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		securemem.Wipe(b)
+		return nil, fmt.Errorf("failed to read the key from file descriptor %d: %w", fd, err)
+	}
+
+	keyFDPlain = securemem.Lock(b)
+	keyFDNumber = fd
+
+	return bytes.Clone(keyFDPlain), nil
+}
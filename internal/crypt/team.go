@@ -0,0 +1,162 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	fage "filippo.io/age"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/age"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/x25519"
+)
+
+// teamSecretAD separates the sealed team secret from sealed values.
+const teamSecretAD = "cryptkeeper team secret v1"
+
+// HasRecipients reports whether values of type t are encrypted to a list of
+// recipients, each of whom decrypts with their own identity.
+func HasRecipients(t EncryptionType) bool {
+	return t == X25519 || t == Age
+}
+
+// NewTeamSecret seals a fresh random secret to the given recipients.
+// Projects with recipients authenticate their config with a key derived
+// from it, since the members don't share any other secret. A new one is made
+// whenever the recipients change, so removed members can't forge a valid
+// config.
+func NewTeamSecret(t EncryptionType, recipients []string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return "", err
+	}
+
+	var sealed []byte
+	var err error
+	switch t {
+	case X25519:
+		var parsed []*x25519.Recipient
+		parsed, err = parseX25519Recipients(recipients)
+		if err == nil {
+			sealed, err = x25519.Seal(secret, parsed, []byte(teamSecretAD))
+		}
+	case Age:
+		var parsed []fage.Recipient
+		parsed, err = parseAgeRecipients(recipients)
+		if err == nil {
+			sealed, err = age.Seal(secret, parsed)
+		}
+	default:
+		err = fmt.Errorf("%s has no recipients", t)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// ValidateRecipient checks that recipient is a public key values of type t
+// can be encrypted to.
+func ValidateRecipient(t EncryptionType, recipient string) error {
+	var err error
+	switch t {
+	case X25519:
+		_, err = x25519.ParseRecipient(recipient)
+	case Age:
+		_, err = age.ParseRecipient(recipient)
+	default:
+		err = fmt.Errorf("%s has no recipients", t)
+	}
+
+	return err
+}
+
+// Recipient returns the public key others encrypt to for this keeper's
+// identity.
+func (k *Keeper) Recipient() (string, error) {
+	if err := k.lazyInit(); err != nil {
+		return "", err
+	}
+
+	switch key := k.encryptionKey.(type) {
+	case *x25519.Identity:
+		return key.Recipient().String(), nil
+	case *age.Keys:
+		return key.Recipient, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNoPublicKey, k.encryptionType)
+}
+
+// openTeamSecret opens the sealed team secret with this keeper's identity.
+func (k *Keeper) openTeamSecret() ([]byte, error) {
+	if k.teamSecret == "" {
+		return nil, ErrNoMACKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(k.teamSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid team secret: %w", err)
+	}
+
+	var secret []byte
+	switch key := k.encryptionKey.(type) {
+	case *x25519.Identity:
+		secret, err = x25519.Open(sealed, key, []byte(teamSecretAD))
+	case *age.Keys:
+		secret, err = age.Open(sealed, key)
+	default:
+		return nil, errors.New("corrupted key file")
+	}
+	if isNotRecipient(err) {
+		return nil, ErrNotRecipient
+	}
+
+	return secret, err
+}
+
+// isNotRecipient reports whether err means the identity isn't one of the
+// recipients a value was encrypted to.
+func isNotRecipient(err error) bool {
+	var noMatch *fage.NoIdentityMatchError
+	return errors.Is(err, x25519.ErrNotRecipient) || errors.As(err, &noMatch)
+}
+
+func parseX25519Recipients(recipients []string) ([]*x25519.Recipient, error) {
+	parsed := make([]*x25519.Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		recipient, err := x25519.ParseRecipient(r)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, recipient)
+	}
+
+	if len(parsed) == 0 {
+		return nil, x25519.ErrNoRecipients
+	}
+
+	return parsed, nil
+}
+
+func parseAgeRecipients(recipients []string) ([]fage.Recipient, error) {
+	parsed := make([]fage.Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		recipient, err := age.ParseRecipient(r)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, recipient)
+	}
+
+	if len(parsed) == 0 {
+		return nil, x25519.ErrNoRecipients
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,93 @@
+package crypt
+
+import (
+	"errors"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/vault"
+)
+
+var ErrNoTransitKey = errors.New("vault-transit needs the name of the transit key")
+
+// VaultTransitKey names the key in Vault's transit engine that wraps a
+// project's data key, and holds the data key it wrapped. Values are
+// encrypted locally with AES-256-GCM under the data key. The address and
+// token themselves are never part of the config: they're read from the
+// environment variables it names, or from VAULT_ADDR and VAULT_TOKEN, or
+// ~/.vault-token, of whoever loads it.
+type VaultTransitKey struct {
+	AddressEnv string `json:"address_env,omitempty"`
+	Mount      string `json:"mount,omitempty"`
+	KeyName    string `json:"key_name"`
+	TokenEnv   string `json:"token_env,omitempty"`
+	WrappedKey string `json:"wrapped_key,omitempty"`
+}
+
+// WithVaultTransitKey sets the transit key and wrapped data key
+// VaultTransit keepers use.
+func WithVaultTransitKey(key *VaultTransitKey) Option {
+	return func(o *options) {
+		o.transitKey = key
+	}
+}
+
+// NewVaultTransitKey generates a data key and has Vault wrap it with the
+// transit key named by key, replacing its wrapped key.
+func NewVaultTransitKey(key *VaultTransitKey) error {
+	w, err := key.wrapper()
+	if err != nil {
+		return err
+	}
+
+	key.WrappedKey, err = newDataKey(w)
+
+	return err
+}
+
+// transitWrapper has Vault wrap data keys with a transit key. Wrapped keys
+// are Vault's ciphertexts.
+type transitWrapper struct {
+	vault.Transit
+}
+
+// transitDataKey returns the wrapper for the keeper's transit key and the
+// data key it wrapped.
+func (k *Keeper) transitDataKey() (keyWrapper, string, error) {
+	w, err := k.transitKey.wrapper()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return w, k.transitKey.WrappedKey, nil
+}
+
+// wrapper returns the wrapper for the transit key.
+func (key *VaultTransitKey) wrapper() (transitWrapper, error) {
+	if key == nil || key.KeyName == "" {
+		return transitWrapper{}, ErrNoTransitKey
+	}
+
+	address, err := vault.Address(key.AddressEnv)
+	if err != nil {
+		return transitWrapper{}, err
+	}
+
+	token, err := vault.ReadToken(key.TokenEnv)
+	if err != nil {
+		return transitWrapper{}, err
+	}
+
+	return transitWrapper{vault.Transit{
+		Address: address,
+		Mount:   key.Mount,
+		KeyName: key.KeyName,
+		Token:   token,
+	}}, nil
+}
+
+func (w transitWrapper) wrap(dataKey []byte) (string, error) {
+	return w.Encrypt(dataKey)
+}
+
+func (w transitWrapper) unwrap(wrapped string) ([]byte, error) {
+	return w.Decrypt(wrapped)
+}
@@ -0,0 +1,113 @@
+package crypt_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/vault"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/vault/vaulttest"
+)
+
+func TestKeeperVaultTransit(t *testing.T) {
+	assert := assert.New(t)
+	server := vaulttest.NewServer(t)
+	t.Setenv(vault.AddrEnvVar, server.URL)
+	t.Setenv(vault.TokenEnvVar, vaulttest.Token)
+
+	transitKey := &crypt.VaultTransitKey{KeyName: "project-transit"}
+	assert.NoError(crypt.NewVaultTransitKey(transitKey))
+	assert.True(strings.HasPrefix(transitKey.WrappedKey, "vault:v1:"))
+
+	keeper, err := crypt.NewKeeper(crypt.VaultTransit, "", crypt.WithVaultTransitKey(transitKey), crypt.WithProjectID("project"))
+	assert.NoError(err)
+	assert.Contains(keeper.KeySource(), "project-transit")
+
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(cipher, "ck:v2:vault-transit:"))
+
+	// The data key is only unwrapped once per process.
+	reloaded, err := crypt.NewKeeper(crypt.VaultTransit, "", crypt.WithVaultTransitKey(transitKey), crypt.WithProjectID("project"))
+	assert.NoError(err)
+	assert.Equal(1, server.Decrypts())
+
+	plainText, err := reloaded.Decrypt("FOO", cipher)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+
+	_, err = keeper.PlainKeyFile()
+	assert.ErrorIs(err, crypt.ErrNoKeyFile)
+
+	rewrapped := *transitKey
+	assert.NoError(crypt.NewVaultTransitKey(&rewrapped))
+	rotated, err := crypt.NewKeeper(crypt.VaultTransit, "", crypt.WithVaultTransitKey(&rewrapped), crypt.WithProjectID("project"))
+	assert.NoError(err)
+	assert.Equal(2, server.Decrypts())
+
+	_, err = rotated.Decrypt("FOO", cipher)
+	assert.ErrorIs(err, crypt.ErrKeyNotFound)
+}
+
+func TestKeeperVaultTransitNamedEnv(t *testing.T) {
+	assert := assert.New(t)
+	server := vaulttest.NewServer(t)
+	t.Setenv(vault.AddrEnvVar, "")
+	t.Setenv(vault.TokenEnvVar, "")
+	t.Setenv("VAULT_ADDR_PROJECT", server.URL)
+	t.Setenv("VAULT_TOKEN_PROJECT", vaulttest.Token)
+
+	transitKey := &crypt.VaultTransitKey{AddressEnv: "VAULT_ADDR_PROJECT", KeyName: "project-named", TokenEnv: "VAULT_TOKEN_PROJECT"}
+	assert.NoError(crypt.NewVaultTransitKey(transitKey))
+
+	keeper, err := crypt.NewKeeper(crypt.VaultTransit, "", crypt.WithVaultTransitKey(transitKey))
+	assert.NoError(err)
+
+	cipher, err := keeper.Encrypt("FOO", "bar")
+	assert.NoError(err)
+
+	plainText, err := keeper.Decrypt("FOO", cipher)
+	assert.NoError(err)
+	assert.Equal("bar", plainText)
+}
+
+func TestKeeperVaultTransitFailures(t *testing.T) {
+	server := vaulttest.NewServer(t)
+	t.Setenv(vault.AddrEnvVar, server.URL)
+	t.Setenv(vault.TokenEnvVar, vaulttest.Token)
+
+	transitKey := &crypt.VaultTransitKey{KeyName: "project-failures"}
+	assert.NoError(t, crypt.NewVaultTransitKey(transitKey))
+
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, k *crypt.VaultTransitKey)
+		err    error
+	}{
+		{"No key name", func(t *testing.T, k *crypt.VaultTransitKey) { k.KeyName = "" }, crypt.ErrNoTransitKey},
+		{"Unknown key", func(t *testing.T, k *crypt.VaultTransitKey) { k.KeyName = "unknown" }, nil},
+		{"Wrong token", func(t *testing.T, k *crypt.VaultTransitKey) { t.Setenv(vault.TokenEnvVar, "wrong") }, nil},
+		{"No address", func(t *testing.T, k *crypt.VaultTransitKey) { t.Setenv(vault.AddrEnvVar, "") }, vault.ErrNoAddress},
+		{"Token not meant for Vault", func(t *testing.T, k *crypt.VaultTransitKey) { k.TokenEnv = "HOME" }, vault.ErrInvalidEnvVar},
+		{"Address not meant for Vault", func(t *testing.T, k *crypt.VaultTransitKey) { k.AddressEnv = "HOME" }, vault.ErrInvalidEnvVar},
+		{"No wrapped key", func(t *testing.T, k *crypt.VaultTransitKey) { k.WrappedKey = "" }, nil},
+		{"Tampered", func(t *testing.T, k *crypt.VaultTransitKey) {
+			k.WrappedKey = strings.Replace(k.WrappedKey, "vault:v1:", "vault:v1:A", 1)
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := *transitKey
+			tt.tamper(t, &k)
+
+			_, err := crypt.NewKeeper(crypt.VaultTransit, "", crypt.WithVaultTransitKey(&k))
+			assert.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
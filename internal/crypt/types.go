@@ -1,13 +1,117 @@
 package crypt
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 type EncryptionType string
 
 var (
 	ErrUnknownEncryptionType                = errors.New("unknown encryption type")
+	ErrUnsupportedKeySize                   = errors.New("unsupported key size")
+	ErrKeyNotFound                          = errors.New("no loaded key matches the encrypted value")
+	ErrUnboundValue                         = errors.New("value isn't bound to its secret name - run 'cryptkeeper reencrypt' to upgrade it")
+	ErrNoPublicKey                          = errors.New("encryption type has no public key")
+	ErrPublicKeyOnly                        = errors.New("key file only holds the public key - decrypting needs the private key")
+	ErrNotRecipient                         = errors.New("your key isn't one of the project's recipients - ask a teammate to run 'cryptkeeper recipients add' with your public key")
+	ErrNoKeyFile                            = errors.New("the key is wrapped by an HSM, Vault or a key provider and has no key file")
+	ErrAuthenticationFailed                 = errors.New("value failed authentication - it was tampered with, moved from another secret or copied from another project")
 	AES256                   EncryptionType = "aes256"
+	AES256SIV                EncryptionType = "aes256-siv"
 	ECC256                   EncryptionType = "ecc256"
 	RSA2048                  EncryptionType = "rsa2048"
+	RSA4096                  EncryptionType = "rsa4096"
 	Serpent256               EncryptionType = "serpent256"
+	XChaCha20                EncryptionType = "xchacha20"
+	X25519                   EncryptionType = "x25519"
+	Age                      EncryptionType = "age"
+	MLKEM768X25519           EncryptionType = "mlkem768-x25519"
+	PKCS11                   EncryptionType = "pkcs11"
+	VaultTransit             EncryptionType = "vault-transit"
+	KeyProvider              EncryptionType = "keyprovider"
 )
+
+// ParseEncryptionType maps the names accepted on the command line, such as
+// "aes" or "rsa-2048", to an EncryptionType.
+func ParseEncryptionType(name string) (EncryptionType, error) {
+	switch strings.ToLower(name) {
+	case "aes", "aes256", "aes-256":
+		return AES256, nil
+	case "aes-siv", "aes256-siv", "aes-256-siv", "siv":
+		return AES256SIV, nil
+	case "rsa", "rsa2048", "rsa-2048":
+		return RSA2048, nil
+	case "rsa4096", "rsa-4096":
+		return RSA4096, nil
+	case "ecc", "ecc256", "ecc-256":
+		return ECC256, nil
+	case "serpent", "serpent256", "serpent-256":
+		return Serpent256, nil
+	case "xchacha20", "xchacha20-poly1305", "xchacha":
+		return XChaCha20, nil
+	case "x25519", "team":
+		return X25519, nil
+	case "age":
+		return Age, nil
+	case "mlkem768-x25519", "mlkem768", "mlkem", "pq", "hybrid":
+		return MLKEM768X25519, nil
+	case "pkcs11", "hsm":
+		return PKCS11, nil
+	case "vault-transit", "vault", "transit":
+		return VaultTransit, nil
+	case "keyprovider", "key-provider", "provider", "plugin":
+		return KeyProvider, nil
+	default:
+		return "", ErrUnknownEncryptionType
+	}
+}
+
+// WrapsDataKey reports whether projects of type t keep a data key in their
+// config instead of a key file. The data key is wrapped by a key that never
+// leaves an HSM, Vault or a key provider, and values are encrypted with
+// AES-256-GCM under it.
+func WrapsDataKey(t EncryptionType) bool {
+	_, ok := wrappedDataKeys[t]
+	return ok
+}
+
+// rsaTypes maps the supported RSA modulus sizes to their encryption type.
+var rsaTypes = map[int]EncryptionType{
+	2048: RSA2048,
+	4096: RSA4096,
+}
+
+// ResizeEncryptionType returns the variant of t with keys of the given size
+// in bits, so "rsa" with 4096 bits becomes RSA4096.
+func ResizeEncryptionType(t EncryptionType, bits int) (EncryptionType, error) {
+	// Wrapped data keys are AES-256 keys.
+	if WrapsDataKey(t) && bits == 256 {
+		return t, nil
+	}
+
+	switch t {
+	case RSA2048, RSA4096:
+		if resized, ok := rsaTypes[bits]; ok {
+			return resized, nil
+		}
+	case AES256, AES256SIV, ECC256, Serpent256, XChaCha20, X25519, Age, MLKEM768X25519:
+		if bits == 256 {
+			return t, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s doesn't support %d-bit keys", ErrUnsupportedKeySize, t, bits)
+}
+
+// keySize returns the size in bits of the keys generated for t.
+func keySize(t EncryptionType) int {
+	for bits, rsaType := range rsaTypes {
+		if rsaType == t {
+			return bits
+		}
+	}
+
+	return 256
+}
@@ -0,0 +1,280 @@
+// Package vault wraps data keys with a key in HashiCorp Vault's transit
+// engine, which never hands out the key itself.
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// AddrEnvVar and TokenEnvVar are read by the vault CLI too. A config
+	// can name other environment variables to read the address and token
+	// from, but only ones starting with envPrefix: never a file, and never
+	// the address or token itself, so a config can't point the token at
+	// another server or read secrets that aren't meant for Vault.
+	AddrEnvVar  = "VAULT_ADDR"
+	TokenEnvVar = "VAULT_TOKEN"
+	envPrefix   = "VAULT_"
+
+	// DefaultMount is where 'vault secrets enable transit' mounts the
+	// engine.
+	DefaultMount = "transit"
+
+	// tokenHeader carries the token on every request.
+	tokenHeader = "X-Vault-Token"
+)
+
+var (
+	ErrNoAddress       = errors.New("no Vault address - set " + AddrEnvVar)
+	ErrNoToken         = errors.New("no Vault token - set " + TokenEnvVar + " or log in with 'vault login'")
+	ErrInsecureAddress = errors.New("the Vault address must use https, unless it's on the loopback interface")
+	ErrInvalidEnvVar   = errors.New("the Vault address and token can only be read from environment variables starting with " + envPrefix)
+)
+
+// ResponseError is an error Vault responded with.
+type ResponseError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *ResponseError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("vault responded with %d: %s", e.StatusCode, strings.Join(e.Errors, "; "))
+}
+
+// Transit names a key in a transit engine and the token used to reach it.
+type Transit struct {
+	Address string
+	Mount   string
+	KeyName string
+	Token   string
+
+	// Client sends the requests. http.DefaultClient with a timeout is used
+	// when it's nil.
+	Client *http.Client
+}
+
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// Encrypt has Vault encrypt plaintext with the transit key and returns the
+// ciphertext, prefixed with vault:v<version>: as Vault writes it.
+func (t Transit) Encrypt(plaintext []byte) (string, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+
+	err := t.post("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}, &resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.Data.Ciphertext == "" {
+		return "", errors.New("vault returned no ciphertext")
+	}
+
+	return resp.Data.Ciphertext, nil
+}
+
+// Decrypt has Vault decrypt a ciphertext returned by Encrypt. The plaintext
+// is the caller's to wipe.
+func (t Transit) Decrypt(ciphertext string) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+
+	err := t.post("decrypt", map[string]string{"ciphertext": ciphertext}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault returned invalid plaintext: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func (t Transit) String() string {
+	return fmt.Sprintf("transit key %q at %s/v1/%s", t.KeyName, strings.TrimSuffix(t.Address, "/"), t.mount())
+}
+
+func (t Transit) mount() string {
+	if t.Mount == "" {
+		return DefaultMount
+	}
+
+	return strings.Trim(t.Mount, "/")
+}
+
+// post sends body to the transit endpoint op and decodes the response into
+// out.
+func (t Transit) post(op string, body, out any) error {
+	if t.Address == "" {
+		return ErrNoAddress
+	}
+	err := checkAddress(t.Address)
+	if err != nil {
+		return err
+	}
+	if t.Token == "" {
+		return ErrNoToken
+	}
+
+	endpoint, err := url.JoinPath(t.Address, "v1", t.mount(), op, url.PathEscape(t.KeyName))
+	if err != nil {
+		return fmt.Errorf("invalid Vault address %q: %w", t.Address, err)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(tokenHeader, t.Token)
+
+	client := t.Client
+	if client == nil {
+		client = defaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read Vault's response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respErr := &ResponseError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(b, respErr)
+
+		return fmt.Errorf("%s %s: %w", op, t, respErr)
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+// checkAddress refuses addresses the token would be sent to in the clear:
+// plain http is only accepted on the loopback interface.
+func checkAddress(address string) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("invalid Vault address %q: %w", address, err)
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w, not %q", ErrInsecureAddress, address)
+}
+
+// ValidateEnvVar checks that a config may read the address or token from
+// the environment variable name. Empty names the default.
+func ValidateEnvVar(name string) error {
+	if name == "" || strings.HasPrefix(name, envPrefix) && len(name) > len(envPrefix) {
+		return nil
+	}
+
+	return fmt.Errorf("%w, not %q", ErrInvalidEnvVar, name)
+}
+
+// Address returns the address in the environment variable addrEnv, or in
+// VAULT_ADDR when it's empty.
+func Address(addrEnv string) (string, error) {
+	if addrEnv == "" {
+		return os.Getenv(AddrEnvVar), nil
+	}
+	if err := ValidateEnvVar(addrEnv); err != nil {
+		return "", err
+	}
+
+	address := os.Getenv(addrEnv)
+	if address == "" {
+		return "", fmt.Errorf("%w: %s is empty", ErrNoAddress, addrEnv)
+	}
+
+	return address, nil
+}
+
+// ReadToken reads the token in the environment variable tokenEnv. When it's
+// empty, it reads VAULT_TOKEN, or ~/.vault-token where 'vault login' leaves
+// it.
+func ReadToken(tokenEnv string) (string, error) {
+	if tokenEnv != "" {
+		if err := ValidateEnvVar(tokenEnv); err != nil {
+			return "", err
+		}
+
+		token := os.Getenv(tokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("%w: %s is empty", ErrNoToken, tokenEnv)
+		}
+
+		return token, nil
+	}
+
+	if token := os.Getenv(TokenEnvVar); token != "" {
+		return token, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", ErrNoToken
+	}
+
+	token, err := readTokenFile(filepath.Join(home, ".vault-token"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoToken
+	}
+
+	return token, err
+}
+
+func readTokenFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Vault token: %w", err)
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("%w: %s is empty", ErrNoToken, path)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,163 @@
+package vault_test
+
+import (
+	"crypto/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/vault"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/vault/vaulttest"
+)
+
+func TestTransit(t *testing.T) {
+	server := vaulttest.NewServer(t)
+	testTransit(t, vault.Transit{Address: server.URL, KeyName: "project", Token: vaulttest.Token})
+}
+
+// TestTransitDevServer runs against a real Vault, such as one started with
+// 'vault server -dev' and 'vault secrets enable transit', when VAULT_ADDR
+// and VAULT_TOKEN point at it.
+func TestTransitDevServer(t *testing.T) {
+	address, token := os.Getenv(vault.AddrEnvVar), os.Getenv(vault.TokenEnvVar)
+	if address == "" || token == "" {
+		t.Skip("VAULT_ADDR and VAULT_TOKEN aren't set")
+	}
+
+	testTransit(t, vault.Transit{Address: address, KeyName: "cryptkeeper-test", Token: token})
+}
+
+func testTransit(t *testing.T, transit vault.Transit) {
+	assert := assert.New(t)
+
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	assert.NoError(err)
+
+	ciphertext, err := transit.Encrypt(dataKey)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(ciphertext, "vault:v"))
+
+	plaintext, err := transit.Decrypt(ciphertext)
+	assert.NoError(err)
+	assert.Equal(dataKey, plaintext)
+
+	other := transit
+	other.KeyName += "-other"
+	_, err = other.Encrypt(dataKey)
+	assert.NoError(err)
+	_, err = other.Decrypt(ciphertext)
+	assert.Error(err)
+
+	wrongToken := transit
+	wrongToken.Token = "wrong"
+	_, err = wrongToken.Decrypt(ciphertext)
+
+	var respErr *vault.ResponseError
+	assert.ErrorAs(err, &respErr)
+	assert.Equal(http.StatusForbidden, respErr.StatusCode)
+}
+
+func TestTransitUnreachable(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := vault.Transit{KeyName: "project", Token: "token"}.Encrypt([]byte("key"))
+	assert.ErrorIs(err, vault.ErrNoAddress)
+
+	_, err = vault.Transit{Address: "http://127.0.0.1:1", KeyName: "project"}.Encrypt([]byte("key"))
+	assert.ErrorIs(err, vault.ErrNoToken)
+}
+
+func TestTransitInsecureAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		err     error
+	}{
+		{"http://vault.example.com:8200", vault.ErrInsecureAddress},
+		{"http://10.0.0.1:8200", vault.ErrInsecureAddress},
+		{"vault.example.com:8200", vault.ErrInsecureAddress},
+		{"https://127.0.0.1:1", nil},
+		{"http://localhost:1", nil},
+		{"http://[::1]:1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			_, err := vault.Transit{Address: tt.address, KeyName: "project", Token: "token"}.Encrypt([]byte("key"))
+			assert.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NotErrorIs(t, err, vault.ErrInsecureAddress)
+			}
+		})
+	}
+}
+
+func TestReadToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(vault.TokenEnvVar, "")
+
+	tests := []struct {
+		name     string
+		tokenEnv string
+		setup    func(t *testing.T)
+		expected string
+		err      error
+	}{
+		{"VAULT_TOKEN", "", func(t *testing.T) { t.Setenv(vault.TokenEnvVar, "default") }, "default", nil},
+		{"vault login", "", func(t *testing.T) {
+			assert.NoError(t, os.WriteFile(filepath.Join(home, ".vault-token"), []byte("login"), 0600))
+			t.Cleanup(func() { os.Remove(filepath.Join(home, ".vault-token")) })
+		}, "login", nil},
+		{"Empty token file", "", func(t *testing.T) {
+			assert.NoError(t, os.WriteFile(filepath.Join(home, ".vault-token"), []byte("\n"), 0600))
+			t.Cleanup(func() { os.Remove(filepath.Join(home, ".vault-token")) })
+		}, "", vault.ErrNoToken},
+		{"No token", "", func(t *testing.T) {}, "", vault.ErrNoToken},
+		{"Named variable", "VAULT_TOKEN_PROD", func(t *testing.T) { t.Setenv("VAULT_TOKEN_PROD", "prod") }, "prod", nil},
+		{"Empty named variable", "VAULT_TOKEN_PROD", func(t *testing.T) { t.Setenv(vault.TokenEnvVar, "default") }, "", vault.ErrNoToken},
+		{"Variable not meant for Vault", "AWS_SECRET_ACCESS_KEY", func(t *testing.T) { t.Setenv("AWS_SECRET_ACCESS_KEY", "secret") }, "", vault.ErrInvalidEnvVar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(t)
+
+			token, err := vault.ReadToken(tt.tokenEnv)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, token)
+		})
+	}
+}
+
+func TestAddress(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv(vault.AddrEnvVar, "https://vault.example.com")
+	t.Setenv("VAULT_ADDR_PROD", "https://vault.prod.example.com")
+	t.Setenv("HOME_URL", "https://attacker.example.com")
+
+	address, err := vault.Address("")
+	assert.NoError(err)
+	assert.Equal("https://vault.example.com", address)
+
+	address, err = vault.Address("VAULT_ADDR_PROD")
+	assert.NoError(err)
+	assert.Equal("https://vault.prod.example.com", address)
+
+	_, err = vault.Address("VAULT_ADDR_STAGING")
+	assert.ErrorIs(err, vault.ErrNoAddress)
+
+	_, err = vault.Address("HOME_URL")
+	assert.ErrorIs(err, vault.ErrInvalidEnvVar)
+}
@@ -0,0 +1,155 @@
+// Package vaulttest serves a stub of Vault's transit engine for tests.
+package vaulttest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	// Token is the only token the stub accepts.
+	Token = "vaulttest-token"
+
+	ciphertextPrefix = "vault:v1:"
+)
+
+// Server implements the encrypt and decrypt endpoints of a transit engine
+// mounted at Mount. Like Vault, it creates a key the first time something
+// is encrypted with it.
+type Server struct {
+	*httptest.Server
+
+	Mount string
+
+	mu       sync.Mutex
+	keys     map[string]cipher.AEAD
+	decrypts int
+}
+
+// NewServer starts a stub with the engine at "transit". It's closed when
+// the test finishes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{Mount: "transit", keys: make(map[string]cipher.AEAD)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Decrypts returns how many decrypt requests succeeded.
+func (s *Server) Decrypts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.decrypts
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != Token {
+		respondError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	route, mounted := strings.CutPrefix(r.URL.Path, "/v1/"+s.Mount+"/")
+	op, name, ok := strings.Cut(route, "/")
+	if r.Method != http.MethodPost || !mounted || !ok || name == "" {
+		respondError(w, http.StatusNotFound, "no handler for route")
+		return
+	}
+
+	var req struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch op {
+	case "encrypt":
+		s.encrypt(w, name, req.Plaintext)
+	case "decrypt":
+		s.decrypt(w, name, req.Ciphertext)
+	default:
+		respondError(w, http.StatusNotFound, "no handler for route")
+	}
+}
+
+func (s *Server) encrypt(w http.ResponseWriter, name, plaintext string) {
+	b, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to base64-decode plaintext")
+		return
+	}
+
+	aead, ok := s.keys[name]
+	if !ok {
+		key := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		block, _ := aes.NewCipher(key)
+		aead, _ = cipher.NewGCM(block)
+		s.keys[name] = aead
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sealed := aead.Seal(nonce, nonce, b, nil)
+	respond(w, map[string]string{"ciphertext": ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed)})
+}
+
+func (s *Server) decrypt(w http.ResponseWriter, name, ciphertext string) {
+	aead, ok := s.keys[name]
+	if !ok {
+		respondError(w, http.StatusBadRequest, "encryption key not found")
+		return
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, ciphertextPrefix))
+	if err != nil || !strings.HasPrefix(ciphertext, ciphertextPrefix) || len(sealed) < aead.NonceSize() {
+		respondError(w, http.StatusBadRequest, "invalid ciphertext")
+		return
+	}
+
+	b, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "cipher: message authentication failed")
+		return
+	}
+
+	s.decrypts++
+	respond(w, map[string]string{"plaintext": base64.StdEncoding.EncodeToString(b)})
+}
+
+func respond(w http.ResponseWriter, data map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func respondError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string][]string{"errors": {msg}})
+}
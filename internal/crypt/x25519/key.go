@@ -0,0 +1,121 @@
+package x25519
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+)
+
+// RecipientPrefix starts every recipient string, so they can't be confused
+// with other kinds of keys.
+const RecipientPrefix = "ck-x25519:"
+
+var ErrInvalidRecipient = errors.New("invalid recipient - expected " + RecipientPrefix + "<base64 public key>")
+
+// Identity is a team member's private key. Each member keeps their own and
+// never shares it.
+type Identity struct {
+	Private *ecdh.PrivateKey
+}
+
+// Recipient is a team member's public key. Values are encrypted to every
+// recipient listed in the project.
+type Recipient struct {
+	Public *ecdh.PublicKey
+}
+
+func GenerateIdentity() (*Identity, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{private}, nil
+}
+
+// Recipient returns the public key other members encrypt to.
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{i.Private.PublicKey()}
+}
+
+// ID returns the short identifier of the identity's public key.
+func (i *Identity) ID() string {
+	return i.Recipient().ID()
+}
+
+// Fingerprint identifies the identity in full by its public key.
+func (i *Identity) Fingerprint() string {
+	return keyid.Fingerprint(i.Recipient().Public.Bytes())
+}
+
+func (i *Identity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"private_key": base64.StdEncoding.EncodeToString(i.Private.Bytes()),
+		"public_key":  i.Recipient().String(),
+	})
+}
+
+func (i *Identity) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(m["private_key"])
+	if err != nil {
+		return err
+	}
+
+	i.Private, err = ecdh.X25519().NewPrivateKey(raw)
+
+	return err
+}
+
+// ParseRecipient parses a recipient string written by Recipient.String.
+func ParseRecipient(s string) (*Recipient, error) {
+	encoded, ok := strings.CutPrefix(s, RecipientPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRecipient, s)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRecipient, s)
+	}
+
+	public, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRecipient, s)
+	}
+
+	return &Recipient{public}, nil
+}
+
+func (r *Recipient) String() string {
+	return RecipientPrefix + base64.StdEncoding.EncodeToString(r.Public.Bytes())
+}
+
+// ID returns the short identifier of the recipient's public key.
+func (r *Recipient) ID() string {
+	return keyid.New(r.Public.Bytes())
+}
+
+// SetID identifies a set of recipients regardless of their order. It's
+// recorded next to every value encrypted to the set.
+func SetID(recipients []*Recipient) string {
+	keys := make([]string, 0, len(recipients))
+	for _, r := range recipients {
+		keys = append(keys, r.String())
+	}
+	sort.Strings(keys)
+
+	return keyid.New([]byte(strings.Join(keys, "\n")))
+}
@@ -0,0 +1,222 @@
+package x25519
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	// info separates the keys that wrap data keys from anything else
+	// derived from the same shared secret.
+	info = "cryptkeeper x25519 v1"
+
+	dataKeySize = 32
+
+	// stanzaSize is an ephemeral public key followed by the wrapped data key
+	// and its GCM tag.
+	stanzaSize = 32 + dataKeySize + 16
+
+	// maxRecipients bounds how many stanzas Open reads.
+	maxRecipients = 1024
+)
+
+var (
+	ErrNoRecipients      = errors.New("no recipients to encrypt to")
+	ErrNotRecipient      = errors.New("identity isn't one of the recipients")
+	ErrInvalidCiphertext = errors.New("invalid x25519 ciphertext")
+)
+
+// X25519 encrypts every value to a set of recipients, so each member of a
+// team decrypts with their own identity.
+type X25519 struct{}
+
+// Encrypt encrypts plaintext to the given []*Recipient.
+func (x *X25519) Encrypt(plaintext string, key any, additionalData []byte) (string, error) {
+	recipients, ok := key.([]*Recipient)
+	if !ok {
+		return "", errors.New("invalid x25519 encryption key")
+	}
+
+	sealed, err := Seal([]byte(plaintext), recipients, additionalData)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value with the given *Identity.
+func (x *X25519) Decrypt(ciphertext string, key any, additionalData []byte) (string, error) {
+	identity, ok := key.(*Identity)
+	if !ok {
+		return "", errors.New("invalid x25519 decryption key")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := Open(sealed, identity, additionalData)
+	if err != nil {
+		return "", err
+	}
+	defer securemem.Wipe(plaintext)
+
+	return string(plaintext), nil
+}
+
+// Seal encrypts plaintext with a random AES-256-GCM data key and wraps the
+// data key for every recipient:
+//
+//	uvarint(recipients) || stanza... || nonce || ciphertext
+//
+// Each stanza is an ephemeral public key and the data key encrypted under a
+// key derived from its agreement with the recipient. Stanzas don't name
+// their recipient, so Open tries each of them.
+func Seal(plaintext []byte, recipients []*Recipient, additionalData []byte) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(dataKey)
+
+	out := binary.AppendUvarint(nil, uint64(len(recipients)))
+
+	for _, r := range recipients {
+		ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		shared, err := ephemeral.ECDH(r.Public)
+		if err != nil {
+			return nil, err
+		}
+
+		wrap, err := wrapAEAD(shared, ephemeral.PublicKey(), r.Public)
+		securemem.Wipe(shared)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, ephemeral.PublicKey().Bytes()...)
+		out = wrap.Seal(out, make([]byte, wrap.NonceSize()), dataKey, nil)
+	}
+
+	body, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, body.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out = append(out, nonce...)
+
+	return body.Seal(out, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts a value written by Seal with the identity of one of its
+// recipients.
+func Open(sealed []byte, identity *Identity, additionalData []byte) ([]byte, error) {
+	count, n := binary.Uvarint(sealed)
+	if n <= 0 || count == 0 || count > maxRecipients || uint64(len(sealed)-n) < count*stanzaSize {
+		return nil, ErrInvalidCiphertext
+	}
+
+	r := bytes.NewReader(sealed[n:])
+	public := identity.Private.PublicKey()
+
+	var dataKey []byte
+	stanza := make([]byte, stanzaSize)
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, stanza); err != nil {
+			return nil, ErrInvalidCiphertext
+		}
+		if dataKey != nil {
+			continue
+		}
+
+		ephemeral, err := ecdh.X25519().NewPublicKey(stanza[:32])
+		if err != nil {
+			continue
+		}
+
+		shared, err := identity.Private.ECDH(ephemeral)
+		if err != nil {
+			continue
+		}
+
+		wrap, err := wrapAEAD(shared, ephemeral, public)
+		securemem.Wipe(shared)
+		if err != nil {
+			return nil, err
+		}
+
+		dataKey, _ = wrap.Open(nil, make([]byte, wrap.NonceSize()), stanza[32:], nil)
+	}
+
+	if dataKey == nil {
+		return nil, ErrNotRecipient
+	}
+	defer securemem.Wipe(dataKey)
+
+	body, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := make([]byte, r.Len())
+	_, _ = r.Read(rest)
+	if len(rest) < body.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	return authenc.Open(body, rest[:body.NonceSize()], rest[body.NonceSize():], additionalData)
+}
+
+// wrapAEAD derives the key that wraps the data key for one recipient. The
+// ephemeral key is never reused, so neither is the derived key, which is
+// why a zero nonce is safe.
+func wrapAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+
+	key := make([]byte, 32)
+	defer securemem.Wipe(key)
+
+	_, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(info)), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
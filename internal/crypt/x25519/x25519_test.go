@@ -0,0 +1,113 @@
+package x25519_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/x25519"
+)
+
+func newIdentities(t *testing.T, n int) ([]*x25519.Identity, []*x25519.Recipient) {
+	t.Helper()
+
+	identities := make([]*x25519.Identity, 0, n)
+	recipients := make([]*x25519.Recipient, 0, n)
+	for i := 0; i < n; i++ {
+		identity, err := x25519.GenerateIdentity()
+		assert.NoError(t, err)
+
+		identities = append(identities, identity)
+		recipients = append(recipients, identity.Recipient())
+	}
+
+	return identities, recipients
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	assert := assert.New(t)
+	x := &x25519.X25519{}
+
+	identities, recipients := newIdentities(t, 3)
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"Normal text", "Hello, world!"},
+		{"Empty text", ""},
+		{"Special characters", "!@#$\n%^\t&*()"},
+		{"Long text", "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Vivamus lacinia odio vitae vestibulum."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ciphertext, err := x.Encrypt(tt.plaintext, recipients, nil)
+			assert.NoError(err)
+			assert.NotEqual(tt.plaintext, ciphertext)
+
+			// Every recipient decrypts with their own identity.
+			for _, identity := range identities {
+				decrypted, err := x.Decrypt(ciphertext, identity, nil)
+				assert.NoError(err)
+				assert.Equal(tt.plaintext, decrypted)
+			}
+		})
+	}
+}
+
+func TestDecryptNotRecipient(t *testing.T) {
+	assert := assert.New(t)
+	x := &x25519.X25519{}
+
+	_, recipients := newIdentities(t, 2)
+	outsiders, _ := newIdentities(t, 1)
+
+	ciphertext, err := x.Encrypt("secret", recipients, nil)
+	assert.NoError(err)
+
+	_, err = x.Decrypt(ciphertext, outsiders[0], nil)
+	assert.ErrorIs(err, x25519.ErrNotRecipient)
+
+	_, err = x.Encrypt("secret", []*x25519.Recipient{}, nil)
+	assert.ErrorIs(err, x25519.ErrNoRecipients)
+
+	_, err = x.Decrypt("AQ==", outsiders[0], nil)
+	assert.ErrorIs(err, x25519.ErrInvalidCiphertext)
+}
+
+func TestAdditionalData(t *testing.T) {
+	assert := assert.New(t)
+	x := &x25519.X25519{}
+
+	identities, recipients := newIdentities(t, 1)
+
+	ciphertext, err := x.Encrypt("secret", recipients, []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := x.Decrypt(ciphertext, identities[0], []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = x.Decrypt(ciphertext, identities[0], []byte("BAR"))
+	assert.Error(err)
+}
+
+func TestRecipients(t *testing.T) {
+	assert := assert.New(t)
+
+	_, recipients := newIdentities(t, 2)
+
+	parsed, err := x25519.ParseRecipient(recipients[0].String())
+	assert.NoError(err)
+	assert.True(recipients[0].Public.Equal(parsed.Public))
+
+	for _, invalid := range []string{"", "age1abc", "ck-x25519:", "ck-x25519:not base64", "ck-x25519:AAAA"} {
+		_, err = x25519.ParseRecipient(invalid)
+		assert.ErrorIs(err, x25519.ErrInvalidRecipient, invalid)
+	}
+
+	reversed := []*x25519.Recipient{recipients[1], recipients[0]}
+	assert.Equal(x25519.SetID(recipients), x25519.SetID(reversed))
+	assert.NotEqual(x25519.SetID(recipients), x25519.SetID(recipients[:1]))
+}
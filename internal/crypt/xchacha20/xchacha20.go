@@ -0,0 +1,117 @@
+package xchacha20
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/authenc"
+	"github.com/sunny-b/cryptkeeper/internal/crypt/keyid"
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+type EncryptionKey struct {
+	Key []byte `json:"key"`
+}
+
+// XChaCha20 encrypts with XChaCha20-Poly1305. Its 24 byte nonces are long
+// enough to be picked at random for every value without any realistic
+// chance of reuse, however often values are re-encrypted.
+type XChaCha20 struct {
+	aead cipher.AEAD
+}
+
+// ID returns the short identifier recorded next to every value encrypted
+// with this key.
+func (e *EncryptionKey) ID() string {
+	return keyid.New(e.Key)
+}
+
+// Fingerprint identifies the key in full, so two key files can be compared
+// without looking at the key itself.
+func (e *EncryptionKey) Fingerprint() string {
+	return keyid.Fingerprint(e.Key)
+}
+
+// Secret returns the raw key, which other keys can be derived from.
+func (e *EncryptionKey) Secret() []byte {
+	return e.Key
+}
+
+// LockMemory moves the key into memory that won't be swapped to disk.
+func (e *EncryptionKey) LockMemory() {
+	e.Key = securemem.Lock(e.Key)
+}
+
+func GenerateKeys() (*EncryptionKey, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	return &EncryptionKey{key}, nil
+}
+
+// Encrypt encrypts the given plaintext with the given key using
+// XChaCha20-Poly1305, authenticating additionalData alongside it.
+func (x *XChaCha20) Encrypt(plaintext string, key any, additionalData []byte) (string, error) {
+	e, ok := key.(*EncryptionKey)
+	if !ok {
+		return "", errors.New("invalid encryption key")
+	}
+
+	if x.aead == nil {
+		var err error
+		x.aead, err = chacha20poly1305.NewX(e.Key)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	nonce := make([]byte, x.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	ciphertext := x.aead.Seal(nonce, nonce, []byte(plaintext), additionalData)
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt decrypts the given ciphertext with the given key using
+// XChaCha20-Poly1305. additionalData must match what was passed to Encrypt.
+func (x *XChaCha20) Decrypt(cipherText string, key any, additionalData []byte) (string, error) {
+	e, ok := key.(*EncryptionKey)
+	if !ok {
+		return "", errors.New("invalid encryption key")
+	}
+
+	rawCiphertext, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", err
+	}
+
+	if x.aead == nil {
+		x.aead, err = chacha20poly1305.NewX(e.Key)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	nonceSize := x.aead.NonceSize()
+	if len(rawCiphertext) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, cipher := rawCiphertext[:nonceSize], rawCiphertext[nonceSize:]
+
+	b, err := authenc.Open(x.aead, nonce, cipher, additionalData)
+	defer securemem.Wipe(b)
+
+	return string(b), err
+}
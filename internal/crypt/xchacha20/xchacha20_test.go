@@ -0,0 +1,87 @@
+package xchacha20_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/sunny-b/cryptkeeper/internal/crypt/xchacha20"
+)
+
+func TestXChaCha20(t *testing.T) {
+	assert := assert.New(t)
+	key, err := xchacha20.GenerateKeys()
+	assert.NoError(err)
+
+	x := &xchacha20.XChaCha20{}
+
+	// Table-driven tests
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"Normal text", "Hello, world!"},
+		{"Empty text", ""},
+		{"Special characters", "!@#$\n%^\t&*()"},
+		{"Long text", "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Vivamus lacinia odio vitae vestibulum."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Encryption
+			cipherText, err := x.Encrypt(tt.plaintext, key, nil)
+			assert.NoError(err)
+
+			// Decryption
+			decrypted, err := x.Decrypt(cipherText, key, nil)
+			assert.NoError(err)
+			assert.Equal(tt.plaintext, string(decrypted))
+		})
+	}
+}
+
+func TestAdditionalData(t *testing.T) {
+	assert := assert.New(t)
+	x := &xchacha20.XChaCha20{}
+
+	key, err := xchacha20.GenerateKeys()
+	assert.NoError(err)
+
+	ciphertext, err := x.Encrypt("secret", key, []byte("FOO"))
+	assert.NoError(err)
+
+	decrypted, err := x.Decrypt(ciphertext, key, []byte("FOO"))
+	assert.NoError(err)
+	assert.Equal("secret", decrypted)
+
+	_, err = x.Decrypt(ciphertext, key, []byte("BAR"))
+	assert.Error(err)
+
+	_, err = x.Decrypt(ciphertext, key, nil)
+	assert.Error(err)
+}
+
+func TestRandomNonces(t *testing.T) {
+	assert := assert.New(t)
+	x := &xchacha20.XChaCha20{}
+
+	key, err := xchacha20.GenerateKeys()
+	assert.NoError(err)
+
+	// Re-encrypting the same value never reuses a nonce.
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		ciphertext, err := x.Encrypt("secret", key, nil)
+		assert.NoError(err)
+
+		raw, err := base64.StdEncoding.DecodeString(ciphertext)
+		assert.NoError(err)
+		assert.Len(raw, chacha20poly1305.NonceSizeX+len("secret")+chacha20poly1305.Overhead)
+
+		nonce := string(raw[:chacha20poly1305.NonceSizeX])
+		assert.False(seen[nonce])
+		seen[nonce] = true
+	}
+}
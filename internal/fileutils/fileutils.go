@@ -26,6 +26,18 @@ func ReadFile(fileName string) ([]byte, error) {
 	return afero.ReadFile(fs, fileName)
 }
 
+func Rename(oldPath, newPath string) error {
+	return fs.Rename(oldPath, newPath)
+}
+
+func Remove(fileName string) error {
+	return fs.Remove(fileName)
+}
+
+func Stat(fileName string) (os.FileInfo, error) {
+	return fs.Stat(fileName)
+}
+
 func TextExistsInFile(filePath, targetText string) (bool, error) {
 	content, err := afero.ReadFile(fs, filePath)
 	if err != nil {
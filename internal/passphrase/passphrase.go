@@ -0,0 +1,108 @@
+package passphrase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+const (
+	// EnvKey holds the passphrase for non-interactive use, such as CI.
+	EnvKey = "CK_PASSPHRASE"
+
+	// PINEnvKey holds the PIN of a PKCS#11 token for non-interactive use.
+	PINEnvKey = "CK_PKCS11_PIN"
+)
+
+var (
+	ErrEmpty    = errors.New("passphrase can't be empty")
+	ErrEmptyPIN = errors.New("PIN can't be empty")
+	ErrMismatch = errors.New("passphrases don't match")
+)
+
+// Read returns the passphrase from CK_PASSPHRASE, or prompts for it on the
+// terminal.
+func Read(prompt string) ([]byte, error) {
+	if value, ok := os.LookupEnv(EnvKey); ok {
+		return []byte(value), nil
+	}
+
+	b, err := readFromTerminal(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, ErrEmpty
+	}
+
+	return b, nil
+}
+
+// ReadPIN returns the token PIN from CK_PKCS11_PIN, or prompts for it on
+// the terminal.
+func ReadPIN(prompt string) ([]byte, error) {
+	if value, ok := os.LookupEnv(PINEnvKey); ok {
+		return []byte(value), nil
+	}
+
+	b, err := readFromTerminal(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, ErrEmptyPIN
+	}
+
+	return b, nil
+}
+
+// New asks for a new passphrase twice, unless it's set in CK_PASSPHRASE.
+func New() ([]byte, error) {
+	if value, ok := os.LookupEnv(EnvKey); ok {
+		if value == "" {
+			return nil, ErrEmpty
+		}
+
+		return []byte(value), nil
+	}
+
+	first, err := Read("Enter new passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+
+	second, err := Read("Confirm passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(second)
+
+	if !bytes.Equal(first, second) {
+		return nil, ErrMismatch
+	}
+
+	return first, nil
+}
+
+// readFromTerminal prompts on the controlling terminal rather than
+// stdin/stdout, which may be piped or evaluated by the shell hook.
+func readFromTerminal(prompt string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprint(os.Stderr, prompt)
+		defer fmt.Fprintln(os.Stderr)
+
+		return term.ReadPassword(int(os.Stdin.Fd()))
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	defer fmt.Fprintln(tty)
+
+	return term.ReadPassword(int(tty.Fd()))
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package securemem
+
+// lockedCopy copies b. Memory locking isn't supported on this platform.
+func lockedCopy(b []byte) ([]byte, error) {
+	locked := make([]byte, len(b))
+	copy(locked, b)
+
+	return locked, nil
+}
+
+func unlock(b []byte) {}
+
+// DisableCoreDumps is a no-op on this platform.
+func DisableCoreDumps() error {
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package securemem
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// lockedCopy copies b into memory that won't be swapped to disk. The copy
+// is returned even when locking fails, so callers can decide whether to use
+// it.
+func lockedCopy(b []byte) ([]byte, error) {
+	locked := make([]byte, len(b))
+	copy(locked, b)
+
+	if len(locked) == 0 {
+		return locked, nil
+	}
+
+	return locked, unix.Mlock(locked)
+}
+
+func unlock(b []byte) {
+	if len(b) > 0 {
+		_ = unix.Munlock(b)
+	}
+}
+
+// DisableCoreDumps keeps the process's memory out of core files.
+func DisableCoreDumps() error {
+	return unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0})
+}
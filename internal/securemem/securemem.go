@@ -0,0 +1,117 @@
+// Package securemem keeps secrets out of swap and core files and wipes them
+// when they're no longer needed.
+//
+// Only byte slices can be protected, so it covers key material: keys,
+// derived keys and the buffers they're read into. Decrypted values are Go
+// strings from the Keeper through config.Env, envdiff and the shell
+// exporters, which print them anyway, so they aren't protected. Strings are
+// immutable, and wiping a []byte copy of one leaves the string itself in
+// memory. Key types from the standard library, such as
+// *ecdh.PrivateKey and *rsa.PrivateKey, keep their own copies too. All of
+// these stay in ordinary memory until the process exits; DisableCoreDumps at
+// least keeps them out of core files.
+package securemem
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	mu   sync.Mutex
+	live = make(map[*Buffer]struct{})
+)
+
+// Buffer is a copy of a secret held in memory that won't be swapped to
+// disk. It's wiped by Destroy or WipeAll.
+type Buffer struct {
+	b      []byte
+	locked bool
+}
+
+// New copies src into locked memory and wipes src. It fails when the memory
+// can't be locked, for example because RLIMIT_MEMLOCK is too low.
+func New(src []byte) (*Buffer, error) {
+	b, err := lockedCopy(src)
+	Wipe(src)
+	if err != nil {
+		Wipe(b)
+		return nil, err
+	}
+
+	return track(&Buffer{b: b, locked: true}), nil
+}
+
+// Lock moves the secret in b into locked memory, wipes b and returns the
+// locked copy, which WipeAll wipes. When the memory can't be locked, the
+// copy is still wiped by WipeAll but may be swapped to disk.
+func Lock(b []byte) []byte {
+	if len(b) == 0 {
+		return b
+	}
+
+	locked, err := lockedCopy(b)
+	Wipe(b)
+	if err != nil {
+		logrus.
+			WithError(err).
+			Debug("failed to lock secret in memory, it may be swapped to disk")
+	}
+
+	return track(&Buffer{b: locked, locked: err == nil}).b
+}
+
+func track(buf *Buffer) *Buffer {
+	mu.Lock()
+	defer mu.Unlock()
+
+	live[buf] = struct{}{}
+
+	return buf
+}
+
+// Bytes returns the secret. It's only valid until the buffer is destroyed.
+func (b *Buffer) Bytes() []byte {
+	return b.b
+}
+
+// Destroy wipes the buffer and unlocks its memory.
+func (b *Buffer) Destroy() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	b.destroy()
+}
+
+// destroy wipes the buffer. mu must be held.
+func (b *Buffer) destroy() {
+	if _, ok := live[b]; !ok {
+		return
+	}
+
+	Wipe(b.b)
+	if b.locked {
+		unlock(b.b)
+	}
+
+	delete(live, b)
+}
+
+// WipeAll wipes every live buffer. Commands call it once they finish, after
+// which secrets returned by Lock are zero.
+func WipeAll() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for buf := range live {
+		buf.destroy()
+	}
+}
+
+// Wipe zeroes b.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
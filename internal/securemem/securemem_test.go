@@ -0,0 +1,53 @@
+package securemem_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sunny-b/cryptkeeper/internal/securemem"
+)
+
+func TestBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := []byte("hunter2")
+	buf, err := securemem.New(secret)
+	if err != nil {
+		t.Skipf("memory can't be locked here: %s", err)
+	}
+
+	assert.Equal([]byte("hunter2"), buf.Bytes())
+	assert.Equal(make([]byte, 7), secret)
+
+	b := buf.Bytes()
+	buf.Destroy()
+	assert.Equal(make([]byte, 7), b)
+
+	// Destroying twice is harmless.
+	buf.Destroy()
+}
+
+func TestLockAndWipeAll(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := []byte("hunter2")
+	locked := securemem.Lock(secret)
+	assert.Equal([]byte("hunter2"), locked)
+	assert.Equal(make([]byte, 7), secret)
+
+	other := securemem.Lock(bytes.Repeat([]byte{0xff}, 32))
+
+	securemem.WipeAll()
+	assert.Equal(make([]byte, 7), locked)
+	assert.Equal(make([]byte, 32), other)
+
+	assert.Empty(securemem.Lock(nil))
+}
+
+func TestWipe(t *testing.T) {
+	b := []byte("hunter2")
+	securemem.Wipe(b)
+	assert.Equal(t, make([]byte, 7), b)
+}
@@ -37,7 +37,7 @@ ck_env
 
 test_scenario base
 
-set algorithms aes ecc serpent rsa
+set algorithms aes ecc serpent rsa rsa4096 x25519 age xchacha20 aes256-siv mlkem768-x25519
 for algo in $algorithms
   set -x TARGET_ENCRYPTION $algo
   test_scenario encryption
@@ -34,6 +34,147 @@ section "Verifying secret"
 test_eq (echo 'bar' | cryptkeeper verify FOO) "equal"
 test_eq (echo 'false' | cryptkeeper verify FOO) "not-equal"
 
+if test "$TARGET_ENCRYPTION" != "age" -a "$TARGET_ENCRYPTION" != "x25519"
+  section "Checking the key fingerprint"
+
+  test_eq (cryptkeeper key info | awk '/^Fingerprint:/ { print $2 }') (jq -r .encryption.fingerprint .ckrc)
+  mkdir nest
+  pushd nest
+  cryptkeeper init "$TARGET_SHELL" -e "$TARGET_ENCRYPTION" --standalone >/dev/null
+  popd
+  mv .ckkey .ckkey.mine
+  cp nest/.ckkey .ckkey
+  test_nempty (cryptkeeper decrypt FOO 2>&1 | grep 'wrong key')
+  mv .ckkey.mine .ckkey
+  rm -rf nest
+  test_eq (cryptkeeper decrypt FOO) "bar"
+end
+
+section "Adding large secret"
+
+set large (string repeat -n 3000 x)
+echo "$large" | cryptkeeper set LARGE
+test_eq (cryptkeeper decrypt LARGE) "$large"
+cryptkeeper remove LARGE
+
+if test "$TARGET_ENCRYPTION" != "age"
+  section "Padding secrets"
+
+  cryptkeeper reencrypt --padding 64
+  echo "1234" | cryptkeeper set PIN
+  echo (string repeat -n 40 x) | cryptkeeper set TOKEN
+  test_eq (cryptkeeper decrypt PIN) "1234"
+  test_eq (jq -r .env.PIN .ckrc | wc -c) (jq -r .env.TOKEN .ckrc | wc -c)
+  cryptkeeper reencrypt --padding none
+  test_eq (cryptkeeper decrypt PIN) "1234"
+  cryptkeeper remove PIN TOKEN
+end
+
+if test "$TARGET_ENCRYPTION" = "ecc"
+  section "Adding secret with only the public key"
+
+  cryptkeeper key public
+  test_eq (jq -r .private_key .ckkey.pub) "null"
+  mv .ckkey .ckkey.private
+  cp .ckkey.pub .ckkey
+  echo "baz" | cryptkeeper set BAZ
+  test_neq (cryptkeeper decrypt BAZ 2>/dev/null) "baz"
+  mv .ckkey.private .ckkey
+  test_neq (cryptkeeper decrypt BAZ 2>/dev/null) "baz"
+  cryptkeeper reseal
+  test_eq (cryptkeeper decrypt BAZ) "baz"
+  cryptkeeper remove BAZ
+end
+
+if test "$TARGET_ENCRYPTION" = "x25519"
+  section "Adding and removing recipients"
+
+  set bob (cryptkeeper recipients identity --key-path .ckkey.bob)
+  cryptkeeper recipients add "$bob" bob
+  test_eq (grep -c '^ck-x25519:' .ckrecipients) "2"
+  test_eq (cryptkeeper decrypt FOO) "bar"
+  cryptkeeper recipients remove bob
+  test_eq (grep -c '^ck-x25519:' .ckrecipients) "1"
+  test_eq (cryptkeeper decrypt FOO) "bar"
+end
+
+section "Protecting key"
+
+set -gx CK_PASSPHRASE "hunter2"
+cryptkeeper key protect
+test_eq (jq -r .wrap .ckkey) "argon2id"
+test_eq (cryptkeeper decrypt FOO) "bar"
+test_neq (env CK_PASSPHRASE=wrong cryptkeeper decrypt FOO 2>/dev/null) "bar"
+
+section "Rotating key"
+
+set old_key (cat .ckkey)
+cryptkeeper rotate --yes
+test_neq (cat .ckkey) "$old_key"
+test_eq (cryptkeeper decrypt FOO) "bar"
+ck_env
+test_eq "$FOO" "bar"
+
+section "Migrating encryption"
+
+set target "serpent"
+if test "$TARGET_ENCRYPTION" = "serpent"
+  set target "aes"
+end
+cryptkeeper migrate-encryption --to "$target" --yes
+test_eq (cryptkeeper decrypt FOO) "bar"
+ck_env
+test_eq "$FOO" "bar"
+
+section "Unprotecting key"
+
+test_eq (jq -r .wrap .ckkey) "argon2id"
+cryptkeeper key unprotect
+set -e CK_PASSPHRASE
+test_eq (cryptkeeper decrypt FOO) "bar"
+
+section "Splitting key"
+
+cryptkeeper key split --shares 5 --threshold 3 >.ckkey.shares 2>/dev/null
+mv .ckkey .ckkey.split
+test_neq (head -n 2 .ckkey.shares | cryptkeeper key combine >/dev/null 2>&1; echo $status) "0"
+sed -n 2,4p .ckkey.shares | cryptkeeper key combine
+test_eq (cryptkeeper decrypt FOO) "bar"
+rm .ckkey.split .ckkey.shares
+
+section "Supplying the key from CI"
+
+set ci_key (cryptkeeper key print-ci 2>/dev/null)
+mv .ckkey .ckkey.ci
+test_eq (env CK_KEY="$ci_key" cryptkeeper decrypt FOO) "bar"
+test_eq (env CK_KEY_FILE=.ckkey.ci cryptkeeper decrypt FOO) "bar"
+test_eq (cryptkeeper decrypt FOO --key-fd 3 3<.ckkey.ci) "bar"
+test_neq (cryptkeeper decrypt FOO 2>/dev/null) "bar"
+mv .ckkey.ci .ckkey
+
+section "Exporting key as a mnemonic"
+
+cryptkeeper key export --mnemonic >.ckkey.words
+test_eq (wc -w <.ckkey.words | tr -d ' ') "24"
+mv .ckkey .ckkey.exported
+cryptkeeper key import --mnemonic -e (jq -r .encryption.type .ckrc) <.ckkey.words
+test_eq (cryptkeeper decrypt FOO) "bar"
+rm .ckkey.exported .ckkey.words
+
+section "Moving key to the key store"
+
+set -gx XDG_DATA_HOME "$PWD/.ckdata"
+cryptkeeper key store
+test_eq (jq -r .encryption.key_path .ckrc) "null"
+test_eq (ls .ckkey 2>/dev/null) ""
+test_eq (ls .ckdata/cryptkeeper/keys | wc -l | tr -d ' ') "1"
+test_eq (cryptkeeper decrypt FOO) "bar"
+cryptkeeper rotate --yes
+test_eq (jq -r .encryption.key_path .ckrc) "null"
+test_eq (cryptkeeper decrypt FOO) "bar"
+ck_env
+test_eq "$FOO" "bar"
+
 section "Remove secret"
 
 cryptkeeper remove FOO
@@ -35,6 +35,211 @@ section "Verifying secret"
 test_eq "$(echo 'bar' | cryptkeeper verify FOO)" "equal"
 test_eq "$(echo 'false' | cryptkeeper verify FOO)" "not-equal"
 
+if [[ "$TARGET_ENCRYPTION" != "age" && "$TARGET_ENCRYPTION" != "x25519" ]]; then
+  section "Checking the key fingerprint"
+
+  test_eq "$(cryptkeeper key info | awk '/^Fingerprint:/ { print $2 }')" "$(jq -r .encryption.fingerprint .ckrc)"
+  mkdir nest
+  pushd nest >/dev/null
+  cryptkeeper init "${TARGET_SHELL}" -e "${TARGET_ENCRYPTION}" --standalone >/dev/null
+  popd >/dev/null
+  mv .ckkey .ckkey.mine
+  cp nest/.ckkey .ckkey
+  test_nempty "$(cryptkeeper decrypt FOO 2>&1 | grep 'wrong key')"
+  mv .ckkey.mine .ckkey
+  rm -rf nest
+  test_eq "$(cryptkeeper decrypt FOO)" "bar"
+fi
+
+if [[ "$TARGET_ENCRYPTION" == "aes256-siv" ]]; then
+  section "Re-encrypting unchanged secret"
+
+  before="$(jq -r .env.FOO .ckrc)"
+  echo "bar" | cryptkeeper set FOO
+  test_eq "$(jq -r .env.FOO .ckrc)" "$before"
+  echo "baz" | cryptkeeper set FOO
+  test_neq "$(jq -r .env.FOO .ckrc)" "$before"
+  echo "bar" | cryptkeeper set FOO
+  test_eq "$(jq -r .env.FOO .ckrc)" "$before"
+fi
+
+section "Adding large secret"
+
+large="$(printf 'x%.0s' {1..3000})"
+echo "$large" | cryptkeeper set LARGE
+test_eq "$(cryptkeeper decrypt LARGE)" "$large"
+cryptkeeper remove LARGE
+
+if [[ "$TARGET_ENCRYPTION" != "age" ]]; then
+  section "Padding secrets"
+
+  cryptkeeper reencrypt --padding 64
+  echo "1234" | cryptkeeper set PIN
+  echo "$(printf 'x%.0s' {1..40})" | cryptkeeper set TOKEN
+  test_eq "$(cryptkeeper decrypt PIN)" "1234"
+  test_eq "$(jq -r .env.PIN .ckrc | wc -c)" "$(jq -r .env.TOKEN .ckrc | wc -c)"
+  cryptkeeper reencrypt --padding none
+  test_eq "$(cryptkeeper decrypt PIN)" "1234"
+  cryptkeeper remove PIN TOKEN
+fi
+
+if [[ "$TARGET_ENCRYPTION" == "ecc" ]]; then
+  section "Adding secret with only the public key"
+
+  cryptkeeper key public
+  test_eq "$(jq -r .private_key .ckkey.pub)" "null"
+  mv .ckkey .ckkey.private
+  cp .ckkey.pub .ckkey
+  echo "baz" | cryptkeeper set BAZ
+  test_neq "$(cryptkeeper decrypt BAZ 2>/dev/null)" "baz"
+  mv .ckkey.private .ckkey
+  test_neq "$(cryptkeeper decrypt BAZ 2>/dev/null)" "baz"
+  cryptkeeper reseal
+  test_eq "$(cryptkeeper decrypt BAZ)" "baz"
+  cryptkeeper remove BAZ
+fi
+
+if [[ "$TARGET_ENCRYPTION" == "x25519" ]]; then
+  section "Adding and removing recipients"
+
+  bob="$(cryptkeeper recipients identity --key-path .ckkey.bob)"
+  cryptkeeper recipients add "$bob" bob
+  test_eq "$(grep -c '^ck-x25519:' .ckrecipients)" "2"
+  test_eq "$(cryptkeeper decrypt FOO)" "bar"
+  cryptkeeper recipients remove bob
+  test_eq "$(grep -c '^ck-x25519:' .ckrecipients)" "1"
+  test_eq "$(cryptkeeper decrypt FOO)" "bar"
+fi
+
+if [[ "$TARGET_ENCRYPTION" == "age" ]]; then
+  section "Adding SSH recipients"
+
+  ssh-keygen -q -t ed25519 -N "" -C bob -f .ckkey.ssh
+  cryptkeeper recipients add "$(cat .ckkey.ssh.pub)" bob
+  test_eq "$(grep -c '^ssh-ed25519 ' .ckrecipients)" "1"
+  test_eq "$(jq -r .env.FOO .ckrc | head -n 1)" "-----BEGIN AGE ENCRYPTED FILE-----"
+  mv .ckkey .ckkey.age
+  cp .ckkey.ssh .ckkey
+  test_eq "$(cryptkeeper decrypt FOO)" "bar"
+  mv .ckkey.age .ckkey
+  cryptkeeper recipients remove bob
+  test_eq "$(grep -c '^ssh-ed25519 ' .ckrecipients)" "0"
+
+  section "Initializing with an existing SSH key"
+
+  mkdir nest
+  pushd nest >/dev/null
+  cryptkeeper init "${TARGET_SHELL}" -e age -k ../.ckkey.ssh --recipient "$(cat ../.ckkey.ssh.pub)" --standalone
+  echo "qux" | cryptkeeper set QUX
+  test_eq "$(cryptkeeper decrypt QUX)" "qux"
+  test_eq "$(grep -c '^ssh-ed25519 ' .ckrecipients)" "1"
+  test_neq "$(cryptkeeper rotate --yes 2>/dev/null; echo $?)" "0"
+  popd >/dev/null
+fi
+
+section "Protecting key"
+
+export CK_PASSPHRASE="hunter2"
+cryptkeeper key protect
+test_eq "$(jq -r .wrap .ckkey)" "argon2id"
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+test_neq "$(CK_PASSPHRASE=wrong cryptkeeper decrypt FOO 2>/dev/null)" "bar"
+
+section "Rotating key"
+
+old_key="$(cat .ckkey)"
+cryptkeeper rotate --yes
+test_neq "$(cat .ckkey)" "$old_key"
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+ck_env
+test_eq "$FOO" "bar"
+
+section "Migrating encryption"
+
+target="serpent"
+if [[ "$TARGET_ENCRYPTION" == "serpent" ]]; then
+  target="aes"
+fi
+cryptkeeper migrate-encryption --to "$target" --yes
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+ck_env
+test_eq "$FOO" "bar"
+
+section "Unprotecting key"
+
+test_eq "$(jq -r .wrap .ckkey)" "argon2id"
+cryptkeeper key unprotect
+unset CK_PASSPHRASE
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+
+section "Splitting key"
+
+cryptkeeper key split --shares 5 --threshold 3 >.ckkey.shares 2>/dev/null
+mv .ckkey .ckkey.split
+test_neq "$(head -n 2 .ckkey.shares | cryptkeeper key combine >/dev/null 2>&1; echo $?)" "0"
+sed -n 2,4p .ckkey.shares | cryptkeeper key combine
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+rm .ckkey.split .ckkey.shares
+
+section "Supplying the key from CI"
+
+ci_key="$(cryptkeeper key print-ci 2>/dev/null)"
+mv .ckkey .ckkey.ci
+test_eq "$(CK_KEY="$ci_key" cryptkeeper decrypt FOO)" "bar"
+test_eq "$(CK_KEY_FILE=.ckkey.ci cryptkeeper decrypt FOO)" "bar"
+test_eq "$(cryptkeeper decrypt FOO --key-fd 3 3<.ckkey.ci)" "bar"
+test_neq "$(cryptkeeper decrypt FOO 2>/dev/null)" "bar"
+mv .ckkey.ci .ckkey
+
+section "Exporting key as a mnemonic"
+
+cryptkeeper key export --mnemonic >.ckkey.words
+test_eq "$(wc -w <.ckkey.words | tr -d ' ')" "24"
+mv .ckkey .ckkey.exported
+cryptkeeper key import --mnemonic -e "$(jq -r .encryption.type .ckrc)" <.ckkey.words
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+rm .ckkey.exported .ckkey.words
+
+cryptkeeper key export >.ckkey.b64
+mv .ckkey .ckkey.exported
+cryptkeeper key import -e "$(jq -r .encryption.type .ckrc)" <.ckkey.b64
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+rm .ckkey.exported .ckkey.b64
+
+section "Protecting key with an SSH key"
+
+ssh-keygen -q -t ed25519 -N "" -f .ckkey.agent
+eval "$(ssh-agent -s)" >/dev/null
+ssh-add -q .ckkey.agent
+cryptkeeper key protect --ssh-key .ckkey.agent.pub
+test_eq "$(jq -r .wrap .ckkey)" "ssh"
+test_eq "$(jq -r .encryption.ssh_key .ckrc)" "$(ssh-keygen -l -f .ckkey.agent.pub | cut -d ' ' -f 2)"
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+cryptkeeper rotate --yes
+test_eq "$(jq -r .wrap .ckkey)" "ssh"
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+ssh-add -q -D
+test_neq "$(cryptkeeper decrypt FOO 2>/dev/null)" "bar"
+ssh-add -q .ckkey.agent
+cryptkeeper key unprotect
+test_eq "$(jq -r .encryption.ssh_key .ckrc)" "null"
+ssh-agent -k >/dev/null
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+
+section "Moving key to the key store"
+
+export XDG_DATA_HOME="$PWD/.ckdata"
+cryptkeeper key store
+test_eq "$(jq -r .encryption.key_path .ckrc)" "null"
+test_eq "$(ls .ckkey 2>/dev/null)" ""
+test_eq "$(ls .ckdata/cryptkeeper/keys | wc -l | tr -d ' ')" "1"
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+cryptkeeper rotate --yes
+test_eq "$(jq -r .encryption.key_path .ckrc)" "null"
+test_eq "$(cryptkeeper decrypt FOO)" "bar"
+ck_env
+test_eq "$FOO" "bar"
+
 section "Remove secret"
 
 cryptkeeper remove FOO
@@ -51,7 +51,7 @@ end
 function cleanup_ck
   set -l files .ck*
   if test -n "$files"
-    rm -f $files
+    rm -rf $files
   end
 end
 
@@ -39,7 +39,7 @@ test_nempty() {
 }
 
 cleanup_ck() {
-  rm -f .ck*
+  rm -rf .ck*
   rm -rf nest
 }
 
@@ -42,7 +42,7 @@ ck_env
 
 test base
 
-algorithms=("aes" "ecc" "serpent" "rsa")
+algorithms=("aes" "ecc" "serpent" "rsa" "rsa4096" "x25519" "age" "xchacha20" "aes256-siv" "mlkem768-x25519")
 for algo in "${algorithms[@]}"; do
   TARGET_ENCRYPTION="$algo" test encryption
 done